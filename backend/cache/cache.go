@@ -0,0 +1,24 @@
+// Package cache stores encoded values for a limited time, in process or in
+// Redis, for the caching repository decorator.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds values under string keys until their TTL passes or they are
+// evicted. Alongside values it keeps generation counters, which are never
+// evicted: a counter that went back to an old value would bring stale
+// entries back to life.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Generation returns the current value of the counter name, 0 if it was
+	// never bumped
+	Generation(ctx context.Context, name string) (int64, error)
+
+	// Bump increments the counter name
+	Bump(ctx context.Context, name string) error
+}
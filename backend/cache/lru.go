@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultLRUSize is how many entries an LRU holds when no size is given
+const DefaultLRUSize = 10000
+
+type (
+	// LRU is an in-process Store that evicts the least recently used entry
+	// once it holds size entries. Each process has its own, so use Redis when
+	// several instances must see each other's invalidations.
+	LRU struct {
+		mu          sync.Mutex
+		size        int
+		entries     map[string]*list.Element
+		order       *list.List
+		generations map[string]int64
+		now         func() time.Time
+	}
+
+	lruEntry struct {
+		key       string
+		value     []byte
+		expiresAt time.Time
+	}
+)
+
+var _ Store = (*LRU)(nil)
+
+// NewLRU creates an LRU holding up to size entries, or DefaultLRUSize when
+// size is not positive
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = DefaultLRUSize
+	}
+	return &LRU{
+		size:        size,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		generations: make(map[string]int64),
+		now:         time.Now,
+	}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(element)
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Generation(_ context.Context, name string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[name], nil
+}
+
+func (c *LRU) Bump(_ context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[name]++
+	return nil
+}
+
+// Len reports how many entries are held, including expired ones not yet removed
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Store shared by every instance of the API. Values expire through
+// Redis TTLs; generation counters are kept without one.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+var _ Store = (*Redis)(nil)
+
+// NewRedis creates a Redis store that namespaces its keys with prefix
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// NewRedisFromURL connects to a redis:// or rediss:// URL, such as REDIS_DSN
+func NewRedisFromURL(ctx context.Context, url, prefix string) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return NewRedis(client, prefix), nil
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+func (c *Redis) Generation(ctx context.Context, name string) (int64, error) {
+	generation, err := c.client.Get(ctx, c.generationKey(name)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (c *Redis) Bump(ctx context.Context, name string) error {
+	return c.client.Incr(ctx, c.generationKey(name)).Err()
+}
+
+// Close releases the connection pool
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
+
+func (c *Redis) generationKey(name string) string {
+	return c.prefix + "generation:" + name
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/cache"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type CacheTestSuite struct {
+	testutils.BaseSuite
+	miniRedis *miniredis.Miniredis
+}
+
+func TestCache(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (suite *CacheTestSuite) SetupTest() {
+	suite.miniRedis = miniredis.RunT(suite.T())
+}
+
+func (suite *CacheTestSuite) stores() map[string]cache.Store {
+	return map[string]cache.Store{
+		"lru":   cache.NewLRU(0),
+		"redis": cache.NewRedis(redis.NewClient(&redis.Options{Addr: suite.miniRedis.Addr()}), "test:"),
+	}
+}
+
+func (suite *CacheTestSuite) TestGetAndSet() {
+	ctx := context.Background()
+
+	for name, store := range suite.stores() {
+		suite.Run(name, func() {
+			_, found, err := store.Get(ctx, "missing")
+			suite.Require().NoError(err)
+			suite.False(found)
+
+			suite.Require().NoError(store.Set(ctx, "key", []byte("value"), time.Minute))
+			value, found, err := store.Get(ctx, "key")
+			suite.Require().NoError(err)
+			suite.True(found)
+			suite.Equal([]byte("value"), value)
+
+			suite.Require().NoError(store.Set(ctx, "key", []byte("replaced"), time.Minute))
+			value, _, err = store.Get(ctx, "key")
+			suite.Require().NoError(err)
+			suite.Equal([]byte("replaced"), value)
+		})
+	}
+}
+
+func (suite *CacheTestSuite) TestGenerations() {
+	ctx := context.Background()
+
+	for name, store := range suite.stores() {
+		suite.Run(name, func() {
+			generation, err := store.Generation(ctx, "users")
+			suite.Require().NoError(err)
+			suite.Zero(generation)
+
+			suite.Require().NoError(store.Bump(ctx, "users"))
+			suite.Require().NoError(store.Bump(ctx, "users"))
+
+			generation, err = store.Generation(ctx, "users")
+			suite.Require().NoError(err)
+			suite.Equal(int64(2), generation)
+
+			generation, err = store.Generation(ctx, "posts")
+			suite.Require().NoError(err)
+			suite.Zero(generation, "counters are independent")
+		})
+	}
+}
+
+func (suite *CacheTestSuite) TestLRUEvictsLeastRecentlyUsed() {
+	ctx := context.Background()
+	store := cache.NewLRU(2)
+
+	suite.Require().NoError(store.Set(ctx, "a", []byte("a"), time.Minute))
+	suite.Require().NoError(store.Set(ctx, "b", []byte("b"), time.Minute))
+	_, found, _ := store.Get(ctx, "a")
+	suite.True(found)
+
+	suite.Require().NoError(store.Set(ctx, "c", []byte("c"), time.Minute))
+	suite.Equal(2, store.Len())
+
+	_, found, _ = store.Get(ctx, "b")
+	suite.False(found, "b was used least recently")
+	_, found, _ = store.Get(ctx, "a")
+	suite.True(found)
+	_, found, _ = store.Get(ctx, "c")
+	suite.True(found)
+}
+
+func (suite *CacheTestSuite) TestLRUExpiresEntries() {
+	ctx := context.Background()
+	store := cache.NewLRU(0)
+
+	suite.Require().NoError(store.Set(ctx, "key", []byte("value"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	_, found, err := store.Get(ctx, "key")
+	suite.Require().NoError(err)
+	suite.False(found)
+	suite.Zero(store.Len())
+}
+
+func (suite *CacheTestSuite) TestRedisExpiresEntriesButNotGenerations() {
+	ctx := context.Background()
+	store := cache.NewRedis(redis.NewClient(&redis.Options{Addr: suite.miniRedis.Addr()}), "test:")
+
+	suite.Require().NoError(store.Set(ctx, "key", []byte("value"), time.Minute))
+	suite.Require().NoError(store.Bump(ctx, "users"))
+	suite.True(suite.miniRedis.Exists("test:key"), "keys are prefixed")
+
+	suite.miniRedis.FastForward(2 * time.Minute)
+
+	_, found, err := store.Get(ctx, "key")
+	suite.Require().NoError(err)
+	suite.False(found)
+
+	generation, err := store.Generation(ctx, "users")
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), generation)
+}
+
+func (suite *CacheTestSuite) TestRedisFromURL() {
+	ctx := context.Background()
+
+	url := "redis://" + suite.miniRedis.Addr() + "/0"
+
+	store, err := cache.NewRedisFromURL(ctx, url, "test:")
+	suite.Require().NoError(err)
+	defer store.Close()
+	suite.NoError(store.Set(ctx, "key", []byte("value"), time.Minute))
+
+	suite.miniRedis.Close()
+	_, err = cache.NewRedisFromURL(ctx, url, "test:")
+	suite.Error(err, "an unreachable server is reported at startup")
+
+	_, err = cache.NewRedisFromURL(ctx, "not a url", "test:")
+	suite.Error(err)
+}
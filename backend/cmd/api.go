@@ -3,17 +3,29 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"github.com/spf13/cobra"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/redis/go-redis/v9"
+
+	"github.com/tejiriaustin/lema/cache"
 	constants "github.com/tejiriaustin/lema/constants"
 	"github.com/tejiriaustin/lema/database"
 	"github.com/tejiriaustin/lema/env"
 	"github.com/tejiriaustin/lema/logger"
-	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/middleware"
+	"github.com/tejiriaustin/lema/migrations"
 	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/secrets"
 	"github.com/tejiriaustin/lema/server"
 	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/sso"
+	"github.com/tejiriaustin/lema/task_manager"
 )
 
 // serverCmd represents the server command
@@ -29,7 +41,8 @@ func init() {
 }
 
 func startApi(cmd *cobra.Command, args []string) {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	lemaLogger, err := logger.NewProductionLogger()
 	if err != nil {
@@ -38,33 +51,95 @@ func startApi(cmd *cobra.Command, args []string) {
 
 	config := setApiEnvironment()
 
-	dbCfg := &database.Config{
-		DB: config.GetAsString(constants.DB),
+	dbCfg, err := newDatabaseConfig(config)
+	if err != nil {
+		lemaLogger.Fatal("Invalid database configuration: %v", logger.WithField("error", err))
+		return
 	}
+	dbCfg.Logger = lemaLogger
+
 	dbConn, err := database.Initialize(dbCfg)
 	if err != nil {
 		lemaLogger.Fatal("Failed to initialize database: %v", logger.WithField("error", err))
 		return
 	}
 
+	migrator, err := migrations.NewMigrator(dbConn)
+	if err != nil {
+		lemaLogger.Fatal("Failed to load migrations: %v", logger.WithField("error", err))
+		return
+	}
+
 	if config.GetAsString(constants.ShouldAutoMigrate) == "true" {
-		tables := []interface{}{
-			models.User{},
-			models.Post{},
-			models.Address{},
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			lemaLogger.Fatal("Failed to migrate database: %v", logger.WithField("error", err))
+			return
 		}
+		for _, migration := range applied {
+			lemaLogger.Info("applied migration", logger.WithField("version", migration.Version), logger.WithField("name", migration.Name))
+		}
+	}
 
-		if err = dbConn.Migrate(tables...); err != nil {
-			lemaLogger.Fatal("Failed to migrate database: %v", logger.WithField("error", err))
+	// Serving against an older schema fails in confusing ways, so refuse to start
+	if err = migrator.CheckCurrent(ctx); err != nil {
+		lemaLogger.Fatal("Refusing to start, run `lema migrate up`: %v", logger.WithField("error", err))
+		return
+	}
+
+	cacheOptions, err := newCacheOptions(ctx, config)
+	if err != nil {
+		lemaLogger.Fatal("Failed to initialize cache: %v", logger.WithField("error", err))
+		return
+	}
+
+	rc := repository.NewRepositoryContainer(lemaLogger, dbConn, append(cacheOptions, repository.WithAuditLog())...)
+
+	mailClient, err := newMailer(config)
+	if err != nil {
+		lemaLogger.Fatal("Failed to initialize mailer: %v", logger.WithField("error", err))
+		return
+	}
+
+	var secretBox *secrets.Box
+	if key := config.GetAsString(constants.TotpEncryptionKey); key != "" {
+		secretBox, err = secrets.NewBox(key)
+		if err != nil {
+			lemaLogger.Fatal("Invalid TOTP encryption key: %v", logger.WithField("error", err))
 			return
 		}
+	} else {
+		lemaLogger.Warn("TOTP_ENCRYPTION_KEY is not set, two-factor authentication is disabled")
 	}
 
-	rc := repository.NewRepositoryContainer(lemaLogger, dbConn)
+	ssoProviders, err := newSSORegistry(config)
+	if err != nil {
+		lemaLogger.Fatal("Invalid OIDC configuration: %v", logger.WithField("error", err))
+		return
+	}
 
-	sc := service.NewService(lemaLogger, &config)
+	sc := service.NewService(lemaLogger, &config, mailClient, secretBox, ssoProviders)
 
-	err = server.Start(ctx, sc, rc, &config)
+	runner, err := newTaskRunner(config, rc, dbConn)
+	if err != nil {
+		lemaLogger.Fatal("Invalid task configuration: %v", logger.WithField("error", err))
+		return
+	}
+	go runner.RunTasks()
+
+	rateLimiter, err := newRateLimiter(ctx, config, lemaLogger)
+	if err != nil {
+		lemaLogger.Fatal("Invalid rate limit configuration: %v", logger.WithField("error", err))
+		return
+	}
+
+	corsConfig, err := newCORSConfig(config)
+	if err != nil {
+		lemaLogger.Fatal("Invalid CORS configuration: %v", logger.WithField("error", err))
+		return
+	}
+
+	err = server.Start(ctx, sc, rc, &config, rateLimiter, corsConfig, lemaLogger)
 	if err != nil {
 		lemaLogger.Fatal("Server shutdown unexpectedly: %v", logger.WithField("error", err))
 		return
@@ -72,14 +147,310 @@ func startApi(cmd *cobra.Command, args []string) {
 }
 
 func setApiEnvironment() env.Environment {
-	staticEnvironment := env.NewEnvironment()
+	staticEnvironment := setDatabaseEnvironment(env.NewEnvironment())
 
 	staticEnvironment.
 		SetEnv(constants.Port, env.GetEnv(constants.Port, "8080")).
-		SetEnv(constants.DB, env.MustGetEnv(constants.DB)).
 		SetEnv(constants.ShouldAutoMigrate, env.MustGetEnv(constants.ShouldAutoMigrate)).
 		SetEnv(constants.JwtSecret, env.MustGetEnv(constants.JwtSecret)).
-		SetEnv(constants.FrontendUrl, env.MustGetEnv(constants.FrontendUrl))
+		SetEnv(constants.FrontendUrl, env.MustGetEnv(constants.FrontendUrl)).
+		SetEnv(constants.TrustedProxies, env.GetEnv(constants.TrustedProxies, "")).
+		SetEnv(constants.CorsAllowedOrigins, env.GetEnv(constants.CorsAllowedOrigins, "")).
+		SetEnv(constants.CorsMaxAge, env.GetEnv(constants.CorsMaxAge, "2h")).
+		SetEnv(constants.MailerDriver, env.GetEnv(constants.MailerDriver, "outbox")).
+		SetEnv(constants.MailFrom, env.GetEnv(constants.MailFrom, "no-reply@lema.local")).
+		SetEnv(constants.MailOutboxDir, env.GetEnv(constants.MailOutboxDir, "outbox")).
+		SetEnv(constants.SmtpHost, env.GetEnv(constants.SmtpHost, "")).
+		SetEnv(constants.SmtpPort, env.GetEnv(constants.SmtpPort, "587")).
+		SetEnv(constants.SmtpUsername, env.GetEnv(constants.SmtpUsername, "")).
+		SetEnv(constants.SmtpPassword, env.GetEnv(constants.SmtpPassword, "")).
+		SetEnv(constants.TotpEncryptionKey, env.GetEnv(constants.TotpEncryptionKey, "")).
+		SetEnv(constants.TotpIssuer, env.GetEnv(constants.TotpIssuer, "Lema")).
+		SetEnv(constants.OidcProviders, env.GetEnv(constants.OidcProviders, "")).
+		SetEnv(constants.RedisDsn, env.GetEnv(constants.RedisDsn, "")).
+		SetEnv(constants.CacheTtl, env.GetEnv(constants.CacheTtl, "1m")).
+		SetEnv(constants.CacheSize, env.GetEnv(constants.CacheSize, "")).
+		SetEnv(constants.SoftDeleteRetention, env.GetEnv(constants.SoftDeleteRetention, "720h")).
+		SetEnv(constants.PurgeInterval, env.GetEnv(constants.PurgeInterval, "1h")).
+		SetEnv(constants.BackupDir, env.GetEnv(constants.BackupDir, "")).
+		SetEnv(constants.BackupInterval, env.GetEnv(constants.BackupInterval, "24h")).
+		SetEnv(constants.BackupKeep, env.GetEnv(constants.BackupKeep, "7")).
+		SetEnv(constants.RateLimitDefault, env.GetEnv(constants.RateLimitDefault, "10/2s")).
+		SetEnv(constants.RateLimitAuth, env.GetEnv(constants.RateLimitAuth, "20/1m")).
+		SetEnv(constants.RateLimitSignup, env.GetEnv(constants.RateLimitSignup, "5/1h")).
+		SetEnv(constants.RateLimitUser, env.GetEnv(constants.RateLimitUser, "120/1m")).
+		SetEnv(constants.RateLimitApiKeys, env.GetEnv(constants.RateLimitApiKeys, "")).
+		SetEnv(constants.RateLimitMaxVisitors, env.GetEnv(constants.RateLimitMaxVisitors, "100000"))
 
 	return staticEnvironment
 }
+
+// setDatabaseEnvironment adds the settings needed to open the database, which
+// every command that touches it shares
+func setDatabaseEnvironment(environment env.Environment) env.Environment {
+	return environment.
+		SetEnv(constants.DB, env.MustGetEnv(constants.DB)).
+		SetEnv(constants.DbMaxOpenConns, env.GetEnv(constants.DbMaxOpenConns, "")).
+		SetEnv(constants.DbMaxIdleConns, env.GetEnv(constants.DbMaxIdleConns, "")).
+		SetEnv(constants.DbConnMaxLifetime, env.GetEnv(constants.DbConnMaxLifetime, "")).
+		SetEnv(constants.DbConnMaxIdleTime, env.GetEnv(constants.DbConnMaxIdleTime, "")).
+		SetEnv(constants.DbReplicas, env.GetEnv(constants.DbReplicas, "")).
+		SetEnv(constants.DbReplicaCheckInterval, env.GetEnv(constants.DbReplicaCheckInterval, "")).
+		SetEnv(constants.DbLogLevel, env.GetEnv(constants.DbLogLevel, "warn")).
+		SetEnv(constants.DbSlowQueryThreshold, env.GetEnv(constants.DbSlowQueryThreshold, "200ms")).
+		SetEnv(constants.DbLogParams, env.GetEnv(constants.DbLogParams, "false")).
+		SetEnv(constants.SqliteJournalMode, env.GetEnv(constants.SqliteJournalMode, "WAL")).
+		SetEnv(constants.SqliteBusyTimeout, env.GetEnv(constants.SqliteBusyTimeout, "5s")).
+		SetEnv(constants.SqliteSynchronous, env.GetEnv(constants.SqliteSynchronous, "NORMAL")).
+		SetEnv(constants.SqliteForeignKeys, env.GetEnv(constants.SqliteForeignKeys, "true"))
+}
+
+// newDatabaseConfig reads the DSN, any pool overrides, the SQLite pragmas and
+// the query logging settings. Unset pool variables keep the defaults of the dialect chosen by
+// the DSN.
+func newDatabaseConfig(config env.Environment) (*database.Config, error) {
+	dbCfg := &database.Config{DB: config.GetAsString(constants.DB)}
+
+	level, err := database.ParseLogLevel(config.GetAsString(constants.DbLogLevel))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", constants.DbLogLevel, err)
+	}
+	dbCfg.Log.Level = level
+	dbCfg.Log.LogParams = config.GetAsString(constants.DbLogParams) == "true"
+
+	dbCfg.SQLite.JournalMode = config.GetAsString(constants.SqliteJournalMode)
+	dbCfg.SQLite.Synchronous = config.GetAsString(constants.SqliteSynchronous)
+	if value := config.GetAsString(constants.SqliteForeignKeys); value != "" {
+		foreignKeys, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", constants.SqliteForeignKeys, err)
+		}
+		dbCfg.SQLite.ForeignKeys = &foreignKeys
+	}
+
+	for _, replica := range strings.Split(config.GetAsString(constants.DbReplicas), ",") {
+		if replica = strings.TrimSpace(replica); replica != "" {
+			dbCfg.Replicas = append(dbCfg.Replicas, replica)
+		}
+	}
+
+	for key, target := range map[string]*int{
+		constants.DbMaxOpenConns: &dbCfg.Pool.MaxOpenConns,
+		constants.DbMaxIdleConns: &dbCfg.Pool.MaxIdleConns,
+	} {
+		if value := config.GetAsString(key); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			*target = parsed
+		}
+	}
+
+	for key, target := range map[string]*time.Duration{
+		constants.DbConnMaxLifetime:      &dbCfg.Pool.ConnMaxLifetime,
+		constants.DbConnMaxIdleTime:      &dbCfg.Pool.ConnMaxIdleTime,
+		constants.DbReplicaCheckInterval: &dbCfg.ReplicaCheckInterval,
+		constants.DbSlowQueryThreshold:   &dbCfg.Log.SlowThreshold,
+		constants.SqliteBusyTimeout:      &dbCfg.SQLite.BusyTimeout,
+	} {
+		if value := config.GetAsString(key); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			*target = parsed
+		}
+	}
+
+	return dbCfg, nil
+}
+
+// newCacheOptions picks the cache backing the repositories: Redis when
+// REDIS_DSN is set, an in-process LRU otherwise, or none when CACHE_TTL is 0
+func newCacheOptions(ctx context.Context, config env.Environment) ([]repository.ContainerOption, error) {
+	ttl, err := time.ParseDuration(config.GetAsString(constants.CacheTtl))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", constants.CacheTtl, err)
+	}
+	if ttl <= 0 {
+		return nil, nil
+	}
+
+	if dsn := config.GetAsString(constants.RedisDsn); dsn != "" {
+		store, err := cache.NewRedisFromURL(ctx, dsn, "lema:cache:")
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to redis: %v", err)
+		}
+		return []repository.ContainerOption{repository.WithCache(store, ttl)}, nil
+	}
+
+	var size int
+	if value := config.GetAsString(constants.CacheSize); value != "" {
+		if size, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("%s: %v", constants.CacheSize, err)
+		}
+	}
+	return []repository.ContainerOption{repository.WithCache(cache.NewLRU(size), ttl)}, nil
+}
+
+// newTaskRunner registers the background jobs. Soft deleted records are purged
+// once they are older than SOFT_DELETE_RETENTION, posts and addresses before
+// the users they belong to, and SQLite databases are backed up to BACKUP_DIR
+// when it is set.
+func newTaskRunner(config env.Environment, rc *repository.Container, dbConn *database.Client) (*task_manager.Run, error) {
+	runner := task_manager.NewRunner(task_manager.WithConfig(&config))
+
+	retention, err := time.ParseDuration(config.GetAsString(constants.SoftDeleteRetention))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", constants.SoftDeleteRetention, err)
+	}
+	interval, err := time.ParseDuration(config.GetAsString(constants.PurgeInterval))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", constants.PurgeInterval, err)
+	}
+
+	if retention > 0 {
+		runner.RegisterJob(task_manager.PurgeDeletedTask, interval, task_manager.PurgeDeleted(retention,
+			task_manager.PurgeTarget{Name: "posts", Purger: rc.PostRepo},
+			task_manager.PurgeTarget{Name: "addresses", Purger: rc.AddressRepo},
+			task_manager.PurgeTarget{Name: "users", Purger: rc.UserRepo},
+		))
+	}
+
+	if dir := config.GetAsString(constants.BackupDir); dir != "" {
+		if dbConn.Dialect != database.DialectSQLite {
+			return nil, fmt.Errorf("%s: %v", constants.BackupDir, database.ErrBackupUnsupported)
+		}
+		backupInterval, err := time.ParseDuration(config.GetAsString(constants.BackupInterval))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", constants.BackupInterval, err)
+		}
+		keep, err := strconv.Atoi(config.GetAsString(constants.BackupKeep))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", constants.BackupKeep, err)
+		}
+		runner.RegisterJob(task_manager.BackupDatabaseTask, backupInterval, task_manager.BackupDatabase(dbConn, dir, keep))
+	}
+	return runner, nil
+}
+
+// newRateLimiter reads the rate limit policies and the API keys that are
+// limited per key. With REDIS_DSN set the limits are shared by every instance
+// through Redis, and each instance limits on its own while Redis is down. Idle
+// clients are forgotten until ctx is done.
+func newRateLimiter(ctx context.Context, config env.Environment, lemaLogger logger.Logger) (*middleware.RateLimiter, error) {
+	var policies middleware.RatePolicies
+	for key, target := range map[string]*middleware.RatePolicy{
+		constants.RateLimitDefault: &policies.Default,
+		constants.RateLimitAuth:    &policies.Auth,
+		constants.RateLimitSignup:  &policies.Signup,
+		constants.RateLimitUser:    &policies.User,
+	} {
+		name := strings.ToLower(strings.TrimPrefix(key, "RATE_LIMIT_"))
+		policy, err := middleware.ParseRatePolicy(name, config.GetAsString(key))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		*target = policy
+	}
+
+	maxVisitors, err := strconv.Atoi(config.GetAsString(constants.RateLimitMaxVisitors))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", constants.RateLimitMaxVisitors, err)
+	}
+	// Forgetting a client any sooner would hand it a full bucket early
+	memoryStore := middleware.NewMemoryRateStore(max(time.Hour, policies.LongestWindow()), maxVisitors)
+	go memoryStore.Janitor(ctx, time.Minute)
+
+	var store middleware.RateStore = memoryStore
+	if dsn := config.GetAsString(constants.RedisDsn); dsn != "" {
+		options, err := redis.ParseURL(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", constants.RedisDsn, err)
+		}
+		// Not pinged: Redis being down at startup is handled like any outage
+		shared := middleware.NewRedisRateStore(redis.NewClient(options), "lema:ratelimit:")
+		store = middleware.NewFallbackRateStore(shared, store, lemaLogger)
+	}
+
+	apiKeys := strings.Split(config.GetAsString(constants.RateLimitApiKeys), ",")
+	return middleware.NewRateLimiter(store, policies, apiKeys...), nil
+}
+
+// newCORSConfig reads the origins allowed to call the API from a browser,
+// CORS_ALLOWED_ORIGINS or else the frontend's
+func newCORSConfig(config env.Environment) (middleware.CORSConfig, error) {
+	var corsConfig middleware.CORSConfig
+
+	key, origins := constants.CorsAllowedOrigins, config.GetAsString(constants.CorsAllowedOrigins)
+	if origins == "" {
+		key, origins = constants.FrontendUrl, config.GetAsString(constants.FrontendUrl)
+	}
+	for _, value := range strings.Split(origins, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		origin, err := middleware.ParseOrigin(value)
+		if err != nil {
+			return corsConfig, fmt.Errorf("%s: %v", key, err)
+		}
+		corsConfig.AllowedOrigins = append(corsConfig.AllowedOrigins, origin)
+	}
+
+	maxAge, err := time.ParseDuration(config.GetAsString(constants.CorsMaxAge))
+	if err != nil {
+		return corsConfig, fmt.Errorf("%s: %v", constants.CorsMaxAge, err)
+	}
+	corsConfig.MaxAge = maxAge
+	return corsConfig, nil
+}
+
+// newMailer picks the mail transport from MAILER_DRIVER: "smtp" relays through
+// the configured server, while "outbox" writes messages to MAIL_OUTBOX_DIR.
+func newMailer(config env.Environment) (mailer.Mailer, error) {
+	switch driver := config.GetAsString(constants.MailerDriver); driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.GetAsString(constants.SmtpHost),
+			Port:     config.GetAsString(constants.SmtpPort),
+			Username: config.GetAsString(constants.SmtpUsername),
+			Password: config.GetAsString(constants.SmtpPassword),
+			From:     config.GetAsString(constants.MailFrom),
+		})
+	case "outbox":
+		return mailer.NewOutboxMailer(config.GetAsString(constants.MailOutboxDir), config.GetAsString(constants.MailFrom))
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %q", driver)
+	}
+}
+
+// newSSORegistry builds an OpenID Connect provider for every name listed in
+// OIDC_PROVIDERS, reading its settings from the OIDC_<NAME>_* variables.
+func newSSORegistry(config env.Environment) (*sso.Registry, error) {
+	var providers []*sso.Provider
+
+	for _, name := range strings.Split(config.GetAsString(constants.OidcProviders), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providerConfig := sso.ProviderConfig{
+			Name:         name,
+			IssuerURL:    env.GetEnv(prefix+"ISSUER_URL", ""),
+			ClientID:     env.GetEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: env.GetEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  env.GetEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:       strings.Fields(strings.ReplaceAll(env.GetEnv(prefix+"SCOPES", ""), ",", " ")),
+		}
+		if providerConfig.IssuerURL == "" || providerConfig.ClientID == "" || providerConfig.RedirectURL == "" {
+			return nil, fmt.Errorf("provider %q needs %sISSUER_URL, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+
+		providers = append(providers, sso.NewProvider(providerConfig, nil))
+	}
+
+	return sso.NewRegistry(providers...), nil
+}
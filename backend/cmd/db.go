@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/env"
+)
+
+// dbCmd groups the commands that back up and restore the database
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Back up and restore the database",
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:          "backup",
+	Short:        "Copy the SQLite database to a file while the API keeps serving",
+	Args:         cobra.NoArgs,
+	RunE:         dbBackup,
+	SilenceUsage: true,
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:          "restore",
+	Short:        "Replace the SQLite database with an integrity checked backup",
+	Args:         cobra.NoArgs,
+	RunE:         dbRestore,
+	SilenceUsage: true,
+}
+
+func init() {
+	dbBackupCmd.Flags().String("out", "", "file to write the backup to; it must not exist")
+	_ = dbBackupCmd.MarkFlagRequired("out")
+	dbRestoreCmd.Flags().String("from", "", "backup file to restore")
+	_ = dbRestoreCmd.MarkFlagRequired("from")
+
+	dbCmd.AddCommand(dbBackupCmd, dbRestoreCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+func openDatabase() (*database.Client, error) {
+	dbCfg, err := newDatabaseConfig(setDatabaseEnvironment(env.NewEnvironment()))
+	if err != nil {
+		return nil, err
+	}
+	return database.Initialize(dbCfg)
+}
+
+func dbBackup(cmd *cobra.Command, args []string) error {
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	dbConn, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	if err := dbConn.Backup(cmd.Context(), out); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "backed up to %s\n", out)
+	return nil
+}
+
+func dbRestore(cmd *cobra.Command, args []string) error {
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+
+	dbConn, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	if err := dbConn.Restore(cmd.Context(), from); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "restored from %s\n", from)
+	return nil
+}
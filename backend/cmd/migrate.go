@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/migrations"
+)
+
+// migrateCmd groups the schema migration commands
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply, revert and inspect database migrations",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:          "up",
+	Short:        "Apply every pending migration",
+	Args:         cobra.NoArgs,
+	RunE:         migrateUp,
+	SilenceUsage: true,
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:          "down",
+	Short:        "Revert the most recent migrations",
+	Args:         cobra.NoArgs,
+	RunE:         migrateDown,
+	SilenceUsage: true,
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:          "status",
+	Short:        "List migrations and whether they are applied",
+	Args:         cobra.NoArgs,
+	RunE:         migrateStatus,
+	SilenceUsage: true,
+}
+
+var migrateCreateCmd = &cobra.Command{
+	Use:          "create NAME",
+	Short:        "Write empty up and down files for a new migration for every dialect",
+	Args:         cobra.ExactArgs(1),
+	RunE:         migrateCreate,
+	SilenceUsage: true,
+}
+
+func init() {
+	migrateDownCmd.Flags().Int("steps", 1, "number of migrations to revert")
+	migrateCreateCmd.Flags().String("dir", "migrations/sql", "directory holding a sub-directory of migrations per dialect")
+
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migrateCreateCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func newMigrator() (*migrations.Migrator, *database.Client, error) {
+	dbCfg, err := newDatabaseConfig(setDatabaseEnvironment(env.NewEnvironment()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbConn, err := database.Initialize(dbCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrator, err := migrations.NewMigrator(dbConn)
+	if err != nil {
+		_ = dbConn.Close()
+		return nil, nil, err
+	}
+	return migrator, dbConn, nil
+}
+
+func migrateUp(cmd *cobra.Command, args []string) error {
+	migrator, dbConn, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	applied, err := migrator.Up(cmd.Context())
+	for _, migration := range applied {
+		fmt.Fprintf(cmd.OutOrStdout(), "applied %d_%s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "database is up to date")
+	}
+	return nil
+}
+
+func migrateDown(cmd *cobra.Command, args []string) error {
+	steps, err := cmd.Flags().GetInt("steps")
+	if err != nil {
+		return err
+	}
+	if steps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+
+	migrator, dbConn, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	reverted, err := migrator.Down(cmd.Context(), steps)
+	for _, migration := range reverted {
+		fmt.Fprintf(cmd.OutOrStdout(), "reverted %d_%s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(reverted) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "no migrations to revert")
+	}
+	return nil
+}
+
+func migrateStatus(cmd *cobra.Command, args []string) error {
+	migrator, dbConn, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	statuses, err := migrator.Status(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied() {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+func migrateCreate(cmd *cobra.Command, args []string) error {
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return err
+	}
+
+	paths, err := migrations.Create(dir, args[0], time.Now())
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fmt.Fprintf(cmd.OutOrStdout(), "created %s\n", path)
+	}
+	return nil
+}
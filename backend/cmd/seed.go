@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/seeder"
+	"github.com/tejiriaustin/lema/service"
+)
+
+// seedCmd fills the database with fake users, addresses and posts
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Populate the database with realistic fake users, addresses and posts",
+	Long: `Seed creates users with addresses and posts through the repository layer.
+The same --seed always produces the same data, so seed an empty database.`,
+	Example:      "  lema seed --users 1000 --posts-per-user 0-20 --seed 42",
+	Args:         cobra.NoArgs,
+	RunE:         seed,
+	SilenceUsage: true,
+}
+
+func init() {
+	seedCmd.Flags().Int("users", 100, "number of users to create")
+	seedCmd.Flags().String("posts-per-user", "0-20", "posts per user, a number or an inclusive range")
+	seedCmd.Flags().Int64("seed", 42, "random seed; 0 picks a random one")
+	seedCmd.Flags().String("password", "", "password given to every user so they can log in (none by default)")
+
+	rootCmd.AddCommand(seedCmd)
+}
+
+func seed(cmd *cobra.Command, args []string) error {
+	users, err := cmd.Flags().GetInt("users")
+	if err != nil {
+		return err
+	}
+	if users < 0 {
+		return fmt.Errorf("--users must not be negative")
+	}
+
+	postRange, err := cmd.Flags().GetString("posts-per-user")
+	if err != nil {
+		return err
+	}
+	minPosts, maxPosts, err := seeder.ParsePostRange(postRange)
+	if err != nil {
+		return err
+	}
+
+	seedValue, err := cmd.Flags().GetInt64("seed")
+	if err != nil {
+		return err
+	}
+
+	password, err := cmd.Flags().GetString("password")
+	if err != nil {
+		return err
+	}
+
+	config := seeder.Config{
+		Users:           users,
+		MinPostsPerUser: minPosts,
+		MaxPostsPerUser: maxPosts,
+		Seed:            seedValue,
+	}
+	if password != "" {
+		// Hashed once, bcrypt per user would dominate the run
+		if config.PasswordHash, err = service.HashPassword(password); err != nil {
+			return err
+		}
+	}
+
+	migrator, dbConn, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	if err := migrator.CheckCurrent(cmd.Context()); err != nil {
+		return fmt.Errorf("run `lema migrate up` first: %w", err)
+	}
+
+	lemaLogger, err := logger.NewProductionLogger()
+	if err != nil {
+		return err
+	}
+
+	result, err := seeder.Seed(cmd.Context(), config, repository.NewRepositoryContainer(lemaLogger, dbConn))
+	if result != nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "created %d users and %d posts\n", result.Users, result.Posts)
+	}
+	return err
+}
@@ -8,4 +8,10 @@ const (
 
 	// ContextKeyPageSize is the key used to set pagination per_page value in context
 	ContextKeyPageSize contextKey = "_ctx.middlewares.key-page-size_"
+
+	// ContextKeyAccountInfo is the key used to set the authenticated account in context
+	ContextKeyAccountInfo contextKey = "x-user-info"
+
+	// ContextKeyRequestID is the key used to set the request's correlation ID in context
+	ContextKeyRequestID contextKey = "x-request-id"
 )
@@ -3,13 +3,131 @@ package env
 const (
 	Port = "PORT"
 
+	// RedisDsn, when set, is a redis:// URL for the cache shared by every
+	// instance; otherwise each process caches in an LRU of CACHE_SIZE entries.
+	// Cached reads are served for up to CACHE_TTL, and 0 turns caching off.
 	RedisDsn = "REDIS_DSN"
 
+	CacheTtl = "CACHE_TTL"
+
+	CacheSize = "CACHE_SIZE"
+
+	// DB is the database DSN. Its scheme selects the dialect: sqlite://,
+	// postgres:// or mysql://. A bare path is opened as a SQLite file.
 	DB = "DB"
 
+	// DbMaxOpenConns, DbMaxIdleConns, DbConnMaxLifetime and DbConnMaxIdleTime
+	// override the dialect's default pool settings when set.
+	DbMaxOpenConns = "DB_MAX_OPEN_CONNS"
+
+	DbMaxIdleConns = "DB_MAX_IDLE_CONNS"
+
+	DbConnMaxLifetime = "DB_CONN_MAX_LIFETIME"
+
+	DbConnMaxIdleTime = "DB_CONN_MAX_IDLE_TIME"
+
+	// DbReplicas is a comma separated list of read replica DSNs. Reads are
+	// spread over the healthy ones, which are checked every
+	// DB_REPLICA_CHECK_INTERVAL.
+	DbReplicas = "DB_REPLICAS"
+
+	DbReplicaCheckInterval = "DB_REPLICA_CHECK_INTERVAL"
+
+	// DbLogLevel is silent, error, warn or info. Statements slower than
+	// DB_SLOW_QUERY_THRESHOLD are logged from warn up, and bind parameters
+	// are only logged when DB_LOG_PARAMS is true.
+	DbLogLevel = "DB_LOG_LEVEL"
+
+	DbSlowQueryThreshold = "DB_SLOW_QUERY_THRESHOLD"
+
+	DbLogParams = "DB_LOG_PARAMS"
+
+	// SqliteJournalMode, SqliteBusyTimeout, SqliteSynchronous and
+	// SqliteForeignKeys set the pragmas of SQLite connections. They are
+	// ignored by the other dialects.
+	SqliteJournalMode = "SQLITE_JOURNAL_MODE"
+
+	SqliteBusyTimeout = "SQLITE_BUSY_TIMEOUT"
+
+	SqliteSynchronous = "SQLITE_SYNCHRONOUS"
+
+	SqliteForeignKeys = "SQLITE_FOREIGN_KEYS"
+
+	// FrontendUrl is where links in emails and sign in redirects point. Unless
+	// CORS_ALLOWED_ORIGINS is set, it is also the only origin browsers may
+	// call the API from.
 	FrontendUrl = "FRONTEND_URL"
 
+	// CorsAllowedOrigins is a comma separated list of origins, such as
+	// https://app.lema.io, or patterns matching subdomains, such as
+	// https://*.lema.io. Browsers cache preflights for CORS_MAX_AGE.
+	CorsAllowedOrigins = "CORS_ALLOWED_ORIGINS"
+
+	CorsMaxAge = "CORS_MAX_AGE"
+
 	ShouldAutoMigrate = "SHOULD_AUTO_MIGRATE"
 
 	JwtSecret = "JWT_SECRET_KEY"
+
+	MailerDriver = "MAILER_DRIVER"
+
+	MailFrom = "MAIL_FROM"
+
+	MailOutboxDir = "MAIL_OUTBOX_DIR"
+
+	SmtpHost = "SMTP_HOST"
+
+	SmtpPort = "SMTP_PORT"
+
+	SmtpUsername = "SMTP_USERNAME"
+
+	SmtpPassword = "SMTP_PASSWORD"
+
+	TotpEncryptionKey = "TOTP_ENCRYPTION_KEY"
+
+	TotpIssuer = "TOTP_ISSUER"
+
+	// OidcProviders is a comma separated list of provider names. Each provider
+	// reads its settings from OIDC_<NAME>_ISSUER_URL, OIDC_<NAME>_CLIENT_ID,
+	// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and OIDC_<NAME>_SCOPES.
+	OidcProviders = "OIDC_PROVIDERS"
+
+	// SoftDeleteRetention is how long soft deleted records are kept before the
+	// purge job, which runs every PURGE_INTERVAL, removes them. 0 keeps them.
+	SoftDeleteRetention = "SOFT_DELETE_RETENTION"
+
+	PurgeInterval = "PURGE_INTERVAL"
+
+	// BackupDir, when set on a SQLite database, turns on a job that writes a
+	// backup there every BACKUP_INTERVAL and keeps the newest BACKUP_KEEP.
+	BackupDir = "BACKUP_DIR"
+
+	BackupInterval = "BACKUP_INTERVAL"
+
+	BackupKeep = "BACKUP_KEEP"
+
+	// TrustedProxies is a comma separated list of the IPs and CIDRs of the
+	// proxies in front of the API. Only they may set X-Forwarded-For, which
+	// otherwise any client could use to pose as another IP. Empty trusts none.
+	TrustedProxies = "TRUSTED_PROXIES"
+
+	// RateLimitDefault, RateLimitAuth, RateLimitSignup and RateLimitUser are
+	// rate limit policies written as <limit>/<window>, such as 10/1m, or off.
+	// The default covers every request by IP, the others apply on top of it
+	// to /auth, to signing up and, per user, to routes needing a sign in.
+	RateLimitDefault = "RATE_LIMIT_DEFAULT"
+
+	RateLimitAuth = "RATE_LIMIT_AUTH"
+
+	RateLimitSignup = "RATE_LIMIT_SIGNUP"
+
+	RateLimitUser = "RATE_LIMIT_USER"
+
+	// RateLimitApiKeys is a comma separated list of API keys. A request sending
+	// one in X-API-Key is limited per key instead of per IP.
+	RateLimitApiKeys = "RATE_LIMIT_API_KEYS"
+
+	// RateLimitMaxVisitors caps the clients counted in process. Past it, the
+	// clients seen least recently are forgotten first.
+	RateLimitMaxVisitors = "RATE_LIMIT_MAX_VISITORS"
 )
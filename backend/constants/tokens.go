@@ -0,0 +1,14 @@
+package env
+
+const (
+	// TokenTypeClaim names the JWT claim telling what a token signed with
+	// JwtSecret is for, so one kind can't be passed off as another
+	TokenTypeClaim = "typ"
+
+	// TokenTypeAccess is the type of the bearer tokens Authorize accepts
+	TokenTypeAccess = "access"
+
+	// TokenTypeSSOState is the type of the login state carried through an
+	// OIDC redirect
+	TokenTypeSSOState = "sso_state"
+)
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/response"
+	"github.com/tejiriaustin/lema/service"
+)
+
+type AdminController struct {
+	conf *env.Environment
+}
+
+func NewAdminController(conf *env.Environment) *AdminController {
+	return &AdminController{
+		conf: conf,
+	}
+}
+
+func (c *AdminController) GetLockStatus(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status, err := authService.GetLockStatus(ctx, ctx.Param("id"), userRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrUserNotFound):
+				response.FormatResponse(ctx, http.StatusNotFound, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to get lock status", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", response.LockStatusResponse(response.LockStatus{
+			UserID:         status.UserID,
+			Locked:         status.Locked,
+			LockedUntil:    status.LockedUntil,
+			FailedAttempts: status.FailedAttempts,
+		}))
+	}
+}
+
+func (c *AdminController) UnlockAccount(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := authService.UnlockAccount(ctx, ctx.Param("id"), userRepo); err != nil {
+			switch {
+			case errors.Is(err, service.ErrUserNotFound):
+				response.FormatResponse(ctx, http.StatusNotFound, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to unlock account", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "account unlocked", nil)
+	}
+}
+
+// DeleteUser removes a user along with their posts and sign-in credentials in one transaction
+func (c *AdminController) DeleteUser(
+	userService service.UserServiceInterface,
+	postService service.PostServiceInterface,
+	authService service.AuthServiceInterface,
+	uow repository.UnitOfWork,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.Param("id")
+
+		err := uow.Transaction(ctx, func(tx *repository.Container) error {
+			if err := postService.DeleteUserPosts(ctx, userID, tx.PostRepo); err != nil {
+				return err
+			}
+			if err := authService.DeleteCredentials(ctx, userID, tx.TokenRepo, tx.RecoveryCodeRepo, tx.IdentityRepo); err != nil {
+				return err
+			}
+			return userService.DeleteUser(ctx, userID, tx.UserRepo, tx.AddressRepo)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrUserNotFound):
+				response.FormatResponse(ctx, http.StatusNotFound, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to delete user", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "user deleted", nil)
+	}
+}
+
+func (c *AdminController) GetAuditLog(
+	auditService service.AuditServiceInterface,
+	auditRepo repository.RepoInterface[models.AuditEntry],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		input := service.GetAuditLogInput{
+			Pager: service.Pager{
+				Page:    service.GetPageNumberFromContext(ctx),
+				PerPage: service.GetPageSizeLimitFromContext(ctx),
+			},
+			Entity:   ctx.Query("entity"),
+			RecordID: ctx.Query("id"),
+		}
+
+		entries, paginate, err := auditService.GetAuditLog(ctx, input, auditRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrAuditEntityRequired), errors.Is(err, repository.ErrColumnNotAllowed):
+				response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to get audit log", nil)
+			}
+			return
+		}
+
+		payload := map[string]interface{}{
+			"paginationData": paginate,
+			"entries":        response.MultipleAuditEntryResponse(entries),
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", payload)
+	}
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/requests"
+	"github.com/tejiriaustin/lema/response"
+	"github.com/tejiriaustin/lema/service"
+)
+
+type AuthController struct {
+	conf *env.Environment
+}
+
+func NewAuthController(conf *env.Environment) *AuthController {
+	return &AuthController{
+		conf: conf,
+	}
+}
+
+func (c *AuthController) Login(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
+	historyRepo *repository.Repository[models.LoginHistory],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req requests.LoginRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		input := service.LoginInput{
+			Email:     req.Email,
+			Password:  req.Password,
+			IPAddress: ctx.ClientIP(),
+			UserAgent: ctx.Request.UserAgent(),
+		}
+
+		result, err := authService.Login(ctx, input, userRepo, tokenRepo, historyRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidCredentials):
+				response.FormatResponse(ctx, http.StatusUnauthorized, err.Error(), nil)
+			case errors.Is(err, service.ErrAccountLocked):
+				response.FormatResponse(ctx, http.StatusLocked, err.Error(), nil)
+			case errors.Is(err, service.ErrTooManyLoginAttempts):
+				ctx.Header("Retry-After", strconv.Itoa(int(service.FailedLoginIPWindow.Seconds())))
+				response.FormatResponse(ctx, http.StatusTooManyRequests, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to log in", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", response.LoginResponse(loginResponse(result)))
+	}
+}
+
+func (c *AuthController) CompleteTwoFactorLogin(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
+	recoveryRepo *repository.Repository[models.RecoveryCode],
+	historyRepo *repository.Repository[models.LoginHistory],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req requests.TwoFactorLoginRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		input := service.TwoFactorLoginInput{
+			ChallengeToken: req.ChallengeToken,
+			Code:           req.Code,
+			IPAddress:      ctx.ClientIP(),
+			UserAgent:      ctx.Request.UserAgent(),
+		}
+
+		result, err := authService.CompleteTwoFactorLogin(ctx, input, userRepo, tokenRepo, recoveryRepo, historyRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrInvalidTwoFactorCode):
+				response.FormatResponse(ctx, http.StatusUnauthorized, err.Error(), nil)
+			case errors.Is(err, service.ErrAccountLocked):
+				response.FormatResponse(ctx, http.StatusLocked, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to log in", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", response.LoginResponse(loginResponse(result)))
+	}
+}
+
+func (c *AuthController) GetLoginHistory(
+	authService service.AuthServiceInterface,
+	historyRepo *repository.Repository[models.LoginHistory],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		account, ok := service.GetAccountInfoFromContext(ctx)
+		if !ok {
+			response.FormatResponse(ctx, http.StatusUnauthorized, "Unauthorized", nil)
+			return
+		}
+
+		input := service.GetLoginHistoryInput{
+			Pager: service.Pager{
+				Page:    service.GetPageNumberFromContext(ctx),
+				PerPage: service.GetPageSizeLimitFromContext(ctx),
+			},
+			UserID: account.Id,
+		}
+
+		history, paginate, err := authService.GetLoginHistory(ctx, input, historyRepo)
+		if err != nil {
+			response.FormatResponse(ctx, http.StatusInternalServerError, "failed to get login history", nil)
+			return
+		}
+
+		payload := map[string]interface{}{
+			"paginationData": paginate,
+			"sessions":       response.MultipleLoginHistoryResponse(history),
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", payload)
+	}
+}
+
+func (c *AuthController) BeginTwoFactorEnrollment(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		account, ok := service.GetAccountInfoFromContext(ctx)
+		if !ok {
+			response.FormatResponse(ctx, http.StatusUnauthorized, "Unauthorized", nil)
+			return
+		}
+
+		enrollment, err := authService.BeginTwoFactorEnrollment(ctx, account.Id, userRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+				response.FormatResponse(ctx, http.StatusConflict, err.Error(), nil)
+			case errors.Is(err, service.ErrTwoFactorUnavailable):
+				response.FormatResponse(ctx, http.StatusServiceUnavailable, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to start two-factor enrollment", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", response.TwoFactorEnrollmentResponse(response.TwoFactorEnrollment{
+			Secret:          enrollment.Secret,
+			ProvisioningURI: enrollment.ProvisioningURI,
+		}))
+	}
+}
+
+func (c *AuthController) ConfirmTwoFactorEnrollment(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	recoveryRepo *repository.Repository[models.RecoveryCode],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		account, ok := service.GetAccountInfoFromContext(ctx)
+		if !ok {
+			response.FormatResponse(ctx, http.StatusUnauthorized, "Unauthorized", nil)
+			return
+		}
+
+		var req requests.TwoFactorCodeRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		recoveryCodes, err := authService.ConfirmTwoFactorEnrollment(ctx, account.Id, req.Code, userRepo, recoveryRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidTwoFactorCode):
+				response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			case errors.Is(err, service.ErrTwoFactorAlreadyEnabled), errors.Is(err, service.ErrTwoFactorNotPending):
+				response.FormatResponse(ctx, http.StatusConflict, err.Error(), nil)
+			case errors.Is(err, service.ErrTwoFactorUnavailable):
+				response.FormatResponse(ctx, http.StatusServiceUnavailable, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to confirm two-factor enrollment", nil)
+			}
+			return
+		}
+
+		payload := map[string]interface{}{
+			"recoveryCodes": recoveryCodes,
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "two-factor authentication enabled", payload)
+	}
+}
+
+func (c *AuthController) VerifyEmail(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req requests.VerifyEmailRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		if err := authService.VerifyEmail(ctx, req.Token, userRepo, tokenRepo); err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidToken):
+				response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to verify email", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "email verified successfully", nil)
+	}
+}
+
+func (c *AuthController) ResendVerification(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req requests.EmailRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		if err := authService.RequestEmailVerification(ctx, req.Email, userRepo, tokenRepo); err != nil {
+			response.FormatResponse(ctx, http.StatusInternalServerError, "failed to send verification email", nil)
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusAccepted, "if the address needs verifying, an email is on its way", nil)
+	}
+}
+
+func (c *AuthController) ForgotPassword(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req requests.EmailRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		if err := authService.RequestPasswordReset(ctx, req.Email, userRepo, tokenRepo); err != nil {
+			response.FormatResponse(ctx, http.StatusInternalServerError, "failed to send password reset email", nil)
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusAccepted, "if the address is registered, a reset link is on its way", nil)
+	}
+}
+
+func (c *AuthController) ResetPassword(
+	authService service.AuthServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req requests.ResetPasswordRequest
+
+		if err := ctx.BindJSON(&req); err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+
+		input := service.ResetPasswordInput{
+			Token:    req.Token,
+			Password: req.Password,
+		}
+
+		if err := authService.ResetPassword(ctx, input, userRepo, tokenRepo); err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidToken):
+				response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to reset password", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "password reset successfully", nil)
+	}
+}
+
+func loginResponse(result *service.LoginResult) response.Login {
+	return response.Login{
+		AccessToken:       result.AccessToken,
+		TwoFactorRequired: result.TwoFactorRequired,
+		ChallengeToken:    result.ChallengeToken,
+		ExpiresAt:         result.ExpiresAt,
+	}
+}
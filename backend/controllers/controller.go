@@ -3,19 +3,28 @@ package controllers
 import (
 	"context"
 	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/logger"
 )
 
 type (
 	Controller struct {
-		conf           *env.Environment
-		UserController *UserController
-		PostController *PostController
+		conf            *env.Environment
+		UserController  *UserController
+		PostController  *PostController
+		AuthController  *AuthController
+		SSOController   *SSOController
+		AdminController *AdminController
+		StatsController *StatsController
 	}
 )
 
-func New(ctx context.Context, conf *env.Environment) *Controller {
+func New(ctx context.Context, conf *env.Environment, lemaLogger logger.Logger) *Controller {
 	return &Controller{
-		UserController: NewUserController(conf),
-		PostController: NewPostController(conf),
+		UserController:  NewUserController(conf, lemaLogger),
+		PostController:  NewPostController(conf),
+		AuthController:  NewAuthController(conf),
+		SSOController:   NewSSOController(conf),
+		AdminController: NewAdminController(conf),
+		StatsController: NewStatsController(conf),
 	}
 }
@@ -26,10 +26,15 @@ func NewPostController(conf *env.Environment) *PostController {
 func (c *PostController) CreatePost(
 	userService service.UserServiceInterface,
 	postService service.PostServiceInterface,
-	userRepo *repository.Repository[models.User],
-	postsRepo *repository.Repository[models.Post],
+	uow repository.UnitOfWork,
 ) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		// Posts are written by the signed in user, whatever the body says
+		account, ok := service.GetAccountInfoFromContext(ctx)
+		if !ok {
+			response.FormatResponse(ctx, http.StatusUnauthorized, "Unauthorized", nil)
+			return
+		}
 
 		var req requests.CreatePostRequest
 
@@ -39,21 +44,37 @@ func (c *PostController) CreatePost(
 			return
 		}
 
-		user, err := userService.GetUserByID(ctx, req.UserID, userRepo)
-		if err != nil || user == nil {
-			response.FormatResponse(ctx, http.StatusBadRequest, "Invalid User ID", nil)
-			return
-		}
-
 		input := service.CreatePostInput{
 			Title:  req.Title,
 			Body:   req.Body,
-			UserID: req.UserID,
+			UserID: account.Id,
 		}
 
-		post, err := postService.CreatePost(ctx, input, postsRepo)
+		// The author's row stays locked until the post is inserted, so the
+		// author can't be deleted in between
+		var post *models.Post
+		err = uow.Transaction(ctx, func(tx *repository.Container) error {
+			user, err := userService.LockUser(ctx, account.Id, tx.UserRepo)
+			if err != nil || user == nil {
+				return service.ErrUserNotFound
+			}
+
+			if !user.IsEmailVerified() {
+				return service.ErrEmailNotVerified
+			}
+
+			post, err = postService.CreatePost(ctx, input, tx.PostRepo)
+			return err
+		})
 		if err != nil {
-			response.FormatResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+			switch {
+			case errors.Is(err, service.ErrUserNotFound):
+				response.FormatResponse(ctx, http.StatusBadRequest, "Invalid User ID", nil)
+			case errors.Is(err, service.ErrEmailNotVerified):
+				response.FormatResponse(ctx, http.StatusForbidden, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+			}
 			return
 		}
 
@@ -64,7 +85,7 @@ func (c *PostController) CreatePost(
 func (c *PostController) GetPosts(
 	userService service.UserServiceInterface,
 	postService service.PostServiceInterface,
-	userRepo *repository.Repository[models.User],
+	userRepo repository.RepoInterface[models.User],
 	postsRepo *repository.Repository[models.Post],
 ) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -80,6 +101,35 @@ func (c *PostController) GetPosts(
 			return
 		}
 
+		// Passing a cursor, even an empty one for the first page, switches to keyset pagination
+		if cursor, ok := ctx.GetQuery("cursor"); ok {
+			input := service.GetUserPostCursorInput{
+				UserID: userID,
+				Cursor: cursor,
+				Limit:  service.GetPageSizeLimitFromContext(ctx),
+			}
+
+			posts, cursorPage, err := postService.GetUserPostsByCursor(ctx, input, postsRepo)
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrInvalidCursor):
+					response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+				default:
+					response.FormatResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+				}
+				return
+			}
+
+			payload := map[string]interface{}{
+				"cursorData": cursorPage,
+				"posts":      response.MultiplePostResponse(posts),
+				"user":       response.SingleUserResponse(user),
+			}
+
+			response.FormatResponse(ctx, http.StatusOK, "successful", payload)
+			return
+		}
+
 		input := service.GetUserPostInput{
 			UserID: userID,
 			Pager: service.Pager{
@@ -104,6 +154,50 @@ func (c *PostController) GetPosts(
 	}
 }
 
+// GetFeed pages through the posts of every user, newest first, each with its
+// author. The authors are loaded in one query rather than one per post.
+func (c *PostController) GetFeed(
+	userService service.UserServiceInterface,
+	postService service.PostServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	postsRepo *repository.Repository[models.Post],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		input := service.GetFeedInput{
+			Cursor: ctx.Query("cursor"),
+			Limit:  service.GetPageSizeLimitFromContext(ctx),
+		}
+
+		posts, cursorPage, err := postService.GetFeed(ctx, input, postsRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrInvalidCursor):
+				response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, err.Error(), nil)
+			}
+			return
+		}
+
+		authorIDs := make([]string, 0, len(posts))
+		for _, post := range posts {
+			authorIDs = append(authorIDs, post.UserID)
+		}
+		authors, err := userService.GetUsersByIDs(ctx, authorIDs, userRepo)
+		if err != nil {
+			response.FormatResponse(ctx, http.StatusInternalServerError, "failed to load authors", nil)
+			return
+		}
+
+		payload := map[string]interface{}{
+			"cursorData": cursorPage,
+			"posts":      response.FeedPostResponse(posts, authors),
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", payload)
+	}
+}
+
 func (c *PostController) DeletePost(
 	postService service.PostServiceInterface,
 	postsRepo repository.RepoInterface[models.Post],
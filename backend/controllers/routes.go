@@ -7,6 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/middleware"
+	"github.com/tejiriaustin/lema/models"
 	"github.com/tejiriaustin/lema/repository"
 	"github.com/tejiriaustin/lema/response"
 	"github.com/tejiriaustin/lema/service"
@@ -18,9 +21,25 @@ func BindRoutes(
 	sc *service.Container, // sc stands for Service Container
 	repo *repository.Container,
 	conf *env.Environment,
+	rateLimiter *middleware.RateLimiter,
+	lemaLogger logger.Logger,
 ) {
 
-	controllers := New(ctx, conf)
+	controllers := New(ctx, conf, lemaLogger)
+
+	limits := rateLimiter.Policies
+
+	auth := routerEngine.Group("/auth", rateLimiter.RateLimit(limits.Auth))
+	{
+		auth.POST("/login", controllers.AuthController.Login(sc.AuthService, repo.AccountRepo, repo.TokenRepo, repo.LoginHistoryRepo))                                             // POST /auth/login
+		auth.POST("/login/2fa", controllers.AuthController.CompleteTwoFactorLogin(sc.AuthService, repo.AccountRepo, repo.TokenRepo, repo.RecoveryCodeRepo, repo.LoginHistoryRepo)) // POST /auth/login/2fa
+		auth.POST("/verify-email", controllers.AuthController.VerifyEmail(sc.AuthService, repo.AccountRepo, repo.TokenRepo))                                                       // POST /auth/verify-email
+		auth.POST("/verify-email/resend", controllers.AuthController.ResendVerification(sc.AuthService, repo.AccountRepo, repo.TokenRepo))                                         // POST /auth/verify-email/resend
+		auth.POST("/forgot-password", controllers.AuthController.ForgotPassword(sc.AuthService, repo.AccountRepo, repo.TokenRepo))                                                 // POST /auth/forgot-password
+		auth.POST("/reset-password", controllers.AuthController.ResetPassword(sc.AuthService, repo.AccountRepo, repo.TokenRepo))                                                   // POST /auth/reset-password
+		auth.GET("/oidc/:provider/login", controllers.SSOController.Login(sc.SSOService))                                                                                          // GET /auth/oidc/{provider}/login
+		auth.GET("/oidc/:provider/callback", controllers.SSOController.Callback(sc.SSOService, repo.AccountRepo, repo.IdentityRepo, repo.TokenRepo, repo.LoginHistoryRepo))        // GET /auth/oidc/{provider}/callback
+	}
 
 	r := routerEngine.Group("/v1")
 
@@ -28,18 +47,43 @@ func BindRoutes(
 		response.FormatResponse(c, http.StatusOK, "OK", nil)
 	})
 
+	me := r.Group("/me", middleware.Authorize(conf), rateLimiter.RateLimit(limits.User))
+	{
+		me.POST("/2fa/enroll", controllers.AuthController.BeginTwoFactorEnrollment(sc.AuthService, repo.AccountRepo))                           // POST /api/v1/me/2fa/enroll
+		me.POST("/2fa/confirm", controllers.AuthController.ConfirmTwoFactorEnrollment(sc.AuthService, repo.AccountRepo, repo.RecoveryCodeRepo)) // POST /api/v1/me/2fa/confirm
+		me.GET("/sessions", controllers.AuthController.GetLoginHistory(sc.AuthService, repo.LoginHistoryRepo))                                  // GET /api/v1/me/sessions?pageNumber=1&pageSize=10
+	}
+
+	admin := r.Group("/admin", middleware.Authorize(conf), rateLimiter.RateLimit(limits.User), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users/:id/lock", controllers.AdminController.GetLockStatus(sc.AuthService, repo.AccountRepo))                // GET /api/v1/admin/users/{id}/lock
+		admin.DELETE("/users/:id/lock", controllers.AdminController.UnlockAccount(sc.AuthService, repo.AccountRepo))             // DELETE /api/v1/admin/users/{id}/lock
+		admin.DELETE("/users/:id", controllers.AdminController.DeleteUser(sc.UserService, sc.PostService, sc.AuthService, repo)) // DELETE /api/v1/admin/users/{id}
+	}
+
+	audit := r.Group("/audit", middleware.Authorize(conf), rateLimiter.RateLimit(limits.User), middleware.RequireRole(models.RoleAdmin))
+	{
+		audit.GET("", controllers.AdminController.GetAuditLog(sc.AuditService, repo.AuditRepo)) // GET /api/v1/audit?entity=users&id={id}
+	}
+
+	stats := r.Group("/stats", middleware.Authorize(conf), rateLimiter.RateLimit(limits.User), middleware.RequireRole(models.RoleAdmin))
+	{
+		stats.GET("/overview", controllers.StatsController.GetOverview(sc.StatsService, repo.UserRepo, repo.PostRepo)) // GET /api/v1/stats/overview?from=2024-01-01&to=2024-01-31&interval=week&top=10
+	}
+
 	users := r.Group("/users")
 	{
-		users.POST("", controllers.UserController.CreateUser(sc.UserService, repo.UserRepo))         // POST /api/v1/users
-		users.GET("/:id", controllers.UserController.GetUser(sc.UserService, repo.UserRepo))         // GET /api/v1/users/{id}
-		users.GET("", controllers.UserController.GetUsers(sc.UserService, repo.UserRepo))            // GET /api/v1/users?pageNumber=0&pageSize=10
-		users.GET("/count", controllers.UserController.GetUsersCount(sc.UserService, repo.UserRepo)) // GET /api/v1/users/count
+		users.POST("", rateLimiter.RateLimit(limits.Signup), controllers.UserController.CreateUser(sc.UserService, sc.AuthService, repo.UserRepo, repo.TokenRepo)) // POST /api/v1/users
+		users.GET("/:id", controllers.UserController.GetUser(sc.UserService, repo.UserRepo))                                                                       // GET /api/v1/users/{id}
+		users.GET("", controllers.UserController.GetUsers(sc.UserService, repo.UserRepo))                                                                          // GET /api/v1/users?pageNumber=0&pageSize=10
+		users.GET("/count", controllers.UserController.GetUsersCount(sc.UserService, repo.UserRepo))                                                               // GET /api/v1/users/count
 	}
 
 	posts := r.Group("/posts")
 	{
-		posts.POST("", controllers.PostController.CreatePost(sc.UserService, sc.PostService, repo.UserRepo, repo.PostRepo)) // POST /api/v1/posts
-		posts.GET("", controllers.PostController.GetPosts(sc.UserService, sc.PostService, repo.UserRepo, repo.PostRepo))    // GET /api/v1/posts?userId=1
-		posts.DELETE("/:id", controllers.PostController.DeletePost(sc.PostService, repo.PostRepo))                          // DELETE /api/v1/posts/:id
+		posts.POST("", middleware.Authorize(conf), rateLimiter.RateLimit(limits.User), controllers.PostController.CreatePost(sc.UserService, sc.PostService, repo)) // POST /api/v1/posts
+		posts.GET("", controllers.PostController.GetPosts(sc.UserService, sc.PostService, repo.UserRepo, repo.PostRepo))                                            // GET /api/v1/posts?user_id=1 (add &cursor= for keyset pagination)
+		posts.GET("/feed", controllers.PostController.GetFeed(sc.UserService, sc.PostService, repo.UserRepo, repo.PostRepo))                                        // GET /api/v1/posts/feed?cursor= (every user's posts, newest first)
+		posts.DELETE("/:id", controllers.PostController.DeletePost(sc.PostService, repo.PostRepo))                                                                  // DELETE /api/v1/posts/:id
 	}
 }
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/response"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/sso"
+)
+
+const ssoStateCookie = "lema_sso_state"
+
+type SSOController struct {
+	conf *env.Environment
+}
+
+func NewSSOController(conf *env.Environment) *SSOController {
+	return &SSOController{
+		conf: conf,
+	}
+}
+
+func (c *SSOController) Login(
+	ssoService service.SSOServiceInterface,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		redirect, err := ssoService.BeginLogin(ctx, ctx.Param("provider"))
+		if err != nil {
+			switch {
+			case errors.Is(err, sso.ErrUnknownProvider):
+				response.FormatResponse(ctx, http.StatusNotFound, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusBadGateway, "identity provider is unavailable", nil)
+			}
+			return
+		}
+
+		ctx.SetSameSite(http.SameSiteLaxMode)
+		ctx.SetCookie(ssoStateCookie, redirect.State, int(service.SSOLoginStateTTL.Seconds()), "/auth/oidc", "", ctx.Request.TLS != nil, true)
+		ctx.Redirect(http.StatusFound, redirect.URL)
+	}
+}
+
+// Callback finishes the login and hands the result to the frontend in the URL
+// fragment, which browsers never send back to a server.
+func (c *SSOController) Callback(
+	ssoService service.SSOServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	identityRepo *repository.Repository[models.UserIdentity],
+	tokenRepo *repository.Repository[models.UserToken],
+	historyRepo *repository.Repository[models.LoginHistory],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginState, _ := ctx.Cookie(ssoStateCookie)
+		ctx.SetCookie(ssoStateCookie, "", -1, "/auth/oidc", "", ctx.Request.TLS != nil, true)
+
+		if providerErr := ctx.Query("error"); providerErr != "" {
+			c.redirectToFrontend(ctx, url.Values{"error": {providerErr}})
+			return
+		}
+
+		input := service.SSOCallbackInput{
+			Provider:   ctx.Param("provider"),
+			Code:       ctx.Query("code"),
+			State:      ctx.Query("state"),
+			LoginState: loginState,
+			IPAddress:  ctx.ClientIP(),
+			UserAgent:  ctx.Request.UserAgent(),
+		}
+
+		result, err := ssoService.CompleteLogin(ctx, input, userRepo, identityRepo, tokenRepo, historyRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, sso.ErrUnknownProvider),
+				errors.Is(err, service.ErrInvalidSSOState),
+				errors.Is(err, service.ErrSSOEmailNotVerified),
+				errors.Is(err, service.ErrSSOAccountNotFound):
+				c.redirectToFrontend(ctx, url.Values{"error": {err.Error()}})
+			default:
+				c.redirectToFrontend(ctx, url.Values{"error": {"sso login failed"}})
+			}
+			return
+		}
+
+		fragment := url.Values{"expires_at": {strconv.FormatInt(result.ExpiresAt.Unix(), 10)}}
+		if result.TwoFactorRequired {
+			fragment.Set("challenge_token", result.ChallengeToken)
+		} else {
+			fragment.Set("access_token", result.AccessToken)
+			fragment.Set("token_type", "Bearer")
+		}
+		c.redirectToFrontend(ctx, fragment)
+	}
+}
+
+func (c *SSOController) redirectToFrontend(ctx *gin.Context, fragment url.Values) {
+	target := strings.TrimRight(c.conf.GetAsString(constants.FrontendUrl), "/") + "/sso/callback#" + fragment.Encode()
+	ctx.Redirect(http.StatusFound, target)
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/response"
+	"github.com/tejiriaustin/lema/service"
+)
+
+const (
+	defaultStatsDays        = 30
+	defaultTopPosters       = 10
+	maxTopPosters           = 100
+	statsDateFormat         = "2006-01-02"
+	statsQueryParamFrom     = "from"
+	statsQueryParamTo       = "to"
+	statsQueryParamTop      = "top"
+	statsQueryParamInterval = "interval"
+)
+
+type StatsController struct {
+	conf *env.Environment
+}
+
+func NewStatsController(conf *env.Environment) *StatsController {
+	return &StatsController{
+		conf: conf,
+	}
+}
+
+// GetOverview reports totals, signups, posts per day and the top posters.
+// from and to are dates or RFC 3339 times; a to date includes that whole day.
+// Without them the overview covers the last 30 days up to the end of today.
+func (c *StatsController) GetOverview(
+	statsService service.StatsServiceInterface,
+	userRepo repository.RepoInterface[models.User],
+	postRepo repository.RepoInterface[models.Post],
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		input, err := statsOverviewInput(ctx)
+		if err != nil {
+			response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+
+		overview, err := statsService.GetOverview(ctx, input, userRepo, postRepo)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidStatsRange), errors.Is(err, service.ErrInvalidStatsInterval):
+				response.FormatResponse(ctx, http.StatusBadRequest, err.Error(), nil)
+			default:
+				response.FormatResponse(ctx, http.StatusInternalServerError, "failed to get stats", nil)
+			}
+			return
+		}
+
+		response.FormatResponse(ctx, http.StatusOK, "successful", response.StatsOverviewResponse(statsOverviewResponse(overview)))
+	}
+}
+
+func statsOverviewInput(ctx *gin.Context) (service.StatsOverviewInput, error) {
+	input := service.StatsOverviewInput{
+		Interval:   repository.TimeBucket(ctx.Query(statsQueryParamInterval)),
+		TopPosters: defaultTopPosters,
+	}
+
+	var err error
+	if value := ctx.Query(statsQueryParamTo); value != "" {
+		if input.To, err = parseStatsTime(value, true); err != nil {
+			return input, fmt.Errorf("invalid %s: %v", statsQueryParamTo, err)
+		}
+	} else {
+		input.To = repository.Day.Next(repository.Day.Truncate(time.Now()))
+	}
+
+	if value := ctx.Query(statsQueryParamFrom); value != "" {
+		if input.From, err = parseStatsTime(value, false); err != nil {
+			return input, fmt.Errorf("invalid %s: %v", statsQueryParamFrom, err)
+		}
+	} else {
+		input.From = input.To.AddDate(0, 0, -defaultStatsDays)
+	}
+
+	if value := ctx.Query(statsQueryParamTop); value != "" {
+		top, err := strconv.Atoi(value)
+		if err != nil || top < 0 || top > maxTopPosters {
+			return input, fmt.Errorf("%s must be between 0 and %d", statsQueryParamTop, maxTopPosters)
+		}
+		input.TopPosters = top
+	}
+
+	return input, nil
+}
+
+// parseStatsTime reads a date, taken as UTC midnight, or an RFC 3339 time.
+// An end date moves to the following midnight so the day is included.
+func parseStatsTime(value string, end bool) (time.Time, error) {
+	if date, err := time.Parse(statsDateFormat, value); err == nil {
+		if end {
+			return repository.Day.Next(date), nil
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func statsOverviewResponse(overview *service.StatsOverview) response.StatsOverview {
+	topPosters := make([]response.TopPoster, 0, len(overview.TopPosters))
+	for _, poster := range overview.TopPosters {
+		topPosters = append(topPosters, response.TopPoster{User: poster.User, Posts: poster.Posts})
+	}
+
+	return response.StatsOverview{
+		From:        overview.From,
+		To:          overview.To,
+		Interval:    overview.Interval,
+		TotalUsers:  overview.TotalUsers,
+		TotalPosts:  overview.TotalPosts,
+		NewUsers:    overview.NewUsers,
+		NewPosts:    overview.NewPosts,
+		Signups:     overview.Signups,
+		PostsPerDay: overview.PostsPerDay,
+		TopPosters:  topPosters,
+	}
+}
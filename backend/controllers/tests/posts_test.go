@@ -8,11 +8,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 
+	constants "github.com/tejiriaustin/lema/constants"
 	"github.com/tejiriaustin/lema/controllers"
 	"github.com/tejiriaustin/lema/env"
 	"github.com/tejiriaustin/lema/models"
@@ -20,6 +22,7 @@ import (
 	"github.com/tejiriaustin/lema/requests"
 	"github.com/tejiriaustin/lema/service"
 	"github.com/tejiriaustin/lema/testutils"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
 	servicemocks "github.com/tejiriaustin/lema/testutils/mocks/service"
 )
 
@@ -38,43 +41,52 @@ func TestPostController(t *testing.T) {
 	})
 }
 
-func (suite *PostControllerTestSuite) setupTest() (*gin.Engine, *servicemocks.UserServiceInterface, *servicemocks.PostServiceInterface, *repository.Repository[models.User], *repository.Repository[models.Post]) {
+func (suite *PostControllerTestSuite) setupTest() (*gin.Engine, *servicemocks.UserServiceInterface, *servicemocks.PostServiceInterface, *repomocks.UnitOfWork) {
 	mockUserSvc := new(servicemocks.UserServiceInterface)
 	mockPostSvc := new(servicemocks.PostServiceInterface)
-	userRepo := &repository.Repository[models.User]{}
-	postsRepo := &repository.Repository[models.Post]{}
+
+	// The services are mocked, so the transaction only needs to run the callback
+	mockUow := new(repomocks.UnitOfWork)
+	mockUow.On("Transaction", mock.Anything, mock.Anything).Return(
+		func(_ context.Context, fn func(*repository.Container) error) error {
+			return fn(&repository.Container{})
+		})
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
-	return router, mockUserSvc, mockPostSvc, userRepo, postsRepo
+	return router, mockUserSvc, mockPostSvc, mockUow
 }
 
 func (suite *PostControllerTestSuite) TestCreatePost() {
 	suite.NotPanics(func() {
 		type testCase struct {
 			name         string
+			accountID    string
 			input        requests.CreatePostRequest
 			setupMocks   func(*servicemocks.UserServiceInterface, *servicemocks.PostServiceInterface)
 			expectedCode int
 			expectedMsg  string
 		}
 
+		verifiedAt := time.Now()
+
 		testCases := []testCase{
 			{
-				name: "successfully create post",
+				name:      "successfully create post",
+				accountID: "user123",
 				input: requests.CreatePostRequest{
-					Title:  "Test Post",
-					Body:   "Test Body",
-					UserID: "user123",
+					Title: "Test Post",
+					Body:  "Test Body",
 				},
 				setupMocks: func(userSvc *servicemocks.UserServiceInterface, postSvc *servicemocks.PostServiceInterface) {
-					userSvc.On("GetUserByID",
+					userSvc.On("LockUser",
 						mock.Anything,
 						"user123",
 						mock.Anything,
 					).Return(&models.User{
-						Name: "Test User",
+						Name:            "Test User",
+						EmailVerifiedAt: &verifiedAt,
 					}, nil)
 
 					postSvc.On("CreatePost",
@@ -95,37 +107,57 @@ func (suite *PostControllerTestSuite) TestCreatePost() {
 				expectedMsg:  "successful",
 			},
 			{
-				name: "invalid user ID",
+				name:      "invalid user ID",
+				accountID: "invalid_user",
 				input: requests.CreatePostRequest{
-					Title:  "Test Post",
-					Body:   "Test Body",
-					UserID: "invalid_user",
+					Title: "Test Post",
+					Body:  "Test Body",
 				},
 				setupMocks: func(userSvc *servicemocks.UserServiceInterface, postSvc *servicemocks.PostServiceInterface) {
-					userSvc.On("GetUserByID",
+					userSvc.On("LockUser",
 						mock.Anything,
 						"invalid_user",
 						mock.Anything,
-					).Return(nil, errors.New("user not found"))
+					).Return(nil, service.ErrUserNotFound)
 				},
 				expectedCode: http.StatusBadRequest,
 				expectedMsg:  "Invalid User ID",
 			},
 			{
-				name: "error creating post",
+				name:      "unverified user cannot create post",
+				accountID: "unverified_user",
 				input: requests.CreatePostRequest{
-					Title:  "Test Post",
-					Body:   "Test Body",
-					UserID: "user123",
+					Title: "Test Post",
+					Body:  "Test Body",
 				},
 				setupMocks: func(userSvc *servicemocks.UserServiceInterface, postSvc *servicemocks.PostServiceInterface) {
-					userSvc.On("GetUserByID",
+					userSvc.On("LockUser",
 						mock.Anything,
-						"user123",
+						"unverified_user",
 						mock.Anything,
 					).Return(&models.User{
 						Name: "Test User",
 					}, nil)
+				},
+				expectedCode: http.StatusForbidden,
+				expectedMsg:  "email address has not been verified",
+			},
+			{
+				name:      "error creating post",
+				accountID: "user123",
+				input: requests.CreatePostRequest{
+					Title: "Test Post",
+					Body:  "Test Body",
+				},
+				setupMocks: func(userSvc *servicemocks.UserServiceInterface, postSvc *servicemocks.PostServiceInterface) {
+					userSvc.On("LockUser",
+						mock.Anything,
+						"user123",
+						mock.Anything,
+					).Return(&models.User{
+						Name:            "Test User",
+						EmailVerifiedAt: &verifiedAt,
+					}, nil)
 
 					postSvc.On("CreatePost",
 						mock.Anything,
@@ -140,19 +172,32 @@ func (suite *PostControllerTestSuite) TestCreatePost() {
 				expectedCode: http.StatusInternalServerError,
 				expectedMsg:  "failed to create post",
 			},
+			{
+				name: "signed out user cannot create post",
+				input: requests.CreatePostRequest{
+					Title: "Test Post",
+					Body:  "Test Body",
+				},
+				setupMocks:   func(*servicemocks.UserServiceInterface, *servicemocks.PostServiceInterface) {},
+				expectedCode: http.StatusUnauthorized,
+				expectedMsg:  "Unauthorized",
+			},
 		}
 
 		for _, tc := range testCases {
 			suite.Run(tc.name, func() {
 				// Setup fresh instances for each test case
-				router, mockUserSvc, mockPostSvc, userRepo, postsRepo := suite.setupTest()
+				router, mockUserSvc, mockPostSvc, mockUow := suite.setupTest()
 
 				// Setup the route for this test case
-				router.POST("/posts", suite.controller.CreatePost(
+				router.POST("/posts", func(c *gin.Context) {
+					if tc.accountID != "" {
+						c.Set(string(constants.ContextKeyAccountInfo), models.AccountInfo{Id: tc.accountID})
+					}
+				}, suite.controller.CreatePost(
 					mockUserSvc,
 					mockPostSvc,
-					userRepo,
-					postsRepo,
+					mockUow,
 				))
 
 				// Setup mocks
@@ -187,3 +232,67 @@ func (suite *PostControllerTestSuite) TestCreatePost() {
 		}
 	})
 }
+
+func (suite *PostControllerTestSuite) TestGetFeed() {
+	_, mockUserSvc, mockPostSvc, _ := suite.setupTest()
+	mockUserRepo := new(repomocks.RepoInterface[models.User])
+
+	posts := []*models.Post{
+		{Shared: models.Shared{ID: "p1"}, UserID: "alice", Title: "First"},
+		{Shared: models.Shared{ID: "p2"}, UserID: "bob", Title: "Second"},
+		{Shared: models.Shared{ID: "p3"}, UserID: "alice", Title: "Third"},
+		{Shared: models.Shared{ID: "p4"}, UserID: "deleted", Title: "Orphan"},
+	}
+	mockPostSvc.On("GetFeed", mock.Anything, service.GetFeedInput{Cursor: "", Limit: 10}, mock.Anything).
+		Return(posts, &repository.CursorPage{Limit: 10}, nil)
+	mockUserSvc.On("GetUsersByIDs", mock.Anything, []string{"alice", "bob", "alice", "deleted"}, mockUserRepo).
+		Return(map[string]*models.User{
+			"alice": {Shared: models.Shared{ID: "alice"}, Name: "Alice"},
+			"bob":   {Shared: models.Shared{ID: "bob"}, Name: "Bob"},
+		}, nil).Once()
+
+	router := gin.New()
+	router.GET("/posts/feed", suite.controller.GetFeed(mockUserSvc, mockPostSvc, mockUserRepo, &repository.Repository[models.Post]{}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/posts/feed", nil)
+	router.ServeHTTP(w, req)
+	suite.Equal(http.StatusOK, w.Code)
+
+	var body struct {
+		Body struct {
+			Posts []struct {
+				ID     string  `json:"id"`
+				Body   *string `json:"body"`
+				Author *struct {
+					FullName string `json:"fullName"`
+				} `json:"author"`
+			} `json:"posts"`
+		} `json:"body"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &body))
+	suite.Require().Len(body.Body.Posts, 4)
+	suite.Equal("Alice", body.Body.Posts[0].Author.FullName)
+	suite.Equal("Bob", body.Body.Posts[1].Author.FullName)
+	suite.Equal("Alice", body.Body.Posts[2].Author.FullName)
+	suite.Nil(body.Body.Posts[3].Author, "a missing author is left out")
+	suite.Nil(body.Body.Posts[0].Body, "the feed lists titles only")
+
+	mockPostSvc.AssertExpectations(suite.T())
+	mockUserSvc.AssertExpectations(suite.T())
+}
+
+func (suite *PostControllerTestSuite) TestGetPostsRequiresUserID() {
+	_, mockUserSvc, mockPostSvc, _ := suite.setupTest()
+
+	router := gin.New()
+	router.GET("/posts", suite.controller.GetPosts(mockUserSvc, mockPostSvc, nil, &repository.Repository[models.Post]{}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/posts", nil)
+	router.ServeHTTP(w, req)
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	mockUserSvc.AssertNotCalled(suite.T(), "GetUserByID", mock.Anything, mock.Anything, mock.Anything)
+	mockPostSvc.AssertNotCalled(suite.T(), "GetFeed", mock.Anything, mock.Anything, mock.Anything)
+}
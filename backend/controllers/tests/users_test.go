@@ -20,6 +20,7 @@ import (
 	"github.com/tejiriaustin/lema/requests"
 	"github.com/tejiriaustin/lema/service"
 	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
 	servicemocks "github.com/tejiriaustin/lema/testutils/mocks/service"
 )
 
@@ -33,19 +34,21 @@ func TestUserController(t *testing.T) {
 	conf := &env.Environment{}
 	suite.Run(t, &UserControllerTestSuite{
 		BaseSuite:  testutils.BaseSuite{},
-		controller: controllers.NewUserController(conf),
+		controller: controllers.NewUserController(conf, new(loggermocks.Logger)),
 		conf:       conf,
 	})
 }
 
-func (suite *UserControllerTestSuite) setupTest() (*gin.Engine, *servicemocks.UserServiceInterface, *repository.Repository[models.User]) {
+func (suite *UserControllerTestSuite) setupTest() (*gin.Engine, *servicemocks.UserServiceInterface, *servicemocks.AuthServiceInterface, *repository.Repository[models.User], *repository.Repository[models.UserToken]) {
 	mockUserSvc := new(servicemocks.UserServiceInterface)
+	mockAuthSvc := new(servicemocks.AuthServiceInterface)
 	UsersRepo := &repository.Repository[models.User]{}
+	tokenRepo := &repository.Repository[models.UserToken]{}
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
-	return router, mockUserSvc, UsersRepo
+	return router, mockUserSvc, mockAuthSvc, UsersRepo, tokenRepo
 }
 
 func (suite *UserControllerTestSuite) TestCreateUser() {
@@ -53,7 +56,7 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 		type testCase struct {
 			name         string
 			input        requests.CreateUserRequest
-			setupMocks   func(*servicemocks.UserServiceInterface)
+			setupMocks   func(*servicemocks.UserServiceInterface, *servicemocks.AuthServiceInterface)
 			expectedCode int
 			expectedMsg  string
 		}
@@ -73,7 +76,7 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 					Email:    "test@example.com",
 					Address:  address,
 				},
-				setupMocks: func(userSvc *servicemocks.UserServiceInterface) {
+				setupMocks: func(userSvc *servicemocks.UserServiceInterface, authSvc *servicemocks.AuthServiceInterface) {
 					userSvc.On("CreateUser",
 						mock.Anything,
 						service.CreateUserInput{
@@ -87,6 +90,14 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 						Email:   "test@example.com",
 						Address: &address,
 					}, nil)
+
+					authSvc.On("SendEmailVerification",
+						mock.Anything,
+						mock.MatchedBy(func(u *models.User) bool {
+							return u.Email == "test@example.com"
+						}),
+						mock.Anything,
+					).Return(nil)
 				},
 				expectedCode: http.StatusOK,
 				expectedMsg:  "successful",
@@ -98,7 +109,7 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 					Email:    "existing@example.com",
 					Address:  address,
 				},
-				setupMocks: func(userSvc *servicemocks.UserServiceInterface) {
+				setupMocks: func(userSvc *servicemocks.UserServiceInterface, authSvc *servicemocks.AuthServiceInterface) {
 					userSvc.On("CreateUser",
 						mock.Anything,
 						service.CreateUserInput{
@@ -119,7 +130,7 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 					Email:    "test@example.com",
 					Address:  address,
 				},
-				setupMocks: func(userSvc *servicemocks.UserServiceInterface) {
+				setupMocks: func(userSvc *servicemocks.UserServiceInterface, authSvc *servicemocks.AuthServiceInterface) {
 					userSvc.On("CreateUser",
 						mock.Anything,
 						service.CreateUserInput{
@@ -137,14 +148,16 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 
 		for _, tc := range testCases {
 			suite.Run(tc.name, func() {
-				router, mockUserSvc, usersRepo := suite.setupTest()
+				router, mockUserSvc, mockAuthSvc, usersRepo, tokenRepo := suite.setupTest()
 
 				router.POST("/users", suite.controller.CreateUser(
 					mockUserSvc,
+					mockAuthSvc,
 					usersRepo,
+					tokenRepo,
 				))
 
-				tc.setupMocks(mockUserSvc)
+				tc.setupMocks(mockUserSvc, mockAuthSvc)
 
 				body, _ := json.Marshal(tc.input)
 				req, _ := http.NewRequestWithContext(
@@ -166,7 +179,34 @@ func (suite *UserControllerTestSuite) TestCreateUser() {
 				suite.Equal(tc.expectedMsg, response["message"])
 
 				mockUserSvc.AssertExpectations(suite.T())
+				mockAuthSvc.AssertExpectations(suite.T())
 			})
 		}
 	})
 }
+
+func (suite *UserControllerTestSuite) TestCreateUserLogsFailedVerificationEmail() {
+	router, mockUserSvc, mockAuthSvc, usersRepo, tokenRepo := suite.setupTest()
+
+	mockLogger := new(loggermocks.Logger)
+	mockLogger.On("Error", "failed to send verification email to new user", mock.Anything, mock.Anything).Return().Once()
+
+	mockUserSvc.On("CreateUser", mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.User{Shared: models.Shared{ID: "user-1"}, Email: "test@example.com"}, nil)
+	mockAuthSvc.On("SendEmailVerification", mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("smtp unavailable"))
+
+	controller := controllers.NewUserController(suite.conf, mockLogger)
+	router.POST("/users", controller.CreateUser(mockUserSvc, mockAuthSvc, usersRepo, tokenRepo))
+
+	body, _ := json.Marshal(requests.CreateUserRequest{FullName: "Test User", Email: "test@example.com"})
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, "/users", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The account was created, so the request still succeeds
+	suite.Equal(http.StatusOK, w.Code)
+	mockLogger.AssertExpectations(suite.T())
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/logger"
 	"github.com/tejiriaustin/lema/models"
 	"github.com/tejiriaustin/lema/repository"
 	"github.com/tejiriaustin/lema/requests"
@@ -14,18 +15,22 @@ import (
 )
 
 type UserController struct {
-	conf *env.Environment
+	conf       *env.Environment
+	lemaLogger logger.Logger
 }
 
-func NewUserController(conf *env.Environment) *UserController {
+func NewUserController(conf *env.Environment, lemaLogger logger.Logger) *UserController {
 	return &UserController{
-		conf: conf,
+		conf:       conf,
+		lemaLogger: lemaLogger,
 	}
 }
 
 func (c *UserController) CreateUser(
 	userService service.UserServiceInterface,
-	usersRepo *repository.Repository[models.User],
+	authService service.AuthServiceInterface,
+	usersRepo repository.RepoInterface[models.User],
+	tokenRepo *repository.Repository[models.UserToken],
 ) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req requests.CreateUserRequest
@@ -39,6 +44,7 @@ func (c *UserController) CreateUser(
 		input := service.CreateUserInput{
 			FullName: req.FullName,
 			Email:    req.Email,
+			Password: req.Password,
 			Address:  &req.Address,
 		}
 
@@ -48,13 +54,20 @@ func (c *UserController) CreateUser(
 			return
 		}
 
+		// The account exists at this point; a failed email can be re-sent from /auth/verify-email/resend
+		if err := authService.SendEmailVerification(ctx, user, tokenRepo); err != nil {
+			c.lemaLogger.Error("failed to send verification email to new user",
+				logger.WithField("err", err),
+				logger.WithField("user_id", user.ID))
+		}
+
 		response.FormatResponse(ctx, http.StatusOK, "successful", response.SingleUserResponse(user))
 	}
 }
 
 func (c *UserController) GetUser(
 	userService service.UserServiceInterface,
-	usersRepo *repository.Repository[models.User],
+	usersRepo repository.RepoInterface[models.User],
 ) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		userID := ctx.Param("id")
@@ -75,7 +88,7 @@ func (c *UserController) GetUser(
 
 func (c *UserController) GetUsers(
 	userService service.UserServiceInterface,
-	usersRepo *repository.Repository[models.User],
+	usersRepo repository.RepoInterface[models.User],
 ) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		input := service.GetUsersInput{
@@ -102,7 +115,7 @@ func (c *UserController) GetUsers(
 
 func (c *UserController) GetUsersCount(
 	userService service.UserServiceInterface,
-	usersRepo *repository.Repository[models.User],
+	usersRepo repository.RepoInterface[models.User],
 ) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		usersCount, err := userService.GetUserCount(ctx, usersRepo)
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrBackupUnsupported is returned by Backup and Restore on server databases,
+// which are backed up with their own tools
+var ErrBackupUnsupported = errors.New("backup and restore are only supported for sqlite; use pg_dump or mysqldump")
+
+// Backup copies the database into a new file at path with SQLite's online
+// backup API, so reads and writes carry on while it runs. The copy is
+// written beside path and only renamed into place once it passes an
+// integrity check.
+func (c Client) Backup(ctx context.Context, path string) error {
+	if c.Dialect != DialectSQLite {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %v", err)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath)
+
+	// A WAL reader never blocks the writer, so copy from the read pool
+	// when there is one
+	source := c.DB
+	if c.readPool != nil {
+		source = c.readPool
+	}
+	sourceDB, err := source.DB()
+	if err != nil {
+		return err
+	}
+
+	destDB, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %v", err)
+	}
+	defer destDB.Close()
+
+	if err := copySQLite(ctx, destDB, sourceDB); err != nil {
+		return fmt.Errorf("backup failed: %v", err)
+	}
+	if err := checkSQLite(ctx, destDB); err != nil {
+		return fmt.Errorf("backup failed its integrity check: %v", err)
+	}
+	if err := destDB.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// Restore replaces the contents of the database with the backup at path.
+// The backup is integrity checked first and the database is left alone if
+// it fails. Writes wait while the pages are copied, but connections stay
+// open, so stop the API first unless it can tolerate its data changing
+// underneath it.
+func (c Client) Restore(ctx context.Context, path string) error {
+	if c.Dialect != DialectSQLite {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	sourceDB, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer sourceDB.Close()
+
+	if err := checkSQLite(ctx, sourceDB); err != nil {
+		return fmt.Errorf("%s failed its integrity check: %v", path, err)
+	}
+
+	destDB, err := c.DB.DB()
+	if err != nil {
+		return err
+	}
+	if err := copySQLite(ctx, destDB, sourceDB); err != nil {
+		return fmt.Errorf("restore failed: %v", err)
+	}
+	return nil
+}
+
+// copySQLite copies the main database of source into dest page by page
+func copySQLite(ctx context.Context, dest, source *sql.DB) error {
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sourceConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return sourceConn.Raw(func(sourceDriverConn interface{}) error {
+			to, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", destDriverConn)
+			}
+			from, ok := sourceDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", sourceDriverConn)
+			}
+
+			backup, err := to.Backup("main", from, "main")
+			if err != nil {
+				return err
+			}
+			// Copying every page in one step reads a single snapshot, and
+			// with WAL the writer isn't held up while it does
+			if _, err := backup.Step(-1); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// checkSQLite runs SQLite's integrity and foreign key checks
+func checkSQLite(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	violations, err := db.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer violations.Close()
+	if violations.Next() {
+		var table string
+		var rowID sql.NullInt64
+		var parent string
+		var key int
+		if err := violations.Scan(&table, &rowID, &parent, &key); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s row %d references a missing %s", table, rowID.Int64, parent)
+	}
+	return violations.Err()
+}
@@ -1,22 +1,46 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
-	"gorm.io/driver/sqlite"
-	"log"
-	"os"
 	"time"
 
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/tejiriaustin/lema/logger"
 )
 
 type (
 	Client struct {
-		DB *gorm.DB
+		DB      *gorm.DB
+		Dialect Dialect
+
+		replicas *replicaSet
+		// readPool serves reads on SQLite, whose DB pool holds the single
+		// connection every write goes through
+		readPool *gorm.DB
+		table    string
 	}
 	Config struct {
-		DB string
+		// DB is the connection DSN; its scheme selects the dialect, see ParseDSN
+		DB   string
+		Pool PoolConfig
+		// SQLite sets the pragmas of SQLite connections
+		SQLite SQLiteConfig
+
+		// Replicas are DSNs of read replicas of DB, in the same dialect
+		Replicas []string
+		// ReplicaCheckInterval is how often replicas are health checked;
+		// 0 means DefaultReplicaCheckInterval and a negative value disables it
+		ReplicaCheckInterval time.Duration
+		// ReplicaHealthCheck replaces the default ping check
+		ReplicaHealthCheck HealthCheck
+
+		// Logger receives the statements Log selects; without one nothing is logged
+		Logger logger.Logger
+		Log    LogConfig
 	}
 )
 
@@ -34,22 +58,37 @@ var _ Connection = (*Client)(nil)
 // Initialize creates a connection to the database and
 // stores the reference to `DB` which can be used for further database operations
 func Initialize(config *Config) (*Client, error) {
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-		logger.Config{
-			SlowThreshold: time.Second, // Slow SQL threshold
-			LogLevel:      logger.Info, // Log level
-			Colorful:      true,        // Enable color
-		},
-	)
+	queryLogger := gormlogger.Discard
+	if config.Logger != nil {
+		queryLogger = NewQueryLogger(config.Logger, config.Log)
+	}
 
-	db, err := gorm.Open(sqlite.Open(config.DB), &gorm.Config{
+	dialect, dsn, err := ParseDSN(config.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteConfig := config.SQLite.withDefaults()
+	if dialect == DialectSQLite {
+		if err := sqliteConfig.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	gormConfig := &gorm.Config{
 		PrepareStmt: true,
-		Logger:      newLogger,
+		Logger:      queryLogger,
 		NowFunc: func() time.Time {
 			return time.Now().Local()
 		},
-	})
+	}
+
+	primaryDSN := dsn
+	if dialect == DialectSQLite {
+		primaryDSN = sqliteDSN(dsn, sqliteConfig, true)
+	}
+
+	db, err := gorm.Open(dialector(dialect, primaryDSN), gormConfig)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
@@ -61,17 +100,87 @@ func Initialize(config *Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to get database instance: %v", err)
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	client := &Client{
+		DB:      db,
+		Dialect: dialect,
+	}
+
+	if dialect == DialectSQLite {
+		// SQLite allows one writer at a time, so writes queue for a single
+		// connection instead of failing on each other's locks, and reads get
+		// a pool of their own
+		configurePool(sqlDB, PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+		if !sqliteInMemory(dsn) {
+			client.readPool, err = openSQLiteReadPool(dsn, sqliteConfig, config.Pool, gormConfig)
+			if err != nil {
+				_ = sqlDB.Close()
+				return nil, err
+			}
+		}
+	} else {
+		configurePool(sqlDB, config.Pool.withDefaults(dialect))
+	}
+
+	if len(config.Replicas) > 0 {
+		client.replicas, err = openReplicas(config, dialect, gormConfig)
+		if err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func openSQLiteReadPool(dsn string, sqliteConfig SQLiteConfig, pool PoolConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	readConfig := *gormConfig
+	readConfig.DisableAutomaticPing = true
+	db, err := gorm.Open(dialector(DialectSQLite, sqliteDSN(dsn, sqliteConfig, false)), &readConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the sqlite read pool: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read pool instance: %v", err)
+	}
+	configurePool(sqlDB, pool.withDefaults(DialectSQLite))
+	return db, nil
+}
 
-	return &Client{
-		DB: db,
-	}, nil
+func configurePool(sqlDB *sql.DB, pool PoolConfig) {
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 }
 
 func (c Client) GetModel(name string) Client {
-	return Client{DB: c.DB.Table(name)}
+	return Client{DB: c.DB.Table(name), Dialect: c.Dialect, replicas: c.replicas, readPool: c.readPool, table: name}
+}
+
+// Table names the table GetModel bound the client to
+func (c Client) Table() string {
+	return c.table
+}
+
+// Close stops the replica health checks and releases every pooled connection
+func (c Client) Close() error {
+	var errs []error
+	if c.replicas != nil {
+		errs = append(errs, c.replicas.close())
+	}
+	if c.readPool != nil {
+		if readDB, err := c.readPool.DB(); err == nil {
+			errs = append(errs, readDB.Close())
+		}
+	}
+
+	sqlDB, err := c.DB.DB()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	return errors.Join(append(errs, sqlDB.Close())...)
 }
 
 func (c Client) Migrate(models ...interface{}) error {
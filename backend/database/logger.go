@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+)
+
+// DefaultSlowQueryThreshold is how long a statement may take before it is
+// logged as slow when LogConfig doesn't say
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+type (
+	// LogConfig decides which statements are logged. At LogLevel Error only
+	// failing statements are, at Warn slow ones too, and at Info every one.
+	LogConfig struct {
+		Level gormlogger.LogLevel
+		// SlowThreshold is how long a statement may take before it is logged
+		// as slow; 0 means DefaultSlowQueryThreshold and a negative value
+		// disables slow query reporting
+		SlowThreshold time.Duration
+		// LogParams writes bind parameters into the logged SQL. They may hold
+		// personal data and secrets, so they are replaced by placeholders
+		// unless this is set.
+		LogParams bool
+	}
+
+	// queryLogger reports GORM's statements and messages through logger.Logger
+	queryLogger struct {
+		log    logger.Logger
+		config LogConfig
+	}
+)
+
+var (
+	_ gormlogger.Interface = (*queryLogger)(nil)
+	_ gorm.ParamsFilter    = (*queryLogger)(nil)
+)
+
+// NewQueryLogger adapts log for GORM. Statements carry the request ID and
+// the authenticated user found on their context.
+func NewQueryLogger(log logger.Logger, config LogConfig) gormlogger.Interface {
+	if config.Level == 0 {
+		config.Level = gormlogger.Warn
+	}
+	if config.SlowThreshold == 0 {
+		config.SlowThreshold = DefaultSlowQueryThreshold
+	}
+	return &queryLogger{log: log, config: config}
+}
+
+// ParseLogLevel reads silent, error, warn or info
+func ParseLogLevel(level string) (gormlogger.LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "silent":
+		return gormlogger.Silent, nil
+	case "error":
+		return gormlogger.Error, nil
+	case "warn", "":
+		return gormlogger.Warn, nil
+	case "info":
+		return gormlogger.Info, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q, expected silent, error, warn or info", level)
+	}
+}
+
+func (l *queryLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.config.Level = level
+	return &clone
+}
+
+func (l *queryLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.Level >= gormlogger.Info {
+		l.log.Info(fmt.Sprintf(msg, data...), correlationFields(ctx)...)
+	}
+}
+
+func (l *queryLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.Level >= gormlogger.Warn {
+		l.log.Warn(fmt.Sprintf(msg, data...), correlationFields(ctx)...)
+	}
+}
+
+func (l *queryLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.Level >= gormlogger.Error {
+		l.log.Error(fmt.Sprintf(msg, data...), correlationFields(ctx)...)
+	}
+}
+
+// Trace logs a statement once it has run. Not finding a record is how
+// lookups report a miss, so it isn't logged as an error.
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.config.Level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold
+
+	switch {
+	case failed && l.config.Level >= gormlogger.Error:
+		l.log.Error("query failed", l.queryFields(ctx, elapsed, fc, logger.WithField("error", err))...)
+	case slow && l.config.Level >= gormlogger.Warn:
+		l.log.Warn("slow query", l.queryFields(ctx, elapsed, fc, logger.WithField("threshold_ms", l.config.SlowThreshold.Milliseconds()))...)
+	case l.config.Level >= gormlogger.Info:
+		l.log.Info("query", l.queryFields(ctx, elapsed, fc)...)
+	}
+}
+
+// ParamsFilter leaves bind parameters out of the logged SQL unless LogParams
+// is set
+func (l *queryLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.LogParams {
+		return sql, params
+	}
+	return sql, nil
+}
+
+func (l *queryLogger) queryFields(ctx context.Context, elapsed time.Duration, fc func() (string, int64), extra ...logger.Field) []logger.Field {
+	sql, rows := fc()
+	fields := append([]logger.Field{
+		logger.WithField("sql", sql),
+		logger.WithField("rows", rows),
+		logger.WithField("elapsed_ms", float64(elapsed.Microseconds())/1000),
+	}, extra...)
+	return append(fields, correlationFields(ctx)...)
+}
+
+// correlationFields ties a log line to the request that caused it
+func correlationFields(ctx context.Context) []logger.Field {
+	if ctx == nil {
+		return nil
+	}
+
+	var fields []logger.Field
+	if requestID, ok := ctx.Value(string(constants.ContextKeyRequestID)).(string); ok && requestID != "" {
+		fields = append(fields, logger.WithField("request_id", requestID))
+	}
+	if account, ok := ctx.Value(string(constants.ContextKeyAccountInfo)).(models.AccountInfo); ok && account.Id != "" {
+		fields = append(fields, logger.WithField("user_id", account.Id))
+	}
+	return fields
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultReplicaCheckInterval is how often replicas are probed so ejected
+	// ones rejoin the rotation once they recover
+	DefaultReplicaCheckInterval = 10 * time.Second
+
+	replicaCheckTimeout = 2 * time.Second
+)
+
+// HealthCheck decides whether a replica may serve reads. The default pings
+// it; a check can also refuse a replica that lags too far behind.
+type HealthCheck func(ctx context.Context, db *sql.DB) error
+
+// ReplicaStatus describes one replica for health reporting
+type ReplicaStatus struct {
+	Name    string
+	Healthy bool
+}
+
+type (
+	replica struct {
+		name    string
+		db      *gorm.DB
+		sqlDB   *sql.DB
+		healthy atomic.Bool
+	}
+
+	replicaSet struct {
+		replicas []*replica
+		next     atomic.Uint64
+		check    HealthCheck
+		stop     context.CancelFunc
+		stopped  sync.WaitGroup
+	}
+)
+
+func pingCheck(ctx context.Context, db *sql.DB) error {
+	return db.PingContext(ctx)
+}
+
+// openReplicas opens every replica without requiring it to be reachable; one
+// that is down starts ejected and rejoins when a later check passes
+func openReplicas(config *Config, dialect Dialect, gormConfig *gorm.Config) (*replicaSet, error) {
+	set := &replicaSet{check: config.ReplicaHealthCheck}
+	if set.check == nil {
+		set.check = pingCheck
+	}
+
+	for _, replicaDSN := range config.Replicas {
+		replicaDialect, dsn, err := ParseDSN(replicaDSN)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		if replicaDialect != dialect {
+			set.close()
+			return nil, fmt.Errorf("replica %s is %s but the primary is %s", redact(replicaDSN), replicaDialect, dialect)
+		}
+
+		if dialect == DialectSQLite {
+			dsn = sqliteDSN(dsn, config.SQLite.withDefaults(), false)
+		}
+
+		replicaConfig := *gormConfig
+		replicaConfig.DisableAutomaticPing = true
+		db, err := gorm.Open(dialector(dialect, dsn), &replicaConfig)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("failed to open replica %s: %v", redact(replicaDSN), err)
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("failed to get replica instance: %v", err)
+		}
+		configurePool(sqlDB, config.Pool.withDefaults(dialect))
+
+		set.replicas = append(set.replicas, &replica{name: redact(replicaDSN), db: db, sqlDB: sqlDB})
+	}
+
+	set.checkAll(context.Background())
+
+	interval := config.ReplicaCheckInterval
+	if interval == 0 {
+		interval = DefaultReplicaCheckInterval
+	}
+	if interval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		set.stop = cancel
+		set.stopped.Add(1)
+		go set.monitor(ctx, interval)
+	}
+
+	return set, nil
+}
+
+// pick returns the next healthy replica in turn, or nil when none is healthy
+func (s *replicaSet) pick() *replica {
+	count := uint64(len(s.replicas))
+	for i := uint64(0); i < count; i++ {
+		candidate := s.replicas[(s.next.Add(1)-1)%count]
+		if candidate.healthy.Load() {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func (s *replicaSet) monitor(ctx context.Context, interval time.Duration) {
+	defer s.stopped.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkAll(ctx)
+		}
+	}
+}
+
+func (s *replicaSet) checkAll(ctx context.Context) {
+	for _, r := range s.replicas {
+		s.checkOne(ctx, r)
+	}
+}
+
+// checkOne runs the health check and records the result, logging changes
+func (s *replicaSet) checkOne(ctx context.Context, r *replica) bool {
+	checkCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	err := s.check(checkCtx, r.sqlDB)
+	healthy := err == nil
+
+	if was := r.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			log.Printf("replica %s is healthy, routing reads to it", r.name)
+		} else {
+			log.Printf("replica %s failed its health check and was ejected: %v", r.name, err)
+		}
+	}
+	return healthy
+}
+
+// reportFailure re-checks a replica after a read on it failed. It returns true
+// when the replica was ejected, meaning the read should be retried on the
+// primary; otherwise the error came from the query itself.
+func (s *replicaSet) reportFailure(ctx context.Context, r *replica, err error) bool {
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+		return false
+	}
+	return !s.checkOne(context.WithoutCancel(ctx), r)
+}
+
+func (s *replicaSet) statuses() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		statuses = append(statuses, ReplicaStatus{Name: r.name, Healthy: r.healthy.Load()})
+	}
+	return statuses
+}
+
+func (s *replicaSet) close() error {
+	if s.stop != nil {
+		s.stop()
+		s.stopped.Wait()
+	}
+
+	var errs []error
+	for _, r := range s.replicas {
+		errs = append(errs, r.sqlDB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// redact hides the password of a DSN so it can be logged
+func redact(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return dsn
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
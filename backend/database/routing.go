@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+type routingKey struct{}
+
+type routing struct {
+	primaryOnly bool
+	wrote       atomic.Bool
+}
+
+// UsePrimary sends every read made with the returned context to the primary
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routingKey{}, &routing{primaryOnly: true})
+}
+
+// ReadYourWrites lets reads made with the returned context use replicas
+// until the first write through it; from then on they go to the primary, so
+// a request sees its own changes despite replication lag.
+func ReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(routingKey{}).(*routing); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, routingKey{}, &routing{})
+}
+
+// MarkWrite records that ctx has written to the primary
+func MarkWrite(ctx context.Context) {
+	if r, ok := ctx.Value(routingKey{}).(*routing); ok {
+		r.wrote.Store(true)
+	}
+}
+
+func readsFromPrimary(ctx context.Context) bool {
+	r, ok := ctx.Value(routingKey{}).(*routing)
+	return ok && (r.primaryOnly || r.wrote.Load())
+}
+
+// Reader returns the connection a read made with ctx should use, and a
+// function to call with the read's error. The function returns true when the
+// replica was ejected and the read should be retried on c.DB.
+func (c Client) Reader(ctx context.Context) (*gorm.DB, func(error) bool) {
+	noRetry := func(error) bool { return false }
+
+	if c.replicas == nil && c.readPool != nil {
+		// Every SQLite connection sees each committed write, so reads need
+		// not queue for the writer to see their own changes
+		db := c.readPool
+		if c.table != "" {
+			db = db.Table(c.table)
+		}
+		return db, noRetry
+	}
+
+	if c.replicas == nil || readsFromPrimary(ctx) {
+		return c.DB, noRetry
+	}
+
+	r := c.replicas.pick()
+	if r == nil {
+		return c.DB, noRetry
+	}
+
+	db := r.db
+	if c.table != "" {
+		db = db.Table(c.table)
+	}
+	return db, func(err error) bool {
+		return c.replicas.reportFailure(ctx, r, err)
+	}
+}
+
+// ReplicaStatuses reports whether each replica is currently serving reads
+func (c Client) ReplicaStatuses() []ReplicaStatus {
+	if c.replicas == nil {
+		return nil
+	}
+	return c.replicas.statuses()
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteConfig sets the pragmas every SQLite connection is opened with. Zero
+// fields fall back to DefaultSQLiteConfig.
+type SQLiteConfig struct {
+	// JournalMode is DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF. WAL lets
+	// reads run while a write is in progress.
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock held by another
+	// before failing with "database is locked"
+	BusyTimeout time.Duration
+	// Synchronous is OFF, NORMAL, FULL or EXTRA. NORMAL is safe with WAL and
+	// only risks the last commits on power loss.
+	Synchronous string
+	// ForeignKeys enforces foreign keys and their cascades, which SQLite
+	// leaves off unless asked
+	ForeignKeys *bool
+}
+
+var (
+	sqliteJournalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	sqliteSynchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// DefaultSQLiteConfig suits a server: WAL, five seconds of patience for
+// locks, NORMAL sync and enforced foreign keys
+func DefaultSQLiteConfig() SQLiteConfig {
+	foreignKeys := true
+	return SQLiteConfig{
+		JournalMode: "WAL",
+		BusyTimeout: 5 * time.Second,
+		Synchronous: "NORMAL",
+		ForeignKeys: &foreignKeys,
+	}
+}
+
+func (c SQLiteConfig) withDefaults() SQLiteConfig {
+	defaults := DefaultSQLiteConfig()
+	if c.JournalMode == "" {
+		c.JournalMode = defaults.JournalMode
+	}
+	if c.BusyTimeout == 0 {
+		c.BusyTimeout = defaults.BusyTimeout
+	}
+	if c.Synchronous == "" {
+		c.Synchronous = defaults.Synchronous
+	}
+	if c.ForeignKeys == nil {
+		c.ForeignKeys = defaults.ForeignKeys
+	}
+	c.JournalMode = strings.ToUpper(c.JournalMode)
+	c.Synchronous = strings.ToUpper(c.Synchronous)
+	return c
+}
+
+func (c SQLiteConfig) validate() error {
+	if !oneOf(c.JournalMode, sqliteJournalModes) {
+		return fmt.Errorf("sqlite journal mode %q is not one of %s", c.JournalMode, strings.Join(sqliteJournalModes, ", "))
+	}
+	if !oneOf(c.Synchronous, sqliteSynchronous) {
+		return fmt.Errorf("sqlite synchronous %q is not one of %s", c.Synchronous, strings.Join(sqliteSynchronous, ", "))
+	}
+	if c.BusyTimeout < 0 {
+		return fmt.Errorf("sqlite busy timeout must not be negative")
+	}
+	return nil
+}
+
+// sqliteDSN adds the pragmas of config to path, leaving any the path already
+// sets alone. The writer takes its lock when a transaction begins, so two
+// transactions never deadlock upgrading from a read lock; readers can't write
+// at all.
+func sqliteDSN(path string, config SQLiteConfig, writer bool) string {
+	base, rawQuery, _ := strings.Cut(path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		query = url.Values{}
+	}
+
+	set := func(key, value string) {
+		if query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+
+	set("_busy_timeout", strconv.FormatInt(config.BusyTimeout.Milliseconds(), 10))
+	set("_synchronous", config.Synchronous)
+	set("_foreign_keys", strconv.FormatBool(*config.ForeignKeys))
+	if writer {
+		// The journal mode is a property of the file, which the writer sets
+		set("_journal_mode", config.JournalMode)
+		set("_txlock", "immediate")
+	} else {
+		set("_query_only", "true")
+	}
+
+	return base + "?" + query.Encode()
+}
+
+// sqliteInMemory reports whether path names a database that lives in the
+// connection, which a second pool could not see
+func sqliteInMemory(path string) bool {
+	return strings.Contains(path, ":memory:") || strings.Contains(path, "mode=memory")
+}
+
+func oneOf(value string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type BackupTestSuite struct {
+	testutils.BaseSuite
+	dir    string
+	dbConn *database.Client
+}
+
+func TestBackup(t *testing.T) {
+	suite.Run(t, new(BackupTestSuite))
+}
+
+func (suite *BackupTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.dbConn = suite.open("lema.db")
+}
+
+func (suite *BackupTestSuite) open(name string) *database.Client {
+	dbConn, err := database.Initialize(&database.Config{DB: "sqlite://" + filepath.Join(suite.dir, name)})
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { _ = dbConn.Close() })
+	suite.Require().NoError(dbConn.Migrate(models.User{}))
+	return dbConn
+}
+
+func (suite *BackupTestSuite) createUser(dbConn *database.Client, email string) {
+	user := models.User{Name: "Jane", Email: email}
+	user.ID = email
+	suite.Require().NoError(dbConn.DB.Create(&user).Error)
+}
+
+func (suite *BackupTestSuite) emails(dbConn *database.Client) []string {
+	// Read through the read pool, which must see a restore too
+	reader, _ := dbConn.Reader(context.Background())
+	var emails []string
+	suite.Require().NoError(reader.Model(&models.User{}).Order("email").Pluck("email", &emails).Error)
+	return emails
+}
+
+func (suite *BackupTestSuite) TestBackupAndRestore() {
+	ctx := context.Background()
+	suite.createUser(suite.dbConn, "a@example.com")
+
+	out := filepath.Join(suite.dir, "backup.db")
+	suite.Require().NoError(suite.dbConn.Backup(ctx, out))
+	suite.Error(suite.dbConn.Backup(ctx, out), "an existing backup is not overwritten")
+
+	suite.Equal([]string{"a@example.com"}, suite.emails(suite.open("backup.db")))
+
+	suite.createUser(suite.dbConn, "b@example.com")
+	suite.Require().NoError(suite.dbConn.Restore(ctx, out))
+	suite.Equal([]string{"a@example.com"}, suite.emails(suite.dbConn))
+
+	leftovers, err := filepath.Glob(filepath.Join(suite.dir, "*.tmp"))
+	suite.Require().NoError(err)
+	suite.Empty(leftovers)
+}
+
+func (suite *BackupTestSuite) TestBackupWhileWriting() {
+	ctx := context.Background()
+	suite.createUser(suite.dbConn, "first@example.com")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			email := fmt.Sprintf("writer-%d@example.com", i)
+			user := models.User{Name: "Writer", Email: email}
+			user.ID = email
+			suite.NoError(suite.dbConn.DB.Create(&user).Error)
+		}
+	}()
+
+	out := filepath.Join(suite.dir, "online.db")
+	suite.Require().NoError(suite.dbConn.Backup(ctx, out))
+	wg.Wait()
+
+	suite.Contains(suite.emails(suite.open("online.db")), "first@example.com")
+}
+
+func (suite *BackupTestSuite) TestRestoreRejectsACorruptBackup() {
+	ctx := context.Background()
+	suite.createUser(suite.dbConn, "a@example.com")
+
+	out := filepath.Join(suite.dir, "corrupt.db")
+	suite.Require().NoError(os.WriteFile(out, []byte("this is not a database"), 0o600))
+
+	suite.Error(suite.dbConn.Restore(ctx, out))
+	suite.Equal([]string{"a@example.com"}, suite.emails(suite.dbConn), "the database is left alone")
+
+	suite.Error(suite.dbConn.Restore(ctx, filepath.Join(suite.dir, "missing.db")))
+}
+
+func (suite *BackupTestSuite) TestServerDatabasesAreNotSupported() {
+	dbConn := &database.Client{Dialect: database.DialectPostgres}
+	suite.ErrorIs(dbConn.Backup(context.Background(), filepath.Join(suite.dir, "pg.db")), database.ErrBackupUnsupported)
+	suite.ErrorIs(dbConn.Restore(context.Background(), filepath.Join(suite.dir, "pg.db")), database.ErrBackupUnsupported)
+}
@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type DialectTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestDialect(t *testing.T) {
+	suite.Run(t, new(DialectTestSuite))
+}
+
+func (suite *DialectTestSuite) TestParseDSN() {
+	testCases := []struct {
+		name            string
+		dsn             string
+		expectedDialect database.Dialect
+		expectedDSN     string
+		expectErr       bool
+	}{
+		{
+			name:            "bare path is sqlite",
+			dsn:             "lemadatabase.db",
+			expectedDialect: database.DialectSQLite,
+			expectedDSN:     "lemadatabase.db",
+		},
+		{
+			name:            "relative sqlite path",
+			dsn:             "sqlite://lemadatabase.db",
+			expectedDialect: database.DialectSQLite,
+			expectedDSN:     "lemadatabase.db",
+		},
+		{
+			name:            "absolute sqlite path",
+			dsn:             "sqlite:///var/lib/lema/lema.db",
+			expectedDialect: database.DialectSQLite,
+			expectedDSN:     "/var/lib/lema/lema.db",
+		},
+		{
+			name:            "in-memory sqlite",
+			dsn:             "sqlite://:memory:",
+			expectedDialect: database.DialectSQLite,
+			expectedDSN:     ":memory:",
+		},
+		{
+			name:            "postgres url is passed through",
+			dsn:             "postgres://lema:secret@db:5432/lema?sslmode=disable",
+			expectedDialect: database.DialectPostgres,
+			expectedDSN:     "postgres://lema:secret@db:5432/lema?sslmode=disable",
+		},
+		{
+			name:            "postgresql scheme",
+			dsn:             "postgresql://lema@db/lema",
+			expectedDialect: database.DialectPostgres,
+			expectedDSN:     "postgresql://lema@db/lema",
+		},
+		{
+			name:            "mysql url is rewritten for the driver",
+			dsn:             "mysql://lema:secret@db:3307/lema",
+			expectedDialect: database.DialectMySQL,
+			expectedDSN:     "lema:secret@tcp(db:3307)/lema?charset=utf8mb4&loc=UTC&parseTime=true",
+		},
+		{
+			name:            "mysql keeps explicit options and defaults the port",
+			dsn:             "mysql://lema@db/lema?parseTime=false&timeout=5s",
+			expectedDialect: database.DialectMySQL,
+			expectedDSN:     "lema@tcp(db:3306)/lema?charset=utf8mb4&loc=UTC&parseTime=false&timeout=5s",
+		},
+		{
+			name:      "mysql without a database",
+			dsn:       "mysql://lema@db",
+			expectErr: true,
+		},
+		{
+			name:      "sqlite without a path",
+			dsn:       "sqlite://",
+			expectErr: true,
+		},
+		{
+			name:      "unknown scheme",
+			dsn:       "oracle://lema@db/lema",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			dialect, dsn, err := database.ParseDSN(tc.dsn)
+			if tc.expectErr {
+				suite.Error(err)
+				return
+			}
+			suite.Require().NoError(err)
+			suite.Equal(tc.expectedDialect, dialect)
+			suite.Equal(tc.expectedDSN, dsn)
+		})
+	}
+}
+
+func (suite *DialectTestSuite) TestPoolDefaults() {
+	postgresPool := database.DefaultPoolConfig(database.DialectPostgres)
+	mysqlPool := database.DefaultPoolConfig(database.DialectMySQL)
+	sqlitePool := database.DefaultPoolConfig(database.DialectSQLite)
+
+	suite.NotZero(postgresPool.ConnMaxLifetime)
+	suite.NotZero(mysqlPool.ConnMaxLifetime)
+	suite.Less(mysqlPool.ConnMaxLifetime, postgresPool.ConnMaxLifetime, "mysql connections must be recycled before wait_timeout")
+	suite.Zero(sqlitePool.ConnMaxLifetime)
+}
+
+func (suite *DialectTestSuite) TestInitializeSQLite() {
+	dbConn, err := database.Initialize(&database.Config{
+		DB:   "sqlite://" + suite.T().TempDir() + "/lema.db",
+		Pool: database.PoolConfig{MaxOpenConns: 3},
+	})
+	suite.Require().NoError(err)
+	defer dbConn.Close()
+
+	suite.Equal(database.DialectSQLite, dbConn.Dialect)
+	suite.Equal(database.DialectSQLite, dbConn.GetModel("users").Dialect)
+
+	// Writes queue for one connection; the pool settings size the read pool
+	sqlDB, err := dbConn.DB.DB()
+	suite.Require().NoError(err)
+	suite.Equal(1, sqlDB.Stats().MaxOpenConnections)
+
+	reader, _ := dbConn.Reader(context.Background())
+	readDB, err := reader.DB()
+	suite.Require().NoError(err)
+	suite.Equal(3, readDB.Stats().MaxOpenConnections)
+}
+
+func (suite *DialectTestSuite) TestSQLitePragmas() {
+	pragma := func(db *gorm.DB, name string) string {
+		var value string
+		suite.Require().NoError(db.Raw("PRAGMA " + name).Scan(&value).Error)
+		return value
+	}
+
+	dbConn, err := database.Initialize(&database.Config{DB: "sqlite://" + suite.T().TempDir() + "/lema.db"})
+	suite.Require().NoError(err)
+	defer dbConn.Close()
+
+	suite.Equal("wal", pragma(dbConn.DB, "journal_mode"))
+	suite.Equal("5000", pragma(dbConn.DB, "busy_timeout"))
+	suite.Equal("1", pragma(dbConn.DB, "synchronous"), "NORMAL")
+	suite.Equal("1", pragma(dbConn.DB, "foreign_keys"))
+
+	reader, _ := dbConn.Reader(context.Background())
+	suite.Equal("1", pragma(reader, "query_only"))
+	suite.Equal("1", pragma(reader, "foreign_keys"))
+
+	foreignKeys := false
+	tuned, err := database.Initialize(&database.Config{
+		DB: "sqlite://" + suite.T().TempDir() + "/tuned.db",
+		SQLite: database.SQLiteConfig{
+			JournalMode: "truncate",
+			BusyTimeout: time.Second,
+			Synchronous: "full",
+			ForeignKeys: &foreignKeys,
+		},
+	})
+	suite.Require().NoError(err)
+	defer tuned.Close()
+
+	suite.Equal("truncate", pragma(tuned.DB, "journal_mode"))
+	suite.Equal("1000", pragma(tuned.DB, "busy_timeout"))
+	suite.Equal("2", pragma(tuned.DB, "synchronous"), "FULL")
+	suite.Equal("0", pragma(tuned.DB, "foreign_keys"))
+
+	_, err = database.Initialize(&database.Config{
+		DB:     "sqlite://" + suite.T().TempDir() + "/bad.db",
+		SQLite: database.SQLiteConfig{JournalMode: "sideways"},
+	})
+	suite.Error(err)
+}
+
+func (suite *DialectTestSuite) TestSQLiteWritesQueueInsteadOfFailing() {
+	dbConn, err := database.Initialize(&database.Config{DB: "sqlite://" + suite.T().TempDir() + "/lema.db"})
+	suite.Require().NoError(err)
+	defer dbConn.Close()
+	suite.Require().NoError(dbConn.Migrate(models.User{}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- dbConn.DB.Transaction(func(tx *gorm.DB) error {
+				user := models.User{Shared: models.Shared{ID: fmt.Sprintf("user-%d", i)}, Name: "n", Username: "u", Email: fmt.Sprintf("%d@example.com", i)}
+				return tx.Create(&user).Error
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		suite.NoError(err)
+	}
+
+	var count int64
+	reader, _ := dbConn.Reader(context.Background())
+	suite.Require().NoError(reader.Model(&models.User{}).Count(&count).Error)
+	suite.Equal(int64(20), count)
+}
+
+// createTableSQL renders the CREATE TABLE statements for the models using a
+// server dialect backed by sqlmock, which stands in for a live server.
+func (suite *DialectTestSuite) createTableSQL(dialect database.Dialect, tables ...interface{}) string {
+	var statements []string
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(_, actual string) error {
+		statements = append(statements, actual)
+		return nil
+	})))
+	suite.Require().NoError(err)
+	defer sqlDB.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 100; i++ {
+		mock.ExpectExec("").WillReturnResult(driver.ResultNoRows)
+	}
+
+	var dialector gorm.Dialector
+	switch dialect {
+	case database.DialectPostgres:
+		dialector = postgres.New(postgres.Config{Conn: sqlDB})
+	case database.DialectMySQL:
+		dialector = mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true})
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard, DisableAutomaticPing: true})
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.Migrator().CreateTable(tables...))
+
+	return strings.Join(statements, ";\n")
+}
+
+func (suite *DialectTestSuite) TestPortableSchema() {
+	tables := []interface{}{models.User{}, models.Address{}, models.Post{}}
+
+	testCases := []struct {
+		dialect database.Dialect
+		quote   string
+	}{
+		{dialect: database.DialectPostgres, quote: `"`},
+		{dialect: database.DialectMySQL, quote: "`"},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(string(tc.dialect), func() {
+			ddl := suite.createTableSQL(tc.dialect, tables...)
+			q := regexp.QuoteMeta(tc.quote)
+
+			suite.Regexp(q+`_version`+q+` bigint NOT NULL DEFAULT 0`, ddl)
+			suite.Regexp(q+`id`+q+` varchar\(36\)`, ddl, "ids must fit a uuid")
+			suite.Regexp(q+`role`+q+` varchar\(20\) NOT NULL DEFAULT 'user'`, ddl, "an unquoted user default is CURRENT_USER on postgres")
+		})
+	}
+}
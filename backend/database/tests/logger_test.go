@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type logLine struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordingLogger keeps every line so tests can look at the fields
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []logLine
+}
+
+func (l *recordingLogger) add(level, msg string, fields []logger.Field) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	line := logLine{level: level, msg: msg, fields: map[string]interface{}{}}
+	for _, field := range fields {
+		line.fields[field.Key] = field.Value
+	}
+	l.lines = append(l.lines, line)
+}
+
+func (l *recordingLogger) find(msg string) []logLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var found []logLine
+	for _, line := range l.lines {
+		if line.msg == msg {
+			found = append(found, line)
+		}
+	}
+	return found
+}
+
+func (l *recordingLogger) Info(msg string, fields ...logger.Field)  { l.add("info", msg, fields) }
+func (l *recordingLogger) Error(msg string, fields ...logger.Field) { l.add("error", msg, fields) }
+func (l *recordingLogger) Debug(msg string, fields ...logger.Field) { l.add("debug", msg, fields) }
+func (l *recordingLogger) Warn(msg string, fields ...logger.Field)  { l.add("warn", msg, fields) }
+func (l *recordingLogger) Fatal(msg string, fields ...logger.Field) { l.add("fatal", msg, fields) }
+func (l *recordingLogger) Sync() error                              { return nil }
+
+type QueryLoggerTestSuite struct {
+	testutils.BaseSuite
+	log *recordingLogger
+}
+
+func TestQueryLogger(t *testing.T) {
+	suite.Run(t, new(QueryLoggerTestSuite))
+}
+
+func (suite *QueryLoggerTestSuite) open(config database.LogConfig) *database.Client {
+	suite.log = &recordingLogger{}
+
+	dbConn, err := database.Initialize(&database.Config{
+		DB:     "sqlite://" + filepath.Join(suite.T().TempDir(), "logger.db"),
+		Logger: suite.log,
+		Log:    config,
+	})
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { _ = dbConn.Close() })
+
+	suite.Require().NoError(dbConn.Migrate(models.User{}))
+	return dbConn
+}
+
+func (suite *QueryLoggerTestSuite) requestContext() context.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(string(constants.ContextKeyRequestID), "request-1")
+	c.Set(string(constants.ContextKeyAccountInfo), models.AccountInfo{Id: "user-1"})
+	return c
+}
+
+func (suite *QueryLoggerTestSuite) TestInfoLogsEveryStatementWithoutParams() {
+	dbConn := suite.open(database.LogConfig{Level: gormlogger.Info})
+
+	var users []models.User
+	suite.Require().NoError(dbConn.DB.WithContext(suite.requestContext()).Where("email = ?", "jane@example.com").Find(&users).Error)
+
+	var found *logLine
+	for _, line := range suite.log.find("query") {
+		if line.fields["request_id"] == "request-1" {
+			found = &line
+		}
+	}
+	suite.Require().NotNil(found, "the select is logged with the request's fields")
+	suite.Equal("info", found.level)
+	suite.Equal("user-1", found.fields["user_id"])
+	suite.Contains(found.fields["sql"], "email = ?")
+	suite.NotContains(found.fields["sql"], "jane@example.com")
+	suite.Contains(found.fields, "elapsed_ms")
+	suite.Contains(found.fields, "rows")
+}
+
+func (suite *QueryLoggerTestSuite) TestParamsAreLoggedWhenEnabled() {
+	dbConn := suite.open(database.LogConfig{Level: gormlogger.Info, LogParams: true})
+
+	var users []models.User
+	suite.Require().NoError(dbConn.DB.Where("email = ?", "jane@example.com").Find(&users).Error)
+
+	lines := suite.log.find("query")
+	suite.Require().NotEmpty(lines)
+	suite.Contains(lines[len(lines)-1].fields["sql"], "jane@example.com")
+}
+
+func (suite *QueryLoggerTestSuite) TestWarnLogsOnlySlowStatements() {
+	dbConn := suite.open(database.LogConfig{Level: gormlogger.Warn, SlowThreshold: time.Hour})
+
+	var users []models.User
+	suite.Require().NoError(dbConn.DB.Find(&users).Error)
+	suite.Empty(suite.log.find("query"))
+	suite.Empty(suite.log.find("slow query"))
+
+	slow := database.NewQueryLogger(suite.log, database.LogConfig{Level: gormlogger.Warn, SlowThreshold: time.Nanosecond})
+	suite.Require().NoError(dbConn.DB.Session(&gorm.Session{Logger: slow}).Find(&users).Error)
+
+	lines := suite.log.find("slow query")
+	suite.Require().Len(lines, 1)
+	suite.Equal("warn", lines[0].level)
+	suite.Equal(int64(0), lines[0].fields["threshold_ms"])
+}
+
+func (suite *QueryLoggerTestSuite) TestErrorsAreLoggedButMissesAreNot() {
+	dbConn := suite.open(database.LogConfig{Level: gormlogger.Error})
+
+	var user models.User
+	suite.Error(dbConn.DB.Where("email = ?", "nobody@example.com").First(&user).Error)
+	suite.Empty(suite.log.find("query failed"), "a record that isn't found is not an error")
+
+	suite.Error(dbConn.DB.Exec("SELECT * FROM missing_table").Error)
+	lines := suite.log.find("query failed")
+	suite.Require().Len(lines, 1)
+	suite.Equal("error", lines[0].level)
+	suite.NotNil(lines[0].fields["error"])
+}
+
+func (suite *QueryLoggerTestSuite) TestParseLogLevel() {
+	for input, expected := range map[string]gormlogger.LogLevel{
+		"":       gormlogger.Warn,
+		"silent": gormlogger.Silent,
+		"error":  gormlogger.Error,
+		"WARN":   gormlogger.Warn,
+		"info":   gormlogger.Info,
+	} {
+		level, err := database.ParseLogLevel(input)
+		suite.NoError(err, input)
+		suite.Equal(expected, level, input)
+	}
+
+	_, err := database.ParseLogLevel("verbose")
+	suite.Error(err)
+}
@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	Message struct {
+		From    string
+		To      []string
+		Subject string
+		Body    string
+	}
+
+	Mailer interface {
+		Send(ctx context.Context, msg Message) error
+	}
+)
+
+// Bytes renders the message as a plain-text RFC 5322 email
+func (m Message) Bytes() []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(m.Body)
+
+	return []byte(b.String())
+}
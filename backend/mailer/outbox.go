@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OutboxMailer keeps every message it is asked to send instead of delivering it.
+// When a directory is configured messages are written out as .eml files so they
+// can be opened during local development; otherwise they are held in memory.
+type OutboxMailer struct {
+	mu       sync.Mutex
+	dir      string
+	from     string
+	sent     int
+	messages []Message
+}
+
+var _ Mailer = (*OutboxMailer)(nil)
+
+// NewOutboxMailer creates an OutboxMailer. An empty dir keeps messages in memory
+// instead of writing them out.
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+		}
+	}
+	return &OutboxMailer{dir: dir, from: from}, nil
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent++
+	// A long running server would otherwise hold every message it ever sent
+	if m.dir == "" {
+		m.messages = append(m.messages, msg)
+		return nil
+	}
+
+	name := fmt.Sprintf("%d-%04d.eml", time.Now().UnixNano(), m.sent)
+	if err := os.WriteFile(filepath.Join(m.dir, name), msg.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write message to outbox: %w", err)
+	}
+	return nil
+}
+
+// Messages returns a copy of every message sent so far. It is empty when
+// messages are written to a directory.
+func (m *OutboxMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
+
+// Last returns the most recently sent message, when messages are held in memory
+func (m *OutboxMailer) Last() (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.messages) == 0 {
+		return Message{}, false
+	}
+	return m.messages[len(m.messages)-1], true
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+type (
+	SMTPConfig struct {
+		Host     string
+		Port     string
+		Username string
+		Password string
+		From     string
+	}
+
+	smtpMailer struct {
+		config SMTPConfig
+		auth   smtp.Auth
+	}
+)
+
+var _ Mailer = (*smtpMailer)(nil)
+
+// NewSMTPMailer creates a Mailer that delivers messages through an SMTP relay
+func NewSMTPMailer(config SMTPConfig) (Mailer, error) {
+	if config.Host == "" || config.Port == "" {
+		return nil, errors.New("smtp host and port are required")
+	}
+	if config.From == "" {
+		return nil, errors.New("smtp sender address is required")
+	}
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	return &smtpMailer{config: config, auth: auth}, nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if msg.From == "" {
+		msg.From = m.config.From
+	}
+
+	addr := net.JoinHostPort(m.config.Host, m.config.Port)
+	if err := smtp.SendMail(addr, m.auth, msg.From, msg.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type OutboxMailerTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestOutboxMailer(t *testing.T) {
+	suite.Run(t, new(OutboxMailerTestSuite))
+}
+
+func (suite *OutboxMailerTestSuite) message() mailer.Message {
+	return mailer.Message{To: []string{"jane@example.com"}, Subject: "Hello", Body: "Hi Jane"}
+}
+
+func (suite *OutboxMailerTestSuite) TestKeepsMessagesInMemoryWithoutADirectory() {
+	outbox, err := mailer.NewOutboxMailer("", "no-reply@lema.local")
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(outbox.Send(context.Background(), suite.message()))
+
+	last, ok := outbox.Last()
+	suite.Require().True(ok)
+	suite.Equal("no-reply@lema.local", last.From)
+	suite.Len(outbox.Messages(), 1)
+}
+
+func (suite *OutboxMailerTestSuite) TestWritesMessagesToTheDirectory() {
+	dir := suite.T().TempDir()
+	outbox, err := mailer.NewOutboxMailer(dir, "no-reply@lema.local")
+	suite.Require().NoError(err)
+
+	for i := 0; i < 3; i++ {
+		suite.Require().NoError(outbox.Send(context.Background(), suite.message()))
+	}
+
+	files, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	suite.Len(files, 3)
+	suite.Empty(outbox.Messages(), "messages written out are not held in memory too")
+}
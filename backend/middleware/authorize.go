@@ -50,19 +50,59 @@ func Authorize(config *env.Environment) gin.HandlerFunc {
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			user := models.AccountInfo{
-				Id:       claims["id"].(string),
-				FullName: claims["full_name"].(string),
-				Email:    claims["email"].(string),
-			}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !token.Valid {
+			c.JSON(401, gin.H{"error": "Invalid token claims"})
+			c.Abort()
+			return
+		}
 
-			c.Set("x-user-info", user)
-			c.Next()
-		} else {
+		user, ok := accountFromClaims(claims)
+		if !ok {
 			c.JSON(401, gin.H{"error": "Invalid token claims"})
 			c.Abort()
 			return
 		}
+
+		c.Set(string(constants.ContextKeyAccountInfo), user)
+		c.Next()
+	}
+}
+
+// accountFromClaims reads the account from an access token. Other tokens
+// signed with the same key, such as the SSO login state, are refused.
+func accountFromClaims(claims jwt.MapClaims) (models.AccountInfo, bool) {
+	var user models.AccountInfo
+
+	// Tokens issued before types existed don't carry one
+	if typ, ok := claims[constants.TokenTypeClaim]; ok && typ != constants.TokenTypeAccess {
+		return user, false
+	}
+
+	id, idOK := claims["id"].(string)
+	email, emailOK := claims["email"].(string)
+	if !idOK || id == "" || !emailOK {
+		return user, false
+	}
+	user.Id = id
+	user.Email = email
+	user.FullName, _ = claims["full_name"].(string)
+
+	// Tokens issued before roles existed don't carry one
+	user.Role, _ = claims["role"].(string)
+	return user, true
+}
+
+// RequireRole only lets through accounts with the given role. It must run after Authorize.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account, ok := c.Get(string(constants.ContextKeyAccountInfo))
+		if info, isAccount := account.(models.AccountInfo); !ok || !isAccount || info.Role != role {
+			c.JSON(403, gin.H{"error": "You are not allowed to access this resource"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
 	}
 }
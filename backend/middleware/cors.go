@@ -1,19 +1,166 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Timezone")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	"github.com/gin-gonic/gin"
+)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowedHeaders = "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Timezone, X-API-Key, X-Request-ID"
+	// corsExposedHeaders are the response headers, beyond the basic ones,
+	// that browser code may read
+	corsExposedHeaders = "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After"
+)
+
+type (
+	// CORSConfig lists the origins browsers may call the API from. An origin
+	// is a scheme, host and optional port such as https://app.lema.io; a host
+	// starting with *. matches its subdomains, so https://*.lema.io lets in
+	// https://staging.lema.io but not https://lema.io. A lone * lets in every
+	// origin, without credentials.
+	CORSConfig struct {
+		// AllowedOrigins are as returned by ParseOrigin
+		AllowedOrigins []string
+		// MaxAge is how long browsers may cache a preflight
+		MaxAge time.Duration
+	}
+
+	corsPolicy struct {
+		anyOrigin bool
+		origins   map[string]bool
+		patterns  []originPattern
+		maxAge    string
+	}
+
+	// originPattern matches origins made of prefix, one or more subdomain
+	// labels and suffix
+	originPattern struct {
+		prefix string
+		suffix string
+	}
+)
+
+// ParseOrigin checks value is an origin or an origin pattern and returns it
+// in the form browsers send. A trailing slash is dropped, so a frontend URL
+// can be used as is.
+func ParseOrigin(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return value, nil
+	}
+
+	parsed, err := url.Parse(strings.TrimSuffix(value, "/"))
+	if err != nil {
+		return "", fmt.Errorf("origin %q: %v", value, err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" || parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil {
+		return "", fmt.Errorf("origin %q is not <scheme>://<host>[:<port>]", value)
+	}
+	if strings.Contains(strings.TrimPrefix(parsed.Host, "*."), "*") {
+		return "", fmt.Errorf("origin %q may only have a wildcard as its first label", value)
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host), nil
+}
+
+// CORSMiddleware lets the configured origins call the API from a browser,
+// with credentials. The allowed origin is echoed back, and Vary tells caches
+// the response depends on it. Preflights from other origins are refused.
+func CORSMiddleware(config CORSConfig) gin.HandlerFunc {
+	policy := corsPolicy{
+		origins: make(map[string]bool),
+		maxAge:  strconv.Itoa(int(config.MaxAge.Seconds())),
+	}
+	for _, origin := range config.AllowedOrigins {
+		scheme, host, _ := strings.Cut(origin, "://")
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.HasPrefix(host, "*."):
+			policy.patterns = append(policy.patterns, originPattern{prefix: scheme + "://", suffix: host[1:]})
+		default:
+			policy.origins[origin] = true
 		}
+	}
+
+	return policy.handle
+}
+
+func (p corsPolicy) handle(c *gin.Context) {
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
 
+	origin := c.GetHeader("Origin")
+	if origin == "" {
 		c.Next()
+		return
+	}
+
+	if !p.allows(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		// Without CORS headers the browser keeps the response from the page
+		c.Next()
+		return
+	}
+
+	if p.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if preflight {
+		header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+		header.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+		header.Set("Access-Control-Max-Age", p.maxAge)
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+
+	header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+	c.Next()
+}
+
+func (p corsPolicy) allows(origin string) bool {
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.matches(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p originPattern) matches(origin string) bool {
+	if !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	if len(origin) <= len(p.prefix)+len(p.suffix) {
+		return false
+	}
+	subdomain := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+	for _, r := range subdomain {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return false
+		}
 	}
+	return !strings.HasPrefix(subdomain, ".") && !strings.HasSuffix(subdomain, ".")
 }
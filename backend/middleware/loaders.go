@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/lema/repository"
+)
+
+// Loaders gives each request its own loaders over repo, so records a request
+// looks up by ID more than once, or for every item of a list, are fetched
+// together and only once. Like ReadYourWrites it relies on the engine's
+// ContextWithFallback.
+func Loaders(repo *repository.Container) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(repository.WithLoaders(ctx.Request.Context(), repo.NewLoaders()))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and spends from a bucket in one step, so instances
+// racing on a key can't both spend its last token. Times are in microseconds.
+// A bucket expires once it would be full again, which is the same as having
+// no bucket at all.
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = limit
+	ts = now
+end
+-- Clocks of instances drift apart, never refill backwards
+if now > ts then
+	tokens = math.min(limit, tokens + (now - ts) / interval)
+	ts = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil((limit - tokens) * interval / 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateStore keeps buckets in Redis, so every instance of the API shares
+// one limit per client
+type RedisRateStore struct {
+	client *redis.Client
+	prefix string
+}
+
+var _ RateStore = (*RedisRateStore)(nil)
+
+// NewRedisRateStore returns a store that namespaces its keys with prefix
+func NewRedisRateStore(client *redis.Client, prefix string) *RedisRateStore {
+	return &RedisRateStore{client: client, prefix: prefix}
+}
+
+func (s *RedisRateStore) Take(ctx context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error) {
+	interval := float64(policy.interval()) / float64(time.Microsecond)
+
+	result, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		policy.Limit, strconv.FormatFloat(interval, 'f', -1, 64), now.UnixMicro()).Slice()
+	if err != nil {
+		return RateDecision{}, err
+	}
+	if len(result) != 2 {
+		return RateDecision{}, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	allowed, _ := result[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(result[1]), 64)
+	if err != nil {
+		return RateDecision{}, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+	return newRateDecision(policy, allowed == 1, tokens), nil
+}
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"context"
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/tejiriaustin/lema/logger"
+)
+
+const (
+	// memoryRateShards is how many shards a MemoryRateStore splits its
+	// buckets over
+	memoryRateShards = 64
+	// evictionSamples is how many buckets a full shard compares to pick the
+	// one to drop
+	evictionSamples = 8
+
+	// sharedStoreTimeout bounds how long a request waits on the shared store
+	sharedStoreTimeout = 250 * time.Millisecond
+	// sharedStoreCooldown is how long limiting stays local after the shared
+	// store fails, so an outage doesn't slow every request down
+	sharedStoreCooldown = 10 * time.Second
+)
+
+type (
+	// RateStore keeps a token bucket per key. Take spends a token from key's
+	// bucket, which holds policy.Limit tokens and regains one every
+	// Window/Limit, if it has one.
+	RateStore interface {
+		Take(ctx context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error)
+	}
+
+	// RateDecision is the outcome of a Take
+	RateDecision struct {
+		Allowed   bool
+		Remaining int
+		// Reset is how long until the bucket is full again
+		Reset time.Duration
+		// RetryAfter is how long until a rejected client has a token
+		RetryAfter time.Duration
+	}
+
+	// MemoryRateStore keeps buckets in process, so each instance of the API
+	// limits on its own. Buckets are spread over shards by key: taking from an
+	// existing bucket only read locks its shard, and adding one write locks
+	// that shard alone.
+	MemoryRateStore struct {
+		shards []rateShard
+		seed   maphash.Seed
+		ttl    time.Duration
+		// shardCap is how many buckets a shard holds, 0 for no limit
+		shardCap int
+	}
+
+	rateShard struct {
+		sync.RWMutex
+		visitors map[string]*visitor
+	}
+
+	visitor struct {
+		limiter *rate.Limiter
+		// lastSeen is in Unix nanoseconds
+		lastSeen atomic.Int64
+	}
+
+	// FallbackRateStore takes from a shared store and switches to a local one
+	// while the shared store is failing
+	FallbackRateStore struct {
+		shared     RateStore
+		local      RateStore
+		lemaLogger logger.Logger
+		// downUntil is when to try the shared store again, in Unix nanoseconds
+		downUntil atomic.Int64
+	}
+)
+
+var (
+	_ RateStore = (*MemoryRateStore)(nil)
+	_ RateStore = (*FallbackRateStore)(nil)
+)
+
+// NewMemoryRateStore returns a store that forgets buckets idle for ttl once
+// Janitor runs. At most maxVisitors buckets are kept, 0 for no limit; past
+// that, adding a bucket drops one of the least recently seen of its shard.
+func NewMemoryRateStore(ttl time.Duration, maxVisitors int) *MemoryRateStore {
+	shards := memoryRateShards
+	if maxVisitors > 0 {
+		shards = min(shards, maxVisitors)
+	}
+
+	s := &MemoryRateStore{
+		shards: make([]rateShard, shards),
+		seed:   maphash.MakeSeed(),
+		ttl:    ttl,
+		// Rounded down, so the shards together never pass maxVisitors
+		shardCap: maxVisitors / shards,
+	}
+	for i := range s.shards {
+		s.shards[i].visitors = make(map[string]*visitor)
+	}
+	return s
+}
+
+// Janitor forgets buckets idle for longer than the ttl every interval until
+// ctx is done
+func (s *MemoryRateStore) Janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sweep(now)
+		}
+	}
+}
+
+// Len returns how many buckets the store holds
+func (s *MemoryRateStore) Len() int {
+	var n int
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.RLock()
+		n += len(shard.visitors)
+		shard.RUnlock()
+	}
+	return n
+}
+
+func (s *MemoryRateStore) Take(_ context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error) {
+	shard := &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+
+	shard.RLock()
+	v, ok := shard.visitors[key]
+	shard.RUnlock()
+	if !ok {
+		v = shard.add(key, policy, now, s.shardCap)
+	} else {
+		v.lastSeen.Store(now.UnixNano())
+	}
+
+	allowed := v.limiter.AllowN(now, 1)
+	return newRateDecision(policy, allowed, v.limiter.TokensAt(now)), nil
+}
+
+// sweep drops the buckets not seen since ttl before now
+func (s *MemoryRateStore) sweep(now time.Time) {
+	cutoff := now.Add(-s.ttl).UnixNano()
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.Lock()
+		for key, v := range shard.visitors {
+			if v.lastSeen.Load() < cutoff {
+				delete(shard.visitors, key)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+// add returns key's bucket, creating it if no other request beat us to it
+func (sh *rateShard) add(key string, policy RatePolicy, now time.Time, capacity int) *visitor {
+	sh.Lock()
+	defer sh.Unlock()
+
+	if v, ok := sh.visitors[key]; ok {
+		v.lastSeen.Store(now.UnixNano())
+		return v
+	}
+	if capacity > 0 && len(sh.visitors) >= capacity {
+		sh.evictLeastRecent()
+	}
+
+	v := &visitor{limiter: rate.NewLimiter(rate.Every(policy.interval()), policy.Limit)}
+	v.lastSeen.Store(now.UnixNano())
+	sh.visitors[key] = v
+	return v
+}
+
+// evictLeastRecent drops the bucket seen least recently among a few picked at
+// random, like Redis does, rather than scanning the whole shard on every add
+// once it is full
+func (sh *rateShard) evictLeastRecent() {
+	var (
+		oldestKey string
+		oldest    int64
+		sampled   int
+	)
+	for key, v := range sh.visitors {
+		if seen := v.lastSeen.Load(); sampled == 0 || seen < oldest {
+			oldestKey, oldest = key, seen
+		}
+		if sampled++; sampled == evictionSamples {
+			break
+		}
+	}
+	delete(sh.visitors, oldestKey)
+}
+
+// NewFallbackRateStore returns a store that takes from shared and, for a
+// while after shared fails or is slow, from local. Each instance then limits
+// on its own until shared is back.
+func NewFallbackRateStore(shared, local RateStore, lemaLogger logger.Logger) *FallbackRateStore {
+	return &FallbackRateStore{
+		shared:     shared,
+		local:      local,
+		lemaLogger: lemaLogger,
+	}
+}
+
+func (s *FallbackRateStore) Take(ctx context.Context, key string, policy RatePolicy, now time.Time) (RateDecision, error) {
+	if now.UnixNano() >= s.downUntil.Load() {
+		sharedCtx, cancel := context.WithTimeout(ctx, sharedStoreTimeout)
+		decision, err := s.shared.Take(sharedCtx, key, policy, now)
+		cancel()
+		if err == nil {
+			return decision, nil
+		}
+
+		// Only the request that notices the outage logs it
+		if s.downUntil.Swap(now.Add(sharedStoreCooldown).UnixNano()) <= now.UnixNano() {
+			s.lemaLogger.Warn("shared rate limit store failed, limiting locally",
+				logger.WithField("err", err),
+				logger.WithField("retry_in", sharedStoreCooldown))
+		}
+	}
+	return s.local.Take(ctx, key, policy, now)
+}
+
+// newRateDecision describes a bucket left with tokens after a take
+func newRateDecision(policy RatePolicy, allowed bool, tokens float64) RateDecision {
+	interval := float64(policy.interval())
+	decision := RateDecision{
+		Allowed:   allowed,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     time.Duration((float64(policy.Limit) - tokens) * interval),
+	}
+	if !allowed {
+		decision.RetryAfter = time.Duration((1 - tokens) * interval)
+	}
+	return decision
+}
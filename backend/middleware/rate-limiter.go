@@ -1,85 +1,187 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/models"
 )
 
-// RateLimiter manages rate limiting per visitor
-type RateLimiter struct {
-	sync.RWMutex
-	visitors map[string]*rate.Limiter
-	rate     rate.Limit
-	burst    int
-	ttl      time.Duration
-	lastSeen map[string]time.Time
-}
+// APIKeyHeader carries the key of an integration that is limited on its own
+// rather than together with everyone sharing its IP
+const APIKeyHeader = "X-API-Key"
 
-// NewRateLimiter creates a new rate limiter with specified requests/second and burst
-func NewRateLimiter(requestsPerSecond float64, burst int, ttl time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		visitors: make(map[string]*rate.Limiter),
-		lastSeen: make(map[string]time.Time),
-		rate:     rate.Limit(requestsPerSecond),
-		burst:    burst,
-		ttl:      ttl,
+type (
+	// RatePolicy lets each client make Limit requests per Window. Used requests
+	// come back steadily, one every Window/Limit, so a client never gets more
+	// than Limit in any Window.
+	RatePolicy struct {
+		// Name keeps the counts of policies sharing a limiter apart
+		Name   string
+		Limit  int
+		Window time.Duration
 	}
 
-	go rl.cleanupVisitors()
-	return rl
+	// RatePolicies are the policies the routes are limited by. Default covers
+	// every request, the others apply on top of it to their route groups.
+	RatePolicies struct {
+		Default RatePolicy
+		// Auth covers logins, verification and password resets
+		Auth RatePolicy
+		// Signup covers creating users
+		Signup RatePolicy
+		// User covers the routes that need a signed in user, counted per user
+		User RatePolicy
+	}
+
+	// RateLimiter counts requests per client and policy in a RateStore.
+	// Clients are told apart by user, then API key, then IP, see
+	// RateLimiter.key.
+	RateLimiter struct {
+		Policies RatePolicies
+
+		store RateStore
+		// apiKeys maps the hash of every accepted API key to its client key
+		apiKeys map[string]string
+	}
+)
+
+// ParseRatePolicy reads a policy written as <limit>/<window>, such as 10/1m.
+// An empty spec, "off" or "0" gives a disabled policy.
+func ParseRatePolicy(name, spec string) (RatePolicy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" || spec == "0" {
+		return RatePolicy{Name: name}, nil
+	}
+
+	count, window, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RatePolicy{}, fmt.Errorf("rate limit %q is not <limit>/<window>", spec)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit < 1 {
+		return RatePolicy{}, fmt.Errorf("rate limit %q needs a positive limit", spec)
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || duration < time.Second {
+		return RatePolicy{}, fmt.Errorf("rate limit %q needs a window of at least 1s", spec)
+	}
+	return RatePolicy{Name: name, Limit: limit, Window: duration}, nil
 }
 
-// getVisitor retrieves or creates a rate limiter for a visitor
-func (rl *RateLimiter) getVisitor(ip string) *rate.Limiter {
-	rl.Lock()
-	defer rl.Unlock()
+// Enabled reports whether the policy limits anything
+func (p RatePolicy) Enabled() bool {
+	return p.Limit > 0 && p.Window > 0
+}
 
-	limiter, exists := rl.visitors[ip]
-	if !exists {
-		limiter = rate.NewLimiter(rl.rate, rl.burst)
-		rl.visitors[ip] = limiter
+// LongestWindow returns the longest window of the enabled policies. A bucket
+// idle for that long is full again under every policy, so it is safe to
+// forget.
+func (p RatePolicies) LongestWindow() time.Duration {
+	var longest time.Duration
+	for _, policy := range []RatePolicy{p.Default, p.Auth, p.Signup, p.User} {
+		if policy.Enabled() {
+			longest = max(longest, policy.Window)
+		}
 	}
+	return longest
+}
 
-	rl.lastSeen[ip] = time.Now()
-	return limiter
+// interval is how long a used request takes to come back
+func (p RatePolicy) interval() time.Duration {
+	return p.Window / time.Duration(p.Limit)
 }
 
-// cleanupVisitors removes rate limiters for IPs that haven't been seen recently
-func (rl *RateLimiter) cleanupVisitors() {
-	for {
-		time.Sleep(time.Minute)
-
-		rl.Lock()
-		for ip, lastSeen := range rl.lastSeen {
-			if time.Since(lastSeen) > rl.ttl {
-				delete(rl.visitors, ip)
-				delete(rl.lastSeen, ip)
-			}
+// NewRateLimiter returns a limiter enforcing policies with the buckets in
+// store. Requests carrying one of apiKeys in APIKeyHeader are counted per key;
+// any other value of the header is ignored, so it can't be used to dodge the
+// limits.
+func NewRateLimiter(store RateStore, policies RatePolicies, apiKeys ...string) *RateLimiter {
+	rl := &RateLimiter{
+		Policies: policies,
+		store:    store,
+		apiKeys:  make(map[string]string, len(apiKeys)),
+	}
+	for _, apiKey := range apiKeys {
+		if apiKey = strings.TrimSpace(apiKey); apiKey != "" {
+			hash := hashAPIKey(apiKey)
+			rl.apiKeys[hash] = "key:" + hash[:16]
 		}
-		rl.Unlock()
 	}
+	return rl
 }
 
-// RateLimit middleware for Gin
-func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
+// RateLimit rejects requests over policy with 429 and reports the client's
+// standing in RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset. When
+// several policies cover a route, the headers show the one closest to
+// running out. Requests are let through if the store fails.
+func (rl *RateLimiter) RateLimit(policy RatePolicy) gin.HandlerFunc {
+	if !policy.Enabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		decision, err := rl.store.Take(c.Request.Context(), policy.Name+"|"+rl.key(c), policy, time.Now())
+		if err != nil {
+			c.Next()
+			return
+		}
 
-		limiter := rl.getVisitor(ip)
+		header := c.Writer.Header()
+		if current, err := strconv.Atoi(header.Get("RateLimit-Remaining")); err != nil || decision.Remaining <= current {
+			header.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+		}
 
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
+		if !decision.Allowed {
+			retryAfter := max(ceilSeconds(decision.RetryAfter), 1)
+			header.Set("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"error":       "rate limit exceeded",
-				"retry_after": time.Duration(1/float64(rl.rate)) * time.Second,
+				"retry_after": retryAfter,
 			})
-			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// key identifies the client: the signed in user once Authorize has run, then
+// an accepted API key, then the IP
+func (rl *RateLimiter) key(c *gin.Context) string {
+	if value, ok := c.Get(string(constants.ContextKeyAccountInfo)); ok {
+		if account, ok := value.(models.AccountInfo); ok && account.Id != "" {
+			return "user:" + account.Id
+		}
+	}
+	if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+		if key, ok := rl.apiKeys[hashAPIKey(apiKey)]; ok {
+			return key
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// ceilSeconds rounds d up to whole seconds, as the headers count in seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
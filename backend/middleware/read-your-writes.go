@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/lema/database"
+)
+
+// ReadYourWrites scopes replica routing to the request: reads go to replicas
+// until the request writes, then to the primary so it sees its own changes.
+// The engine needs ContextWithFallback so handlers passing *gin.Context as
+// the context reach the request's value.
+func ReadYourWrites() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(database.ReadYourWrites(ctx.Request.Context()))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	constants "github.com/tejiriaustin/lema/constants"
+)
+
+// RequestIDHeader carries the request's correlation ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits IDs taken from clients to what is safe to log and store
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestID keeps the X-Request-ID a proxy or client sent, or generates one,
+// stores it in the context for logs and the audit log, and echoes it in the
+// response so a caller can quote it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		c.Set(string(constants.ContextKeyRequestID), id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/suite"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/middleware"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type AuthorizeTestSuite struct {
+	testutils.BaseSuite
+	conf env.Environment
+}
+
+func TestAuthorize(t *testing.T) {
+	suite.Run(t, &AuthorizeTestSuite{
+		conf: env.NewEnvironment().SetEnv(constants.JwtSecret, "test-secret"),
+	})
+}
+
+func (suite *AuthorizeTestSuite) request(claims jwt.MapClaims) (*httptest.ResponseRecorder, *models.AccountInfo) {
+	gin.SetMode(gin.TestMode)
+
+	var account *models.AccountInfo
+	router := gin.New()
+	router.Use(middleware.Authorize(&suite.conf))
+	router.GET("/v1/me", func(c *gin.Context) {
+		info := c.MustGet(string(constants.ContextKeyAccountInfo)).(models.AccountInfo)
+		account = &info
+		c.Status(http.StatusNoContent)
+	})
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	suite.Require().NoError(err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w, account
+}
+
+func (suite *AuthorizeTestSuite) TestAcceptsAccessTokens() {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"typed":   {"id": "user-1", "full_name": "John", "email": "john@example.com", "role": "admin", "typ": constants.TokenTypeAccess, "exp": exp},
+		"untyped": {"id": "user-1", "full_name": "John", "email": "john@example.com", "role": "admin", "exp": exp},
+	} {
+		w, account := suite.request(claims)
+		suite.Equal(http.StatusNoContent, w.Code, name)
+		suite.Require().NotNil(account, name)
+		suite.Equal(models.AccountInfo{Id: "user-1", FullName: "John", Email: "john@example.com", Role: "admin"}, *account, name)
+	}
+}
+
+func (suite *AuthorizeTestSuite) TestRejectsOtherTokens() {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"sso login state": {"provider": "google", "state": "abc", "typ": constants.TokenTypeSSOState, "exp": exp},
+		"wrong type":      {"id": "user-1", "email": "john@example.com", "typ": constants.TokenTypeSSOState, "exp": exp},
+		"missing id":      {"email": "john@example.com", "exp": exp},
+		"id not a string": {"id": 42, "email": "john@example.com", "exp": exp},
+		"missing email":   {"id": "user-1", "exp": exp},
+	} {
+		w, account := suite.request(claims)
+		suite.Equal(http.StatusUnauthorized, w.Code, name)
+		suite.Nil(account, name)
+	}
+}
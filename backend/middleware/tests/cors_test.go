@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/middleware"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type CORSTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestCORS(t *testing.T) {
+	suite.Run(t, &CORSTestSuite{})
+}
+
+func (suite *CORSTestSuite) newRouter(origins ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	config := middleware.CORSConfig{MaxAge: 2 * time.Hour}
+	for _, value := range origins {
+		origin, err := middleware.ParseOrigin(value)
+		suite.Require().NoError(err)
+		config.AllowedOrigins = append(config.AllowedOrigins, origin)
+	}
+
+	router := gin.New()
+	router.Use(middleware.CORSMiddleware(config))
+	router.PATCH("/things", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func (suite *CORSTestSuite) request(router *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/things", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *CORSTestSuite) TestParseOrigin() {
+	for value, expected := range map[string]string{
+		"https://App.Lema.io/":  "https://app.lema.io",
+		"http://localhost:3000": "http://localhost:3000",
+		" https://*.lema.io ":   "https://*.lema.io",
+		"*":                     "*",
+	} {
+		origin, err := middleware.ParseOrigin(value)
+		suite.Require().NoError(err, value)
+		suite.Equal(expected, origin, value)
+	}
+
+	for _, value := range []string{"localhost:3000", "https://lema.io/app", "https://a.*.lema.io", "lema.io", "https://user@lema.io"} {
+		_, err := middleware.ParseOrigin(value)
+		suite.Error(err, value)
+	}
+}
+
+func (suite *CORSTestSuite) TestEchoesAllowedOrigin() {
+	router := suite.newRouter("https://app.lema.io")
+
+	w := suite.request(router, http.MethodPatch, "https://app.lema.io")
+	suite.Equal(http.StatusNoContent, w.Code)
+	suite.Equal("https://app.lema.io", w.Header().Get("Access-Control-Allow-Origin"))
+	suite.Equal("true", w.Header().Get("Access-Control-Allow-Credentials"))
+	suite.Contains(w.Header().Get("Access-Control-Expose-Headers"), middleware.RequestIDHeader)
+	suite.Contains(w.Header().Values("Vary"), "Origin")
+}
+
+func (suite *CORSTestSuite) TestPreflight() {
+	router := suite.newRouter("https://app.lema.io")
+
+	w := suite.request(router, http.MethodOptions, "https://app.lema.io")
+	suite.Equal(http.StatusNoContent, w.Code)
+	suite.Equal("https://app.lema.io", w.Header().Get("Access-Control-Allow-Origin"))
+	suite.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPatch)
+	suite.Equal("7200", w.Header().Get("Access-Control-Max-Age"))
+	suite.Subset(w.Header().Values("Vary"), []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"})
+
+	w = suite.request(router, http.MethodOptions, "https://evil.example")
+	suite.Equal(http.StatusForbidden, w.Code)
+	suite.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func (suite *CORSTestSuite) TestOtherOriginsGetNoCORSHeaders() {
+	router := suite.newRouter("https://app.lema.io")
+
+	for _, origin := range []string{"https://evil.example", "http://app.lema.io", "https://app.lema.io:8443", ""} {
+		w := suite.request(router, http.MethodPatch, origin)
+		suite.Equal(http.StatusNoContent, w.Code, origin)
+		suite.Empty(w.Header().Get("Access-Control-Allow-Origin"), origin)
+		suite.Contains(w.Header().Values("Vary"), "Origin", origin)
+	}
+}
+
+func (suite *CORSTestSuite) TestPatternsMatchSubdomains() {
+	router := suite.newRouter("https://*.lema.io")
+
+	for origin, allowed := range map[string]bool{
+		"https://staging.lema.io":              true,
+		"https://pr-12.preview.lema.io":        true,
+		"https://lema.io":                      false,
+		"http://staging.lema.io":               false,
+		"https://staging.lema.io.evil.example": false,
+		"https://evil-lema.io":                 false,
+	} {
+		w := suite.request(router, http.MethodPatch, origin)
+		if allowed {
+			suite.Equal(origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		} else {
+			suite.Empty(w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	}
+}
+
+func (suite *CORSTestSuite) TestAnyOriginIsWithoutCredentials() {
+	router := suite.newRouter("*")
+
+	w := suite.request(router, http.MethodPatch, "https://anyone.example")
+	suite.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+	suite.Empty(w.Header().Get("Access-Control-Allow-Credentials"))
+}
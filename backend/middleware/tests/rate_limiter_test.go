@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/middleware"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type RateLimiterTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestRateLimiter(t *testing.T) {
+	suite.Run(t, &RateLimiterTestSuite{})
+}
+
+// newRouter serves GET /limited under policy. A user header stands in for
+// Authorize by setting the account the limiter keys on.
+func (suite *RateLimiterTestSuite) newRouter(policy middleware.RatePolicy, apiKeys ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryRateStore(time.Hour, 0), middleware.RatePolicies{Default: policy}, apiKeys...)
+
+	router := gin.New()
+	router.GET("/limited", func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set(string(constants.ContextKeyAccountInfo), models.AccountInfo{Id: id})
+		}
+	}, limiter.RateLimit(limiter.Policies.Default), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func (suite *RateLimiterTestSuite) get(router *gin.Engine, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *RateLimiterTestSuite) TestParseRatePolicy() {
+	policy, err := middleware.ParseRatePolicy("signup", "5/1h")
+	suite.Require().NoError(err)
+	suite.Equal(middleware.RatePolicy{Name: "signup", Limit: 5, Window: time.Hour}, policy)
+
+	for _, spec := range []string{"", "off", "0"} {
+		policy, err := middleware.ParseRatePolicy("auth", spec)
+		suite.Require().NoError(err, spec)
+		suite.False(policy.Enabled(), spec)
+	}
+
+	for _, spec := range []string{"5", "x/1m", "0/1m", "5/soon", "5/10ms"} {
+		_, err := middleware.ParseRatePolicy("auth", spec)
+		suite.Error(err, spec)
+	}
+}
+
+func (suite *RateLimiterTestSuite) TestHeadersCountDown() {
+	router := suite.newRouter(middleware.RatePolicy{Name: "test", Limit: 3, Window: time.Minute})
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		w := suite.get(router, nil)
+		suite.Equal(http.StatusNoContent, w.Code)
+		suite.Equal("3", w.Header().Get("RateLimit-Limit"))
+		suite.Equal(strconv.Itoa(remaining), w.Header().Get("RateLimit-Remaining"))
+		suite.Equal("3;w=60", w.Header().Get("RateLimit-Policy"))
+	}
+
+	// A request comes back every 20s, so the full limit is a minute away
+	reset, err := strconv.Atoi(suite.get(router, nil).Header().Get("RateLimit-Reset"))
+	suite.Require().NoError(err)
+	suite.InDelta(60, reset, 1)
+}
+
+func (suite *RateLimiterTestSuite) TestRetryAfterIsWhenARequestComesBack() {
+	router := suite.newRouter(middleware.RatePolicy{Name: "test", Limit: 2, Window: time.Minute})
+
+	suite.get(router, nil)
+	suite.get(router, nil)
+	w := suite.get(router, nil)
+
+	suite.Equal(http.StatusTooManyRequests, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	suite.Require().NoError(err)
+	suite.InDelta(30, retryAfter, 1)
+	suite.JSONEq(`{"error":"rate limit exceeded","retry_after":`+strconv.Itoa(retryAfter)+`}`, w.Body.String())
+}
+
+func (suite *RateLimiterTestSuite) TestKeysByUserThenAPIKeyThenIP() {
+	router := suite.newRouter(middleware.RatePolicy{Name: "test", Limit: 1, Window: time.Hour}, "partner-key")
+
+	suite.Equal(http.StatusNoContent, suite.get(router, nil).Code)
+	suite.Equal(http.StatusTooManyRequests, suite.get(router, nil).Code)
+
+	// Each user and accepted key has a limit of its own behind the same IP
+	suite.Equal(http.StatusNoContent, suite.get(router, map[string]string{"X-Test-User": "alice"}).Code)
+	suite.Equal(http.StatusNoContent, suite.get(router, map[string]string{"X-Test-User": "bob"}).Code)
+	suite.Equal(http.StatusTooManyRequests, suite.get(router, map[string]string{"X-Test-User": "alice"}).Code)
+
+	suite.Equal(http.StatusNoContent, suite.get(router, map[string]string{middleware.APIKeyHeader: "partner-key"}).Code)
+	suite.Equal(http.StatusTooManyRequests, suite.get(router, map[string]string{middleware.APIKeyHeader: "partner-key"}).Code)
+
+	// Unknown keys count against the IP
+	suite.Equal(http.StatusTooManyRequests, suite.get(router, map[string]string{middleware.APIKeyHeader: "made-up"}).Code)
+}
+
+func (suite *RateLimiterTestSuite) TestDisabledPolicyAllowsEverything() {
+	router := suite.newRouter(middleware.RatePolicy{Name: "test"})
+
+	for i := 0; i < 20; i++ {
+		w := suite.get(router, nil)
+		suite.Equal(http.StatusNoContent, w.Code)
+		suite.Empty(w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func (suite *RateLimiterTestSuite) TestLongestWindowSkipsDisabledPolicies() {
+	policies := middleware.RatePolicies{
+		Default: middleware.RatePolicy{Name: "default", Limit: 10, Window: 2 * time.Second},
+		Auth:    middleware.RatePolicy{Name: "auth"},
+		Signup:  middleware.RatePolicy{Name: "signup", Limit: 5, Window: 24 * time.Hour},
+		User:    middleware.RatePolicy{Name: "user", Limit: 120, Window: time.Minute},
+	}
+	suite.Equal(24*time.Hour, policies.LongestWindow())
+	suite.Zero(middleware.RatePolicies{}.LongestWindow())
+}
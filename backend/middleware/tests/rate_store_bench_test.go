@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tejiriaustin/lema/middleware"
+)
+
+// BenchmarkMemoryRateStore takes from the store on every CPU at once, spread
+// over a number of clients. With many clients most takes hit a bucket that
+// already exists, as on a busy server. Past maxVisitors, most takes add a
+// bucket and evict another instead.
+func BenchmarkMemoryRateStore(b *testing.B) {
+	policy := middleware.RatePolicy{Name: "bench", Limit: 1000, Window: time.Second}
+
+	for _, bench := range []struct{ clients, maxVisitors int }{
+		{clients: 1},
+		{clients: 1000},
+		{clients: 100000},
+		{clients: 100000, maxVisitors: 100000},
+		{clients: 100000, maxVisitors: 10000},
+	} {
+		clients := bench.clients
+		b.Run(fmt.Sprintf("clients=%d/max=%d", clients, bench.maxVisitors), func(b *testing.B) {
+			store := middleware.NewMemoryRateStore(time.Hour, bench.maxVisitors)
+			keys := make([]string, clients)
+			for i := range keys {
+				keys[i] = "ip:" + strconv.Itoa(i)
+			}
+
+			var next atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				i := int(next.Add(7919))
+				for pb.Next() {
+					_, _ = store.Take(ctx, keys[i%clients], policy, time.Now())
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/middleware"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type RateStoreTestSuite struct {
+	testutils.BaseSuite
+	miniRedis *miniredis.Miniredis
+}
+
+func TestRateStore(t *testing.T) {
+	suite.Run(t, &RateStoreTestSuite{})
+}
+
+func (suite *RateStoreTestSuite) SetupTest() {
+	suite.miniRedis = miniredis.RunT(suite.T())
+}
+
+func (suite *RateStoreTestSuite) newRedisStore() *middleware.RedisRateStore {
+	client := redis.NewClient(&redis.Options{Addr: suite.miniRedis.Addr()})
+	suite.T().Cleanup(func() { _ = client.Close() })
+	return middleware.NewRedisRateStore(client, "test:")
+}
+
+func (suite *RateStoreTestSuite) stores() map[string]middleware.RateStore {
+	return map[string]middleware.RateStore{
+		"memory": middleware.NewMemoryRateStore(time.Hour, 0),
+		"redis":  suite.newRedisStore(),
+	}
+}
+
+func (suite *RateStoreTestSuite) TestTakeSpendsAndRefills() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 2, Window: time.Minute}
+	now := time.Now()
+
+	for name, store := range suite.stores() {
+		suite.Run(name, func() {
+			ctx := context.Background()
+
+			decision, err := store.Take(ctx, "ip:1", policy, now)
+			suite.Require().NoError(err)
+			suite.Equal(middleware.RateDecision{Allowed: true, Remaining: 1, Reset: 30 * time.Second}, decision)
+
+			decision, err = store.Take(ctx, "ip:1", policy, now)
+			suite.Require().NoError(err)
+			suite.Equal(middleware.RateDecision{Allowed: true, Remaining: 0, Reset: time.Minute}, decision)
+
+			decision, err = store.Take(ctx, "ip:1", policy, now.Add(10*time.Second))
+			suite.Require().NoError(err)
+			suite.False(decision.Allowed)
+			suite.InDelta(20*time.Second, decision.RetryAfter, float64(time.Millisecond))
+
+			// Other clients have buckets of their own
+			decision, err = store.Take(ctx, "ip:2", policy, now)
+			suite.Require().NoError(err)
+			suite.True(decision.Allowed)
+
+			decision, err = store.Take(ctx, "ip:1", policy, now.Add(30*time.Second))
+			suite.Require().NoError(err)
+			suite.True(decision.Allowed)
+		})
+	}
+}
+
+func (suite *RateStoreTestSuite) TestRedisIsSharedByInstances() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 3, Window: time.Hour}
+	first, second := suite.newRedisStore(), suite.newRedisStore()
+	now := time.Now()
+
+	var allowed int
+	for i := 0; i < 4; i++ {
+		for _, store := range []middleware.RateStore{first, second} {
+			decision, err := store.Take(context.Background(), "ip:1", policy, now)
+			suite.Require().NoError(err)
+			if decision.Allowed {
+				allowed++
+			}
+		}
+	}
+	suite.Equal(3, allowed)
+}
+
+func (suite *RateStoreTestSuite) TestRedisBucketsExpireOnceFull() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 2, Window: time.Minute}
+	store := suite.newRedisStore()
+
+	_, err := store.Take(context.Background(), "ip:1", policy, time.Now())
+	suite.Require().NoError(err)
+
+	// One token is back after 30s, plus a second of slack
+	ttl := suite.miniRedis.TTL("test:ip:1")
+	suite.InDelta(31*time.Second, ttl, float64(time.Second))
+
+	suite.miniRedis.FastForward(ttl)
+	suite.False(suite.miniRedis.Exists("test:ip:1"))
+}
+
+func (suite *RateStoreTestSuite) TestFallbackLimitsLocallyWhileRedisIsDown() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 2, Window: time.Hour}
+	lemaLogger := new(loggermocks.Logger)
+	lemaLogger.On("Warn", mock.Anything, mock.Anything, mock.Anything).Once()
+
+	store := middleware.NewFallbackRateStore(suite.newRedisStore(), middleware.NewMemoryRateStore(time.Hour, 0), lemaLogger)
+	suite.miniRedis.Close()
+
+	now := time.Now()
+	var allowed int
+	for i := 0; i < 3; i++ {
+		decision, err := store.Take(context.Background(), "ip:1", policy, now)
+		suite.Require().NoError(err)
+		if decision.Allowed {
+			allowed++
+		}
+	}
+
+	suite.Equal(2, allowed)
+	lemaLogger.AssertExpectations(suite.T())
+}
+
+func (suite *RateStoreTestSuite) TestFallbackReturnsToRedis() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 1, Window: time.Hour}
+	lemaLogger := new(loggermocks.Logger)
+	lemaLogger.On("Warn", mock.Anything, mock.Anything, mock.Anything).Once()
+
+	store := middleware.NewFallbackRateStore(suite.newRedisStore(), middleware.NewMemoryRateStore(time.Hour, 0), lemaLogger)
+	suite.miniRedis.SetError("LOADING")
+
+	now := time.Now()
+	_, err := store.Take(context.Background(), "ip:1", policy, now)
+	suite.Require().NoError(err)
+	suite.False(suite.miniRedis.Exists("test:ip:1"))
+
+	// The cooldown is over, so Redis is tried again and keeps the bucket
+	suite.miniRedis.SetError("")
+	decision, err := store.Take(context.Background(), "ip:1", policy, now.Add(time.Minute))
+	suite.Require().NoError(err)
+	suite.True(decision.Allowed)
+	suite.True(suite.miniRedis.Exists("test:ip:1"))
+}
+
+func (suite *RateStoreTestSuite) TestMemoryJanitorForgetsIdleClients() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 1, Window: time.Hour}
+	store := middleware.NewMemoryRateStore(50*time.Millisecond, 0)
+
+	_, err := store.Take(context.Background(), "ip:1", policy, time.Now())
+	suite.Require().NoError(err)
+	suite.Equal(1, store.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.Janitor(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	suite.Eventually(func() bool { return store.Len() == 0 }, time.Second, 10*time.Millisecond)
+
+	// A forgotten client starts over with a full bucket
+	decision, err := store.Take(context.Background(), "ip:1", policy, time.Now())
+	suite.Require().NoError(err)
+	suite.True(decision.Allowed)
+
+	cancel()
+	suite.Eventually(func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *RateStoreTestSuite) TestMemoryCapsClients() {
+	policy := middleware.RatePolicy{Name: "test", Limit: 1, Window: time.Hour}
+	store := middleware.NewMemoryRateStore(time.Hour, 100)
+	now := time.Now()
+
+	for i := 0; i < 1000; i++ {
+		_, err := store.Take(context.Background(), "ip:"+strconv.Itoa(i), policy, now.Add(time.Duration(i)))
+		suite.Require().NoError(err)
+		suite.LessOrEqual(store.Len(), 100)
+	}
+
+	// The newest client is kept, so it stays limited
+	decision, err := store.Take(context.Background(), "ip:999", policy, now.Add(time.Second))
+	suite.Require().NoError(err)
+	suite.False(decision.Allowed)
+}
@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/lema/database"
+)
+
+var (
+	ErrInvalidName = errors.New("migration name must contain letters or digits")
+
+	nameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Dialects lists the dialects that every migration is written for
+var Dialects = []database.Dialect{database.DialectSQLite, database.DialectPostgres, database.DialectMySQL}
+
+// Create writes empty up and down files for a new migration into the
+// directory of every dialect under dir and returns their paths. The version is
+// the UTC timestamp of now, so migrations written on separate branches do not
+// collide.
+func Create(dir, name string, now time.Time) ([]string, error) {
+	name = strings.Trim(nameSeparators.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+
+	base := now.UTC().Format("20060102150405") + "_" + name
+
+	var paths []string
+	for _, dialect := range Dialects {
+		dialectDir := filepath.Join(dir, string(dialect))
+		if err := os.MkdirAll(dialectDir, 0o755); err != nil {
+			return nil, err
+		}
+
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dialectDir, base+"."+direction+".sql")
+			header := fmt.Sprintf("-- %s (%s) for %s\n", base, direction, dialect)
+
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			_, err = file.WriteString(header)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	return paths, nil
+}
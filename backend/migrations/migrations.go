@@ -0,0 +1,519 @@
+// Package migrations applies the versioned SQL files embedded under sql/<dialect>
+// and records them in the schema_migrations table.
+//
+// A migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Versions are applied in ascending order and each
+// one runs in its own transaction together with its schema_migrations row.
+// Statements are separated by a semicolon at the end of a line. MySQL commits
+// DDL implicitly, so a migration that fails halfway there must be repaired by
+// hand before it is retried.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/lema/database"
+)
+
+//go:embed sql
+var embedded embed.FS
+
+const (
+	// DefaultLockTimeout is how long a run waits for another process to
+	// finish migrating before it gives up with ErrLocked
+	DefaultLockTimeout = time.Minute
+
+	// staleLockAfter is when a SQLite lock row is considered abandoned by a
+	// process that died while migrating. Every migration refreshes the row as
+	// it commits, so only a dead process leaves it this old.
+	staleLockAfter = 15 * time.Minute
+
+	lockPollInterval = 250 * time.Millisecond
+
+	// lockName identifies the advisory lock on MySQL, lockKey on Postgres
+	lockName = "lema_schema_migrations"
+	lockKey  = 7203114725302
+)
+
+var (
+	ErrLocked            = errors.New("another process is running migrations")
+	ErrPendingMigrations = errors.New("database has pending migrations")
+	ErrNoDownMigration   = errors.New("migration has no down file")
+	ErrUnknownMigration  = errors.New("applied migration is missing from the migration files")
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type (
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// Status reports whether a migration has been applied and when
+	Status struct {
+		Version   int64
+		Name      string
+		AppliedAt *time.Time
+	}
+
+	Migrator struct {
+		db          *sql.DB
+		dialect     database.Dialect
+		migrations  []Migration
+		LockTimeout time.Duration
+	}
+)
+
+func (s Status) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+// NewMigrator loads the embedded migrations for the dialect of dbConn
+func NewMigrator(dbConn *database.Client) (*Migrator, error) {
+	files, err := fs.Sub(embedded, "sql/"+string(dbConn.Dialect))
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dbConn.Dialect, err)
+	}
+	return NewMigratorFromFS(dbConn, files)
+}
+
+// NewMigratorFromFS loads the migrations found at the root of files
+func NewMigratorFromFS(dbConn *database.Client, files fs.FS) (*Migrator, error) {
+	sqlDB, err := dbConn.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %v", err)
+	}
+
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:          sqlDB,
+		dialect:     dbConn.Dialect,
+		migrations:  migrations,
+		LockTimeout: DefaultLockTimeout,
+	}, nil
+}
+
+func load(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file %q among migrations", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+
+		contents, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, newest first, up to steps
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(done))
+		for version := range done {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(reverted) == steps {
+				break
+			}
+
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("version %d: %w", version, ErrUnknownMigration)
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("%d_%s: %w", migration.Version, migration.Name, ErrNoDownMigration)
+			}
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status lists every known migration, plus any applied version whose files
+// are missing, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	done, err := m.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := done[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, record := range done {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, Status{Version: version, Name: record.Name, AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.Applied() {
+			continue
+		}
+		if migration, ok := m.find(status.Version); ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// CheckCurrent returns ErrPendingMigrations when any migration has not been applied
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d to apply, starting with %d_%s", ErrPendingMigrations, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// apply runs one direction of a migration and its bookkeeping in a transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%d_%s (%s): %w", migration.Version, migration.Name, direction, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)", m.placeholder(1), m.placeholder(2), m.placeholder(3)),
+			migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %s", m.placeholder(1)), migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := m.refreshLock(ctx, tx); err != nil {
+		return fmt.Errorf("failed to refresh the migration lock: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// refreshLock keeps the SQLite lock row fresh however long the migrations
+// take. It runs in the migration's transaction so the new time commits with
+// it: no other process can write before then, and one waiting to take over
+// a stale lock sees the refreshed row as soon as it can.
+func (m *Migrator) refreshLock(ctx context.Context, tx *sql.Tx) error {
+	if m.dialect != database.DialectSQLite {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "UPDATE schema_migrations_lock SET locked_at = ? WHERE id = 1", time.Now().Unix())
+	return err
+}
+
+type appliedRecord struct {
+	Name      string
+	AppliedAt time.Time
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedRecord, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedRecord{}
+	for rows.Next() {
+		var (
+			version int64
+			record  appliedRecord
+		)
+		if err := rows.Scan(&version, &record.Name, &record.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	timestamp := "datetime"
+	switch m.dialect {
+	case database.DialectPostgres:
+		timestamp = "timestamptz"
+	case database.DialectMySQL:
+		timestamp = "datetime(3)"
+	}
+
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version bigint NOT NULL PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    applied_at %s NOT NULL
+)`, timestamp))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// withLock runs fn on a single connection while holding the migration lock,
+// so concurrent deploys apply each migration exactly once
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		acquired, err := m.tryLock(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		if acquired {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+	defer m.unlock(context.WithoutCancel(ctx), conn)
+
+	return fn(conn)
+}
+
+// tryLock takes a session advisory lock on Postgres and MySQL, which the
+// server releases if the process dies. SQLite has none, so a row in
+// schema_migrations_lock stands in and is taken over once it goes stale.
+func (m *Migrator) tryLock(ctx context.Context, conn *sql.Conn) (bool, error) {
+	switch m.dialect {
+	case database.DialectPostgres:
+		var acquired bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&acquired)
+		return acquired, err
+	case database.DialectMySQL:
+		var acquired sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", lockName).Scan(&acquired)
+		return acquired.Valid && acquired.Int64 == 1, err
+	default:
+		if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations_lock (id integer NOT NULL PRIMARY KEY, locked_at bigint NOT NULL)"); err != nil {
+			return false, err
+		}
+		now := time.Now().Unix()
+		if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE locked_at < ?", now-int64(staleLockAfter.Seconds())); err != nil {
+			return false, err
+		}
+		result, err := conn.ExecContext(ctx, "INSERT OR IGNORE INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", now)
+		if err != nil {
+			return false, err
+		}
+		inserted, err := result.RowsAffected()
+		return inserted == 1, err
+	}
+}
+
+func (m *Migrator) unlock(ctx context.Context, conn *sql.Conn) {
+	switch m.dialect {
+	case database.DialectPostgres:
+		_, _ = conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+	case database.DialectMySQL:
+		_, _ = conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+	default:
+		_, _ = conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1")
+	}
+}
+
+func (m *Migrator) placeholder(position int) string {
+	if m.dialect == database.DialectPostgres {
+		return "$" + strconv.Itoa(position)
+	}
+	return "?"
+}
+
+// splitStatements breaks a script into statements at semicolons that end a
+// line. Comment lines are dropped since MySQL rejects a comment-only query.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+
+	flush := func() {
+		statement := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+		if strings.TrimSpace(statement) != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	flush()
+
+	return statements
+}
@@ -0,0 +1,43 @@
+-- Baseline schema: the tables AutoMigrate created before migrations were
+-- introduced. IF NOT EXISTS lets those databases adopt it; 0002 brings them
+-- up to date.
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` varchar(32),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `version` bigint unsigned,
+    `name` varchar(200) NOT NULL,
+    `username` varchar(100) NOT NULL,
+    `email` varchar(100) NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_users_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `posts` (
+    `id` varchar(32),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `version` bigint unsigned,
+    `user_id` varchar(32) NOT NULL,
+    `title` varchar(200) NOT NULL,
+    `body` text NOT NULL,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `fk_users_posts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `addresses` (
+    `id` varchar(32),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `version` bigint unsigned,
+    `user_id` varchar(32) NOT NULL,
+    `street` varchar(100) NOT NULL,
+    `city` varchar(100) NOT NULL,
+    `state` varchar(100) NOT NULL,
+    `zipcode` varchar(20) NOT NULL,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `fk_users_address` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
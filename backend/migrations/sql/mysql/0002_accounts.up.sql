@@ -0,0 +1,94 @@
+-- Record versions move from the version column AutoMigrate created to
+-- _version, keeping the value each row already had
+ALTER TABLE `users` ADD COLUMN `_version` bigint NOT NULL DEFAULT 0;
+UPDATE `users` SET `_version` = COALESCE(`version`, 0);
+ALTER TABLE `users` DROP COLUMN `version`;
+ALTER TABLE `posts` ADD COLUMN `_version` bigint NOT NULL DEFAULT 0;
+UPDATE `posts` SET `_version` = COALESCE(`version`, 0);
+ALTER TABLE `posts` DROP COLUMN `version`;
+ALTER TABLE `addresses` ADD COLUMN `_version` bigint NOT NULL DEFAULT 0;
+UPDATE `addresses` SET `_version` = COALESCE(`version`, 0);
+ALTER TABLE `addresses` DROP COLUMN `version`;
+
+-- Ids are UUIDs, which need 36 characters. The foreign keys are dropped
+-- while both sides of them change.
+ALTER TABLE `posts` DROP FOREIGN KEY `fk_users_posts`;
+ALTER TABLE `addresses` DROP FOREIGN KEY `fk_users_address`;
+ALTER TABLE `users` MODIFY `id` varchar(36);
+ALTER TABLE `posts` MODIFY `id` varchar(36), MODIFY `user_id` varchar(36) NOT NULL;
+ALTER TABLE `addresses` MODIFY `id` varchar(36), MODIFY `user_id` varchar(36) NOT NULL;
+ALTER TABLE `posts` ADD CONSTRAINT `fk_users_posts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE;
+ALTER TABLE `addresses` ADD CONSTRAINT `fk_users_address` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE;
+
+-- Roles, passwords, email verification, two-factor and lockout
+ALTER TABLE `users` ADD COLUMN `role` varchar(20) NOT NULL DEFAULT 'user';
+ALTER TABLE `users` ADD COLUMN `password_hash` varchar(100);
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime(3) NULL;
+ALTER TABLE `users` ADD COLUMN `totp_secret` varchar(255);
+ALTER TABLE `users` ADD COLUMN `totp_last_used_step` bigint;
+ALTER TABLE `users` ADD COLUMN `two_factor_enabled_at` datetime(3) NULL;
+ALTER TABLE `users` ADD COLUMN `failed_login_attempts` bigint;
+ALTER TABLE `users` ADD COLUMN `locked_until` datetime(3) NULL;
+
+CREATE TABLE IF NOT EXISTS `user_tokens` (
+    `id` varchar(36),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `_version` bigint NOT NULL DEFAULT 0,
+    `user_id` varchar(36) NOT NULL,
+    `purpose` varchar(50) NOT NULL,
+    `token_hash` varchar(64) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `used_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_user_tokens_token_hash` (`token_hash`),
+    INDEX `idx_user_tokens_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+    `id` varchar(36),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `_version` bigint NOT NULL DEFAULT 0,
+    `user_id` varchar(36) NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `used_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_recovery_codes_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `user_identities` (
+    `id` varchar(36),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `_version` bigint NOT NULL DEFAULT 0,
+    `user_id` varchar(36) NOT NULL,
+    `provider` varchar(50) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `email` varchar(100),
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_provider_subject` (`provider`, `subject`),
+    INDEX `idx_user_identities_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `login_history` (
+    `id` varchar(36),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `_version` bigint NOT NULL DEFAULT 0,
+    `user_id` varchar(36),
+    `email` varchar(100),
+    `ip_address` varchar(45),
+    `user_agent` varchar(255),
+    `method` varchar(20) NOT NULL,
+    `success` boolean NOT NULL,
+    `failure_reason` varchar(50),
+    PRIMARY KEY (`id`),
+    INDEX `idx_login_history_ip_address` (`ip_address`),
+    INDEX `idx_login_history_email` (`email`),
+    INDEX `idx_login_history_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,21 @@
+-- Audit log of writes made through the repositories. Entries are never
+-- changed or removed, which the triggers enforce.
+CREATE TABLE IF NOT EXISTS `audit_log` (
+    `id` varchar(36),
+    `created_at` datetime(3) NOT NULL,
+    `entity` varchar(64) NOT NULL,
+    `record_id` varchar(36) NOT NULL,
+    `action` varchar(16) NOT NULL,
+    `changes` text NOT NULL,
+    `record_version` bigint NOT NULL DEFAULT 0,
+    `actor_id` varchar(36),
+    `request_id` varchar(64),
+    PRIMARY KEY (`id`),
+    INDEX `idx_audit_log_record` (`entity`, `record_id`),
+    INDEX `idx_audit_log_actor_id` (`actor_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+DROP TRIGGER IF EXISTS `audit_log_no_update`;
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+DROP TRIGGER IF EXISTS `audit_log_no_delete`;
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
@@ -0,0 +1,91 @@
+-- Record versions move from the version column AutoMigrate created to
+-- _version, keeping the value each row already had
+ALTER TABLE "users" ADD COLUMN "_version" bigint NOT NULL DEFAULT 0;
+UPDATE "users" SET "_version" = COALESCE("version", 0);
+ALTER TABLE "users" DROP COLUMN "version";
+ALTER TABLE "posts" ADD COLUMN "_version" bigint NOT NULL DEFAULT 0;
+UPDATE "posts" SET "_version" = COALESCE("version", 0);
+ALTER TABLE "posts" DROP COLUMN "version";
+ALTER TABLE "addresses" ADD COLUMN "_version" bigint NOT NULL DEFAULT 0;
+UPDATE "addresses" SET "_version" = COALESCE("version", 0);
+ALTER TABLE "addresses" DROP COLUMN "version";
+
+-- Ids are UUIDs, which need 36 characters
+ALTER TABLE "users" ALTER COLUMN "id" TYPE varchar(36);
+ALTER TABLE "posts" ALTER COLUMN "id" TYPE varchar(36);
+ALTER TABLE "posts" ALTER COLUMN "user_id" TYPE varchar(36);
+ALTER TABLE "addresses" ALTER COLUMN "id" TYPE varchar(36);
+ALTER TABLE "addresses" ALTER COLUMN "user_id" TYPE varchar(36);
+
+-- Roles, passwords, email verification, two-factor and lockout
+ALTER TABLE "users" ADD COLUMN "role" varchar(20) NOT NULL DEFAULT 'user';
+ALTER TABLE "users" ADD COLUMN "password_hash" varchar(100);
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar(255);
+ALTER TABLE "users" ADD COLUMN "totp_last_used_step" bigint;
+ALTER TABLE "users" ADD COLUMN "two_factor_enabled_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "failed_login_attempts" bigint;
+ALTER TABLE "users" ADD COLUMN "locked_until" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "user_tokens" (
+    "id" varchar(36),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "_version" bigint NOT NULL DEFAULT 0,
+    "user_id" varchar(36) NOT NULL,
+    "purpose" varchar(50) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_tokens_token_hash" ON "user_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_user_tokens_user_id" ON "user_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" varchar(36),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "_version" bigint NOT NULL DEFAULT 0,
+    "user_id" varchar(36) NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "user_identities" (
+    "id" varchar(36),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "_version" bigint NOT NULL DEFAULT 0,
+    "user_id" varchar(36) NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "email" varchar(100),
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_provider_subject" ON "user_identities" ("provider", "subject");
+CREATE INDEX IF NOT EXISTS "idx_user_identities_user_id" ON "user_identities" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "login_history" (
+    "id" varchar(36),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "_version" bigint NOT NULL DEFAULT 0,
+    "user_id" varchar(36),
+    "email" varchar(100),
+    "ip_address" varchar(45),
+    "user_agent" varchar(255),
+    "method" varchar(20) NOT NULL,
+    "success" boolean NOT NULL,
+    "failure_reason" varchar(50),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_login_history_ip_address" ON "login_history" ("ip_address");
+CREATE INDEX IF NOT EXISTS "idx_login_history_email" ON "login_history" ("email");
+CREATE INDEX IF NOT EXISTS "idx_login_history_user_id" ON "login_history" ("user_id");
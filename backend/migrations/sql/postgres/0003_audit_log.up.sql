@@ -0,0 +1,20 @@
+-- Audit log of writes made through the repositories. Entries are never
+-- changed or removed, which the trigger enforces.
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "id" varchar(36),
+    "created_at" timestamptz NOT NULL,
+    "entity" varchar(64) NOT NULL,
+    "record_id" varchar(36) NOT NULL,
+    "action" varchar(16) NOT NULL,
+    "changes" text NOT NULL,
+    "record_version" bigint NOT NULL DEFAULT 0,
+    "actor_id" varchar(36),
+    "request_id" varchar(64),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_log_record" ON "audit_log" ("entity", "record_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_log_actor_id" ON "audit_log" ("actor_id");
+
+CREATE OR REPLACE FUNCTION "audit_log_append_only"() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'audit_log is append-only'; END; $$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS "audit_log_append_only" ON "audit_log";
+CREATE TRIGGER "audit_log_append_only" BEFORE UPDATE OR DELETE ON "audit_log" FOR EACH ROW EXECUTE PROCEDURE "audit_log_append_only"();
@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS `login_history`;
+DROP TABLE IF EXISTS `user_identities`;
+DROP TABLE IF EXISTS `recovery_codes`;
+DROP TABLE IF EXISTS `user_tokens`;
+
+ALTER TABLE `users` DROP COLUMN `locked_until`;
+ALTER TABLE `users` DROP COLUMN `failed_login_attempts`;
+ALTER TABLE `users` DROP COLUMN `two_factor_enabled_at`;
+ALTER TABLE `users` DROP COLUMN `totp_last_used_step`;
+ALTER TABLE `users` DROP COLUMN `totp_secret`;
+ALTER TABLE `users` DROP COLUMN `email_verified_at`;
+ALTER TABLE `users` DROP COLUMN `password_hash`;
+ALTER TABLE `users` DROP COLUMN `role`;
+
+ALTER TABLE `addresses` ADD COLUMN `version` integer;
+UPDATE `addresses` SET `version` = `_version`;
+ALTER TABLE `addresses` DROP COLUMN `_version`;
+ALTER TABLE `posts` ADD COLUMN `version` integer;
+UPDATE `posts` SET `version` = `_version`;
+ALTER TABLE `posts` DROP COLUMN `_version`;
+ALTER TABLE `users` ADD COLUMN `version` integer;
+UPDATE `users` SET `version` = `_version`;
+ALTER TABLE `users` DROP COLUMN `_version`;
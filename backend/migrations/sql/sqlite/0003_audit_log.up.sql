@@ -0,0 +1,19 @@
+-- Audit log of writes made through the repositories. Entries are never
+-- changed or removed, which the triggers enforce.
+CREATE TABLE IF NOT EXISTS `audit_log` (
+    `id` varchar(36),
+    `created_at` datetime NOT NULL,
+    `entity` varchar(64) NOT NULL,
+    `record_id` varchar(36) NOT NULL,
+    `action` varchar(16) NOT NULL,
+    `changes` text NOT NULL,
+    `record_version` bigint NOT NULL DEFAULT 0,
+    `actor_id` varchar(36),
+    `request_id` varchar(64),
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_audit_log_record` ON `audit_log` (`entity`, `record_id`);
+CREATE INDEX IF NOT EXISTS `idx_audit_log_actor_id` ON `audit_log` (`actor_id`);
+
+CREATE TRIGGER IF NOT EXISTS `audit_log_no_update` BEFORE UPDATE ON `audit_log` BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS `audit_log_no_delete` BEFORE DELETE ON `audit_log` BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
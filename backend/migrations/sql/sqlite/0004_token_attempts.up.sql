@@ -0,0 +1,3 @@
+-- Wrong codes entered against a two-factor challenge, which is discarded
+-- once there are too many
+ALTER TABLE `user_tokens` ADD COLUMN `attempts` integer NOT NULL DEFAULT 0;
@@ -0,0 +1,345 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/migrations"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+var schemaModels = []interface{}{
+	models.User{},
+	models.Post{},
+	models.Address{},
+	models.UserToken{},
+	models.RecoveryCode{},
+	models.UserIdentity{},
+	models.LoginHistory{},
+	models.AuditEntry{},
+}
+
+// The models as they were when AutoMigrate created the schema, before the
+// migrations existed
+type (
+	legacyShared struct {
+		ID        string     `gorm:"type:varchar(32);primaryKey"`
+		CreatedAt *time.Time `gorm:"created_at"`
+		UpdatedAt *time.Time `gorm:"updated_at"`
+		DeletedAt *time.Time `gorm:"deleted_at"`
+		Version   uint       `gorm:"_version"`
+	}
+
+	legacyUser struct {
+		Shared   legacyShared   `gorm:"embedded"`
+		Name     string         `gorm:"type:varchar(200);not null"`
+		Username string         `gorm:"type:varchar(100);not null"`
+		Email    string         `gorm:"type:varchar(100);uniqueIndex;not null"`
+		Address  *legacyAddress `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+		Posts    []legacyPost   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	}
+
+	legacyPost struct {
+		Shared legacyShared `gorm:"embedded"`
+		UserID string       `gorm:"type:varchar(200);not null"`
+		Title  string       `gorm:"type:varchar(200);not null"`
+		Body   string       `gorm:"type:text;not null"`
+	}
+
+	legacyAddress struct {
+		Shared  legacyShared `gorm:"embedded"`
+		UserID  string       `gorm:"type:varchar(32);not null"`
+		Street  string       `gorm:"type:varchar(100);not null"`
+		City    string       `gorm:"type:varchar(100);not null"`
+		State   string       `gorm:"type:varchar(100);not null"`
+		Zipcode string       `gorm:"type:varchar(20);not null"`
+	}
+)
+
+func (legacyUser) TableName() string    { return "users" }
+func (legacyPost) TableName() string    { return "posts" }
+func (legacyAddress) TableName() string { return "addresses" }
+
+type MigrationsTestSuite struct {
+	testutils.BaseSuite
+	dbConn *database.Client
+}
+
+func TestMigrations(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
+
+func (suite *MigrationsTestSuite) SetupTest() {
+	suite.dbConn = testutils.NewTestDatabase(suite.T(), "migrations")
+
+	tables := append([]interface{}{"schema_migrations", "schema_migrations_lock", "widgets", "gadgets", "lock_seen"}, schemaModels...)
+	suite.Require().NoError(suite.dbConn.DB.Migrator().DropTable(tables...))
+}
+
+func (suite *MigrationsTestSuite) fakeMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_widgets.up.sql": {Data: []byte(`-- two statements in one file
+CREATE TABLE widgets (
+    id integer NOT NULL PRIMARY KEY
+);
+INSERT INTO widgets (id) VALUES (1);
+`)},
+		"0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;\n")},
+		"0002_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id integer NOT NULL PRIMARY KEY);\n")},
+		"0002_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets;\n")},
+	}
+}
+
+func (suite *MigrationsTestSuite) TestEmbeddedBaselineMatchesModels() {
+	ctx := context.Background()
+
+	migrator, err := migrations.NewMigrator(suite.dbConn)
+	suite.Require().NoError(err)
+	suite.ErrorIs(migrator.CheckCurrent(ctx), migrations.ErrPendingMigrations)
+
+	applied, err := migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.NotEmpty(applied)
+	suite.NoError(migrator.CheckCurrent(ctx))
+
+	suite.assertSchemaMatchesModels()
+	dbMigrator := suite.dbConn.DB.Migrator()
+
+	// The audit log only takes inserts
+	entry := models.AuditEntry{ID: "entry", Entity: "users", RecordID: "user", Action: models.AuditActionCreate, Changes: "{}"}
+	now := time.Now().UTC()
+	entry.CreatedAt = &now
+	suite.Require().NoError(suite.dbConn.DB.Create(&entry).Error)
+	suite.Error(suite.dbConn.DB.Model(&entry).Update("action", models.AuditActionDelete).Error)
+	suite.Error(suite.dbConn.DB.Delete(&entry).Error)
+
+	again, err := migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.Empty(again)
+
+	reverted, err := migrator.Down(ctx, len(applied))
+	suite.Require().NoError(err)
+	suite.Len(reverted, len(applied))
+	suite.False(dbMigrator.HasTable(&models.User{}))
+}
+
+func (suite *MigrationsTestSuite) TestUpgradesAutoMigratedDatabase() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.dbConn.DB.AutoMigrate(&legacyUser{}, &legacyPost{}, &legacyAddress{}))
+	user := legacyUser{
+		Shared:   legacyShared{ID: "user", Version: 3},
+		Name:     "Ada Lovelace",
+		Username: "ada",
+		Email:    "ada@example.com",
+		Address:  &legacyAddress{Shared: legacyShared{ID: "address", Version: 1}, Street: "1 Main St", City: "London", State: "London", Zipcode: "N1"},
+		Posts:    []legacyPost{{Shared: legacyShared{ID: "post", Version: 2}, Title: "Notes", Body: "On the engine"}},
+	}
+	suite.Require().NoError(suite.dbConn.DB.Create(&user).Error)
+
+	migrator, err := migrations.NewMigrator(suite.dbConn)
+	suite.Require().NoError(err)
+	applied, err := migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.NotEmpty(applied)
+	suite.assertSchemaMatchesModels()
+
+	// Rows keep their versions, and users pick up the defaults of the new columns
+	var upgraded models.User
+	suite.Require().NoError(suite.dbConn.DB.Preload("Address").Preload("Posts").First(&upgraded, "id = ?", "user").Error)
+	suite.Equal(uint(3), upgraded.Version)
+	suite.Equal(models.RoleUser, upgraded.Role)
+	suite.Require().NotNil(upgraded.Address)
+	suite.Equal(uint(1), upgraded.Address.Version)
+	suite.Require().Len(upgraded.Posts, 1)
+	suite.Equal(uint(2), upgraded.Posts[0].Version)
+	suite.False(suite.dbConn.DB.Migrator().HasColumn("users", "version"))
+
+	// Going back to the baseline restores the schema AutoMigrate created
+	_, err = migrator.Down(ctx, len(applied)-1)
+	suite.Require().NoError(err)
+	var version uint
+	suite.Require().NoError(suite.dbConn.DB.Raw("SELECT version FROM users WHERE id = ?", "user").Scan(&version).Error)
+	suite.Equal(uint(3), version)
+	suite.False(suite.dbConn.DB.Migrator().HasColumn("users", "_version"))
+}
+
+// assertSchemaMatchesModels checks every column the models map exists, or the
+// SQL has drifted from the code
+func (suite *MigrationsTestSuite) assertSchemaMatchesModels() {
+	dbMigrator := suite.dbConn.DB.Migrator()
+	for _, model := range schemaModels {
+		stmt := suite.dbConn.DB.Model(model).Statement
+		suite.Require().NoError(stmt.Parse(model))
+		suite.True(dbMigrator.HasTable(model), stmt.Schema.Table)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				suite.True(dbMigrator.HasColumn(model, field.DBName), "%s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func (suite *MigrationsTestSuite) TestUpDownAndStatus() {
+	ctx := context.Background()
+
+	migrator, err := migrations.NewMigratorFromFS(suite.dbConn, suite.fakeMigrations())
+	suite.Require().NoError(err)
+
+	applied, err := migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.Len(applied, 2)
+
+	var count int64
+	suite.Require().NoError(suite.dbConn.DB.Table("widgets").Count(&count).Error)
+	suite.Equal(int64(1), count)
+
+	statuses, err := migrator.Status(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(statuses, 2)
+	suite.Equal("widgets", statuses[0].Name)
+	suite.True(statuses[0].Applied())
+	suite.True(statuses[1].Applied())
+
+	reverted, err := migrator.Down(ctx, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(reverted, 1)
+	suite.Equal(int64(2), reverted[0].Version)
+	suite.False(suite.dbConn.DB.Migrator().HasTable("gadgets"))
+	suite.True(suite.dbConn.DB.Migrator().HasTable("widgets"))
+
+	pending, err := migrator.Pending(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(pending, 1)
+	suite.Equal("gadgets", pending[0].Name)
+	suite.ErrorIs(migrator.CheckCurrent(ctx), migrations.ErrPendingMigrations)
+}
+
+func (suite *MigrationsTestSuite) TestFailedMigrationIsNotRecorded() {
+	ctx := context.Background()
+
+	files := suite.fakeMigrations()
+	files["0002_gadgets.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE gadgets (id integer NOT NULL PRIMARY KEY);\nTHIS IS NOT SQL;\n")}
+
+	migrator, err := migrations.NewMigratorFromFS(suite.dbConn, files)
+	suite.Require().NoError(err)
+
+	applied, err := migrator.Up(ctx)
+	suite.Error(err)
+	suite.Len(applied, 1)
+
+	pending, err := migrator.Pending(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(pending, 1)
+	suite.Equal(int64(2), pending[0].Version)
+}
+
+func (suite *MigrationsTestSuite) TestInvalidMigrationFiles() {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "unexpected file name",
+			files: fstest.MapFS{"widgets.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name:  "down without up",
+			files: fstest.MapFS{"0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")}},
+		},
+		{
+			name: "version used twice",
+			files: fstest.MapFS{
+				"0001_widgets.up.sql": {Data: []byte("SELECT 1;")},
+				"0001_gadgets.up.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			_, err := migrations.NewMigratorFromFS(suite.dbConn, tc.files)
+			suite.Error(err)
+		})
+	}
+}
+
+func (suite *MigrationsTestSuite) TestLockedByAnotherProcess() {
+	if suite.dbConn.Dialect != database.DialectSQLite {
+		suite.T().Skip("the lock row only exists on sqlite")
+	}
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.dbConn.DB.Exec("CREATE TABLE schema_migrations_lock (id integer NOT NULL PRIMARY KEY, locked_at bigint NOT NULL)").Error)
+	suite.Require().NoError(suite.dbConn.DB.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", time.Now().Unix()).Error)
+
+	migrator, err := migrations.NewMigratorFromFS(suite.dbConn, suite.fakeMigrations())
+	suite.Require().NoError(err)
+	migrator.LockTimeout = 300 * time.Millisecond
+
+	_, err = migrator.Up(ctx)
+	suite.ErrorIs(err, migrations.ErrLocked)
+
+	// A lock left behind by a crashed process is taken over once stale
+	suite.Require().NoError(suite.dbConn.DB.Exec("UPDATE schema_migrations_lock SET locked_at = ?", time.Now().Add(-time.Hour).Unix()).Error)
+	applied, err := migrator.Up(ctx)
+	suite.Require().NoError(err)
+	suite.Len(applied, 2)
+}
+
+func (suite *MigrationsTestSuite) TestLockIsKeptFreshWhileMigrating() {
+	if suite.dbConn.Dialect != database.DialectSQLite {
+		suite.T().Skip("the lock row only exists on sqlite")
+	}
+
+	// The first migration ages the lock as one running past staleLockAfter
+	// would, and the second records the lock another process would see
+	files := fstest.MapFS{
+		"0001_age_lock.up.sql":    {Data: []byte("UPDATE schema_migrations_lock SET locked_at = 0;\n")},
+		"0001_age_lock.down.sql":  {Data: []byte("")},
+		"0002_lock_seen.up.sql":   {Data: []byte("CREATE TABLE lock_seen AS SELECT locked_at FROM schema_migrations_lock;\n")},
+		"0002_lock_seen.down.sql": {Data: []byte("DROP TABLE lock_seen;\n")},
+	}
+
+	migrator, err := migrations.NewMigratorFromFS(suite.dbConn, files)
+	suite.Require().NoError(err)
+
+	started := time.Now().Unix()
+	_, err = migrator.Up(context.Background())
+	suite.Require().NoError(err)
+
+	var lockedAt int64
+	suite.Require().NoError(suite.dbConn.DB.Raw("SELECT locked_at FROM lock_seen").Scan(&lockedAt).Error)
+	suite.GreaterOrEqual(lockedAt, started, "the lock was refreshed when the first migration committed")
+}
+
+func (suite *MigrationsTestSuite) TestCreate() {
+	dir := suite.T().TempDir()
+	now := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	paths, err := migrations.Create(dir, "Add Post Tags!", now)
+	suite.Require().NoError(err)
+	suite.Len(paths, 2*len(migrations.Dialects))
+	suite.Contains(paths, filepath.Join(dir, "postgres", "20250304050607_add_post_tags.up.sql"))
+
+	migrator, err := migrations.NewMigratorFromFS(suite.dbConn, os.DirFS(filepath.Join(dir, string(suite.dbConn.Dialect))))
+	suite.Require().NoError(err)
+	pending, err := migrator.Pending(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(pending, 1)
+	suite.Equal(int64(20250304050607), pending[0].Version)
+
+	_, err = migrations.Create(dir, "Add Post Tags!", now)
+	suite.Error(err, "existing files must not be overwritten")
+
+	_, err = migrations.Create(dir, "!!!", now)
+	suite.ErrorIs(err, migrations.ErrInvalidName)
+}
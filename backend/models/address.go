@@ -7,13 +7,33 @@ import (
 
 type Address struct {
 	Shared  `gorm:"embedded"`
-	UserID  string `json:"user_id" gorm:"type:varchar(32);not null"`
+	UserID  string `json:"user_id" gorm:"type:varchar(36);not null"`
 	Street  string `json:"street" gorm:"type:varchar(100);not null"`
 	City    string `json:"city" gorm:"type:varchar(100);not null"`
 	State   string `json:"state" gorm:"type:varchar(100);not null"`
 	Zipcode string `json:"zipcode" gorm:"type:varchar(20);not null"`
 }
 
+// AddressSummary is an address as listings show them
+type AddressSummary struct {
+	ID      string
+	Street  string
+	City    string
+	State   string
+	Zipcode string
+}
+
+// AddressSummaryColumns are the columns an AddressSummary is built from
+var AddressSummaryColumns = []string{"street", "city", "state", "zipcode"}
+
+func (Address) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "user_id")
+}
+
+func (Address) SoftDeletes() bool {
+	return true
+}
+
 func (a *Address) String() string {
 	return fmt.Sprintf("%s, %s, %s, %s", a.Street, a.City, a.State, a.Zipcode)
 }
@@ -23,3 +43,18 @@ func (a *Address) PreValidate() {
 		a.ID = uuid.New().String()
 	}
 }
+
+// Summary returns the fields of a that listings show, or nil without an
+// address
+func (a *Address) Summary() *AddressSummary {
+	if a == nil {
+		return nil
+	}
+	return &AddressSummary{
+		ID:      a.ID,
+		Street:  a.Street,
+		City:    a.City,
+		State:   a.State,
+		Zipcode: a.Zipcode,
+	}
+}
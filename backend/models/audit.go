@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+type (
+	// AuditEntry records one write to one record: who made it, in which
+	// request, and how each column changed. Entries are only ever inserted,
+	// so the model has none of the bookkeeping columns of Shared.
+	AuditEntry struct {
+		ID            string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+		CreatedAt     *time.Time `json:"created_at" gorm:"not null"`
+		Entity        string     `json:"entity" gorm:"type:varchar(64);not null;index:idx_audit_log_record,priority:1"`
+		RecordID      string     `json:"record_id" gorm:"type:varchar(36);not null;index:idx_audit_log_record,priority:2"`
+		Action        string     `json:"action" gorm:"type:varchar(16);not null"`
+		Changes       string     `json:"changes" gorm:"type:text;not null"`
+		RecordVersion uint       `json:"record_version" gorm:"type:bigint;not null;default:0"`
+		ActorID       string     `json:"actor_id" gorm:"type:varchar(36);index"`
+		RequestID     string     `json:"request_id" gorm:"type:varchar(64)"`
+	}
+
+	// AuditExempt is implemented by models whose writes are not audited,
+	// such as the audit log itself
+	AuditExempt interface {
+		AuditExempt() bool
+	}
+)
+
+var _ Models = AuditEntry{}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+func (e AuditEntry) GetID() string {
+	return e.ID
+}
+
+func (e AuditEntry) GetVersion() uint {
+	return e.RecordVersion
+}
+
+func (e AuditEntry) GetCreatedAt() *time.Time {
+	return e.CreatedAt
+}
+
+func (AuditEntry) AllowedColumns() []string {
+	return []string{"id", "created_at", "entity", "record_id", "action", "actor_id", "request_id"}
+}
+
+func (AuditEntry) AuditExempt() bool {
+	return true
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	Shared   `gorm:"embedded"`
+	UserID   string `json:"user_id" gorm:"type:varchar(36);index;not null"`
+	Provider string `json:"provider" gorm:"type:varchar(50);uniqueIndex:idx_provider_subject;not null"`
+	Subject  string `json:"subject" gorm:"type:varchar(255);uniqueIndex:idx_provider_subject;not null"`
+	Email    string `json:"email" gorm:"type:varchar(100)"`
+}
+
+func (UserIdentity) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "user_id", "provider", "subject", "email")
+}
+
+func (i *UserIdentity) PreValidate() {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+
+	if i.CreatedAt == nil {
+		now := time.Now().UTC()
+		i.CreatedAt = &now
+	}
+
+	if i.Version > 0 {
+		i.Version++
+	} else {
+		i.Version = 1
+	}
+}
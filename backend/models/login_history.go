@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LoginMethodPassword = "password"
+	LoginMethodOIDC     = "oidc"
+
+	LoginFailureInvalidCredentials  = "invalid_credentials"
+	LoginFailureAccountLocked       = "account_locked"
+	LoginFailureTooManyAttempts     = "too_many_attempts"
+	LoginFailureInvalidTwoFactor    = "invalid_two_factor_code"
+	LoginFailureInvalidSSOState     = "invalid_sso_state"
+	LoginFailureSSOEmailNotVerified = "sso_email_not_verified"
+	LoginFailureSSOAccountNotFound  = "sso_account_not_found"
+	LoginFailureSSOFailed           = "sso_failed"
+)
+
+// LoginHistory records a single login attempt. UserID is empty when the
+// email didn't match an account.
+type LoginHistory struct {
+	Shared        `gorm:"embedded"`
+	UserID        string `json:"user_id" gorm:"type:varchar(36);index"`
+	Email         string `json:"email" gorm:"type:varchar(100);index"`
+	IPAddress     string `json:"ip_address" gorm:"type:varchar(45);index"`
+	UserAgent     string `json:"user_agent" gorm:"type:varchar(255)"`
+	Method        string `json:"method" gorm:"type:varchar(20);not null"`
+	Success       bool   `json:"success" gorm:"not null"`
+	FailureReason string `json:"failure_reason" gorm:"type:varchar(50)"`
+}
+
+func (LoginHistory) TableName() string {
+	return "login_history"
+}
+
+func (LoginHistory) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "user_id", "email", "ip_address", "success")
+}
+
+func (h *LoginHistory) PreValidate() {
+	if h.ID == "" {
+		h.ID = uuid.New().String()
+	}
+
+	if h.CreatedAt == nil {
+		now := time.Now().UTC()
+		h.CreatedAt = &now
+	}
+
+	if h.Version > 0 {
+		h.Version++
+	} else {
+		h.Version = 1
+	}
+}
+
+// AuditExempt keeps login attempts out of the audit log, which they would
+// only repeat
+func (LoginHistory) AuditExempt() bool {
+	return true
+}
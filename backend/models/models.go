@@ -10,25 +10,45 @@ type (
 	Models interface {
 		GetID() string
 		GetVersion() uint
+		GetCreatedAt() *time.Time
+
+		// AllowedColumns lists the columns that repository queries may filter and sort on
+		AllowedColumns() []string
 	}
 
 	PreValidator interface {
 		PreValidate()
 	}
 
+	// Redacter is implemented by models holding credentials, which Redact
+	// clears before a record is kept outside the database, e.g. in a cache
+	Redacter interface {
+		Redact()
+	}
+
+	// SoftDeleter is implemented by models whose records are only marked
+	// deleted, so they can be restored until the purge job removes them
+	SoftDeleter interface {
+		SoftDeletes() bool
+	}
+
 	AccountInfo struct {
 		Id       string `json:"id"`
 		FullName string `json:"full_name"`
 		Email    string `json:"email"`
+		Role     string `json:"role"`
 	}
 )
 
 type Shared struct {
-	ID        string     `json:"id" gorm:"type:varchar(32);primaryKey"`
+	ID        string     `json:"id" gorm:"type:varchar(36);primaryKey"`
 	CreatedAt *time.Time `json:"created_at" gorm:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at" gorm:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at" gorm:"deleted_at"`
-	Version   uint       `json:"version" gorm:"_version"`
+	// Version maps to _version. Until the column was named explicitly gorm
+	// ignored the bare tag and stored versions in a column called version,
+	// which migration 0002_accounts copies across.
+	Version uint `json:"version" gorm:"column:_version;type:bigint;not null;default:0"`
 }
 
 func (m Shared) PreValidate() {}
@@ -43,6 +63,14 @@ func (m Shared) GetVersion() uint {
 	return m.Version
 }
 
+func (m Shared) GetCreatedAt() *time.Time {
+	return m.CreatedAt
+}
+
+func (m Shared) AllowedColumns() []string {
+	return []string{"id", "created_at", "updated_at"}
+}
+
 func (m Shared) BeforeCreate(tx *gorm.DB) error {
 	if m.ID == "" {
 		m.ID = uuid.New().String()
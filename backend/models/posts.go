@@ -12,6 +12,14 @@ type Post struct {
 	Body   string `json:"body" gorm:"type:text;not null"`
 }
 
+func (Post) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "user_id", "title")
+}
+
+func (Post) SoftDeletes() bool {
+	return true
+}
+
 func (p *Post) PreValidate() {
 	if p.ID == "" {
 		p.ID = uuid.New().String()
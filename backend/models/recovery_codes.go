@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode is a single-use fallback for a user's authenticator app.
+// Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	Shared   `gorm:"embedded"`
+	UserID   string     `json:"user_id" gorm:"type:varchar(36);index;not null"`
+	CodeHash string     `json:"-" gorm:"type:varchar(64);not null"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+func (RecoveryCode) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "user_id", "code_hash", "used_at")
+}
+
+func (r *RecoveryCode) PreValidate() {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+
+	if r.CreatedAt == nil {
+		now := time.Now().UTC()
+		r.CreatedAt = &now
+	}
+
+	if r.Version > 0 {
+		r.Version++
+	} else {
+		r.Version = 1
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	TokenPurposeTwoFactorLogin    TokenPurpose = "two_factor_login"
+)
+
+// UserToken is a single-use secret sent to a user out of band.
+// Only the SHA-256 hash of the secret is ever stored.
+type UserToken struct {
+	Shared    `gorm:"embedded"`
+	UserID    string       `json:"user_id" gorm:"type:varchar(36);index;not null"`
+	Purpose   TokenPurpose `json:"purpose" gorm:"type:varchar(50);not null"`
+	TokenHash string       `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time    `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time   `json:"used_at"`
+	// Attempts counts wrong codes entered against a two-factor challenge
+	Attempts int `json:"-" gorm:"not null;default:0"`
+}
+
+func (UserToken) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "user_id", "purpose", "token_hash", "expires_at", "used_at", "attempts")
+}
+
+func (t *UserToken) PreValidate() {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+
+	if t.CreatedAt == nil {
+		now := time.Now().UTC()
+		t.CreatedAt = &now
+	}
+
+	if t.Version > 0 {
+		t.Version++
+	} else {
+		t.Version = 1
+	}
+}
+
+// IsUsable reports whether the token can still be redeemed at the given time
+func (t *UserToken) IsUsable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -5,6 +5,11 @@ import (
 	"time"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	Shared   `gorm:"embedded"`
 	Name     string   `json:"name" gorm:"type:varchar(200);not null"`
@@ -12,6 +17,75 @@ type User struct {
 	Email    string   `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
 	Address  *Address `json:"address" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	Posts    []Post   `json:"posts,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Role     string   `json:"role" gorm:"type:varchar(20);not null;default:'user'"`
+
+	PasswordHash    string     `json:"-" gorm:"type:varchar(100)"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	TotpSecret         string     `json:"-" gorm:"type:varchar(255)"`
+	TotpLastUsedStep   int64      `json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at"`
+
+	FailedLoginAttempts int        `json:"-"`
+	LockedUntil         *time.Time `json:"-"`
+}
+
+// UserSummary is a user as listings show them. Listings load only these
+// columns, so they hand out a summary rather than a partial User.
+type UserSummary struct {
+	ID            string
+	Name          string
+	Email         string
+	EmailVerified bool
+	Address       *AddressSummary
+}
+
+// UserSummaryColumns are the columns a UserSummary is built from
+var UserSummaryColumns = []string{"name", "email", "email_verified_at"}
+
+// Summary returns the fields of u that listings show
+func (u *User) Summary() *UserSummary {
+	return &UserSummary{
+		ID:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.IsEmailVerified(),
+		Address:       u.Address.Summary(),
+	}
+}
+
+// Redact clears the password, two-factor and lockout state of u, which must
+// only be read from the database
+func (u *User) Redact() {
+	u.PasswordHash = ""
+	u.TotpSecret = ""
+	u.TotpLastUsedStep = 0
+	u.FailedLoginAttempts = 0
+	u.LockedUntil = nil
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+func (User) AllowedColumns() []string {
+	return append(Shared{}.AllowedColumns(), "name", "email", "role", "email_verified_at", "locked_until")
+}
+
+func (User) SoftDeletes() bool {
+	return true
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
 }
 
 func (u *User) PreValidate() {
@@ -24,6 +98,10 @@ func (u *User) PreValidate() {
 		u.CreatedAt = &now
 	}
 
+	if u.Role == "" {
+		u.Role = RoleUser
+	}
+
 	if u.Version > 0 {
 		u.Version++
 	} else {
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/tejiriaustin/lema/database"
+)
+
+// TimeBucket is the length of the periods ByTime groups timestamps into
+type TimeBucket string
+
+const (
+	Day TimeBucket = "day"
+	// Week starts on Monday
+	Week TimeBucket = "week"
+)
+
+// BucketKeyFormat is how ByTime keys are formatted: the UTC date the day or
+// week starts on
+const BucketKeyFormat = "2006-01-02"
+
+type (
+	// GroupBy is what CountBy groups records by, made with ByColumn or ByTime
+	GroupBy struct {
+		column string
+		bucket TimeBucket
+	}
+
+	// GroupCount is the number of records in one group
+	GroupCount struct {
+		Key   string
+		Count int64
+	}
+
+	groupRow struct {
+		GroupKey   string
+		GroupCount int64
+	}
+)
+
+// ByColumn groups records sharing a value of column. The biggest groups come
+// first.
+func ByColumn(column string) GroupBy {
+	return GroupBy{column: column}
+}
+
+// ByTime groups records by the UTC day or week of a timestamp column, oldest
+// first. Periods without records are left out.
+func ByTime(column string, bucket TimeBucket) GroupBy {
+	return GroupBy{column: column, bucket: bucket}
+}
+
+// Truncate returns the start of the period t falls in, in UTC
+func (b TimeBucket) Truncate(t time.Time) time.Time {
+	day := time.Date(t.UTC().Year(), t.UTC().Month(), t.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	if b == Week {
+		// Go's weeks start on Sunday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// Next returns the start of the period after the one starting at start
+func (b TimeBucket) Next(start time.Time) time.Time {
+	if b == Week {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// CountBy counts the records matching queryFilter in each group. A limit on
+// the query caps the number of groups; its order is not used.
+func (r *Repository[T]) CountBy(ctx context.Context, queryFilter *Query, group GroupBy) ([]GroupCount, error) {
+	if err := r.columns.check(group.column); err != nil {
+		return nil, err
+	}
+	expression, err := group.expression(r.client.Dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	order := "group_count DESC, group_key ASC"
+	if group.bucket != "" {
+		order = "group_key ASC"
+	}
+
+	var rows []groupRow
+	err = r.read(ctx, func(db *gorm.DB) error {
+		query := filter.where(db.Model(new(T))).
+			Select(expression + " AS group_key, COUNT(*) AS group_count").
+			Group(expression).
+			Order(order)
+		return filter.limit(query).Scan(&rows).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	groups := make([]GroupCount, 0, len(rows))
+	for _, row := range rows {
+		groups = append(groups, GroupCount{Key: row.GroupKey, Count: row.GroupCount})
+	}
+	return groups, nil
+}
+
+// expression renders the grouped value in dialect's SQL. Days and weeks are
+// formatted like BucketKeyFormat.
+func (g GroupBy) expression(dialect database.Dialect) (string, error) {
+	column := g.column
+
+	switch g.bucket {
+	case "":
+		return column, nil
+	case Day:
+		switch dialect {
+		case database.DialectPostgres:
+			return "to_char(" + column + " AT TIME ZONE 'UTC', 'YYYY-MM-DD')", nil
+		case database.DialectMySQL:
+			return "DATE_FORMAT(" + column + ", '%Y-%m-%d')", nil
+		default:
+			return "strftime('%Y-%m-%d', " + column + ")", nil
+		}
+	case Week:
+		switch dialect {
+		case database.DialectPostgres:
+			return "to_char(date_trunc('week', " + column + " AT TIME ZONE 'UTC'), 'YYYY-MM-DD')", nil
+		case database.DialectMySQL:
+			return "DATE_FORMAT(DATE_SUB(" + column + ", INTERVAL WEEKDAY(" + column + ") DAY), '%Y-%m-%d')", nil
+		default:
+			// The Monday on or before the date
+			return "date(" + column + ", '-6 days', 'weekday 1')", nil
+		}
+	default:
+		return "", fmt.Errorf("unknown time bucket %q", g.bucket)
+	}
+}
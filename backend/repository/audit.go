@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/models"
+)
+
+// redacted stands in for the values of columns that are never serialized,
+// such as password hashes, so the log shows they changed but not to what
+const redacted = "[redacted]"
+
+// unauditedColumns change on every write and are recorded elsewhere in the entry
+var unauditedColumns = map[string]bool{"updated_at": true, "_version": true}
+
+type (
+	// AuditChange is the value of a column before and after a write
+	AuditChange struct {
+		Old interface{} `json:"old"`
+		New interface{} `json:"new"`
+	}
+
+	// auditPair is one record as it was before and after a write; before
+	// is nil for inserts and after is nil for hard deletes
+	auditPair[T models.Models] struct {
+		action string
+		before *T
+		after  *T
+	}
+)
+
+// WithAuditLog records every write made through the repositories in the
+// audit_log table, in the transaction of the write
+func WithAuditLog() ContainerOption {
+	return func(o *containerOptions) {
+		o.audit = true
+	}
+}
+
+func audits[T any]() bool {
+	exempt, ok := any(new(T)).(models.AuditExempt)
+	return !ok || !exempt.AuditExempt()
+}
+
+func withAudit[T models.Models](repo *Repository[T], enabled bool) *Repository[T] {
+	repo.audit = enabled && audits[T]()
+	return repo
+}
+
+// write runs fn in a transaction when the repository is audited, so a write
+// and its audit entries are committed or rolled back together
+func (r *Repository[T]) write(ctx context.Context, fn func(db *gorm.DB) error) error {
+	if !r.audit {
+		return fn(r.db.WithContext(ctx))
+	}
+	return r.db.WithContext(ctx).Transaction(fn)
+}
+
+// snapshot loads the records scope matches as they are before a write, in
+// batches of findByIDsBatch. It loads nothing when the repository is not
+// audited.
+func (r *Repository[T]) snapshot(db *gorm.DB, scope func(db *gorm.DB) *gorm.DB) ([]*T, error) {
+	if !r.audit {
+		return nil, nil
+	}
+
+	var records, batch []*T
+	err := scope(db.Model(new(T))).FindInBatches(&batch, findByIDsBatch, func(*gorm.DB, int) error {
+		records = append(records, batch...)
+		return nil
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read records for the audit log: %w", err)
+	}
+	return records, nil
+}
+
+// reload loads records by ID, whether or not they are soft deleted
+func (r *Repository[T]) reload(db *gorm.DB, records []*T) (map[string]*T, error) {
+	reloaded := make(map[string]*T, len(records))
+
+	ids := recordIDs(records)
+	for start := 0; start < len(ids); start += findByIDsBatch {
+		end := min(start+findByIDsBatch, len(ids))
+
+		var fresh []*T
+		if err := db.Model(new(T)).Where(r.primaryKey()+" IN ?", ids[start:end]).Find(&fresh).Error; err != nil {
+			return nil, fmt.Errorf("failed to read records for the audit log: %w", err)
+		}
+		for _, record := range fresh {
+			reloaded[(*record).GetID()] = record
+		}
+	}
+	return reloaded, nil
+}
+
+// writeRestricted runs write on the statements query builds and returns the
+// rows it affected. An audited write is limited to the records snapshot
+// loaded, so a row that starts matching in between is not changed without an
+// entry, and runs once per findByIDsBatch of them to keep the IN list within
+// bind parameter limits.
+func (r *Repository[T]) writeRestricted(records []*T, query func() *gorm.DB, write func(db *gorm.DB) *gorm.DB) (int64, error) {
+	if !r.audit {
+		result := write(query())
+		return result.RowsAffected, result.Error
+	}
+
+	var affected int64
+	ids := recordIDs(records)
+	for start := 0; start < len(ids); start += findByIDsBatch {
+		end := min(start+findByIDsBatch, len(ids))
+
+		result := write(query().Where(r.primaryKey()+" IN ?", ids[start:end]))
+		if result.Error != nil {
+			return affected, result.Error
+		}
+		affected += result.RowsAffected
+	}
+	return affected, nil
+}
+
+// changed pairs records loaded before a write with their state after it
+func (r *Repository[T]) changed(db *gorm.DB, action string, before []*T) ([]auditPair[T], error) {
+	after, err := r.reload(db, before)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]auditPair[T], 0, len(before))
+	for _, record := range before {
+		pairs = append(pairs, auditPair[T]{action: action, before: record, after: after[(*record).GetID()]})
+	}
+	return pairs, nil
+}
+
+func removed[T models.Models](action string, before []*T) []auditPair[T] {
+	pairs := make([]auditPair[T], 0, len(before))
+	for _, record := range before {
+		pairs = append(pairs, auditPair[T]{action: action, before: record})
+	}
+	return pairs
+}
+
+// record appends an audit entry for every pair, attributed to the account
+// and request found in ctx
+func (r *Repository[T]) record(ctx context.Context, db *gorm.DB, pairs []auditPair[T]) error {
+	if !r.audit || len(pairs) == 0 {
+		return nil
+	}
+
+	var actorID, requestID string
+	if account, ok := ctx.Value(string(constants.ContextKeyAccountInfo)).(models.AccountInfo); ok {
+		actorID = account.Id
+	}
+	if id, ok := ctx.Value(string(constants.ContextKeyRequestID)).(string); ok {
+		requestID = id
+	}
+
+	entity := r.client.Table()
+	if entity == "" && r.schema != nil {
+		entity = r.schema.Table
+	}
+
+	now := time.Now().UTC()
+	entries := make([]models.AuditEntry, 0, len(pairs))
+	for _, pair := range pairs {
+		current := pair.after
+		if current == nil {
+			current = pair.before
+		}
+
+		changes, err := json.Marshal(r.diff(ctx, pair.before, pair.after))
+		if err != nil {
+			return fmt.Errorf("failed to encode audit changes: %w", err)
+		}
+
+		entries = append(entries, models.AuditEntry{
+			ID:            uuid.New().String(),
+			CreatedAt:     &now,
+			Entity:        entity,
+			RecordID:      (*current).GetID(),
+			Action:        pair.action,
+			Changes:       string(changes),
+			RecordVersion: (*current).GetVersion(),
+			ActorID:       actorID,
+			RequestID:     requestID,
+		})
+	}
+
+	if err := db.Table(models.AuditEntry{}.TableName()).CreateInBatches(&entries, DefaultBatchSize).Error; err != nil {
+		return fmt.Errorf("failed to write the audit log: %w", err)
+	}
+	return nil
+}
+
+// diff lists the columns whose values differ between before and after. When
+// either side is missing, only the columns set on the other are listed.
+func (r *Repository[T]) diff(ctx context.Context, before, after *T) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+	if r.schema == nil {
+		return changes
+	}
+
+	for _, field := range r.schema.Fields {
+		if field.DBName == "" || unauditedColumns[field.DBName] {
+			continue
+		}
+
+		oldValue, newValue := fieldValue(ctx, field, before), fieldValue(ctx, field, after)
+		if sameValue(oldValue, newValue) || (before == nil && isZero(newValue)) || (after == nil && isZero(oldValue)) {
+			continue
+		}
+
+		if field.Tag.Get("json") == "-" {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+		changes[field.DBName] = AuditChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// fieldValue reads a column of record, treating nil pointers as no value
+func fieldValue[T any](ctx context.Context, field *schema.Field, record *T) interface{} {
+	if record == nil {
+		return nil
+	}
+
+	value := field.ReflectValueOf(ctx, reflect.ValueOf(record).Elem())
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	return value.Interface()
+}
+
+func isZero(value interface{}) bool {
+	return value == nil || reflect.ValueOf(value).IsZero()
+}
+
+func sameValue(a, b interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func redact(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return redacted
+}
+
+func recordIDs[T models.Models](records []*T) []string {
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, (*record).GetID())
+	}
+	return ids
+}
@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+)
+
+// DefaultBatchSize is the number of rows per INSERT when CreateMany is not
+// given a batch size. It keeps the bound parameters of the widest table well
+// under the limits of every dialect.
+const DefaultBatchSize = 500
+
+var (
+	ErrNoConflictColumns = errors.New("upsert needs the columns of a unique key")
+	ErrNothingToUpdate   = errors.New("no columns to update")
+	ErrColumnNotWritable = errors.New("column cannot be written in bulk")
+)
+
+// managedColumns are maintained by the repository and never set by callers
+var managedColumns = map[string]bool{
+	"id": true, "created_at": true, "updated_at": true, "deleted_at": true, "_version": true,
+}
+
+// writableColumns lists the columns that bulk writes may name
+func writableColumns(parsed *schema.Schema) columnSet {
+	columns := columnSet{}
+	if parsed == nil {
+		return columns
+	}
+
+	for _, field := range parsed.Fields {
+		if field.DBName != "" && !managedColumns[field.DBName] {
+			columns[field.DBName] = struct{}{}
+		}
+	}
+	return columns
+}
+
+func (s columnSet) checkWritable(columns ...string) error {
+	for _, column := range columns {
+		if _, ok := s[column]; !ok {
+			return fmt.Errorf("%w: %q", ErrColumnNotWritable, column)
+		}
+	}
+	return nil
+}
+
+func prevalidateAll[T models.Models](data []T) {
+	for i := range data {
+		if preValidator, ok := any(&data[i]).(models.PreValidator); ok {
+			preValidator.PreValidate()
+		}
+	}
+}
+
+// CreateMany inserts data in chunks of batchSize rows, or DefaultBatchSize
+// when batchSize is not positive. The chunks share a transaction, so either
+// every row is created or none is.
+func (r *Repository[T]) CreateMany(ctx context.Context, data []T, batchSize int) ([]*T, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	prevalidateAll(data)
+
+	created := make([]*T, len(data))
+	for i := range data {
+		created[i] = &data[i]
+	}
+
+	database.MarkWrite(ctx)
+	err := r.write(ctx, func(db *gorm.DB) error {
+		if err := db.CreateInBatches(&data, batchSize).Error; err != nil {
+			return err
+		}
+
+		pairs := make([]auditPair[T], 0, len(created))
+		for _, record := range created {
+			pairs = append(pairs, auditPair[T]{action: models.AuditActionCreate, after: record})
+		}
+		return r.record(ctx, db, pairs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Upsert inserts data and, for rows whose conflictColumns match an existing
+// row, updates updateColumns of that row instead and bumps its version. With
+// no updateColumns every writable column is updated. conflictColumns must be
+// a unique key, e.g. []string{"email"} for users; MySQL matches on any unique
+// key whatever columns are named.
+//
+// The ids of rows that already existed are kept, so re-read them by their key
+// rather than relying on the ids set on data.
+func (r *Repository[T]) Upsert(ctx context.Context, data []T, conflictColumns []string, updateColumns ...string) error {
+	if len(conflictColumns) == 0 {
+		return ErrNoConflictColumns
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err := r.writable.checkWritable(conflictColumns...); err != nil {
+		return err
+	}
+	if len(updateColumns) == 0 {
+		for column := range r.writable {
+			if !contains(conflictColumns, column) {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+		// Sorted so the statement is the same on every call
+		sort.Strings(updateColumns)
+	}
+	if err := r.writable.checkWritable(updateColumns...); err != nil {
+		return err
+	}
+
+	prevalidateAll(data)
+
+	conflictTargets := make([]clause.Column, 0, len(conflictColumns))
+	for _, column := range conflictColumns {
+		conflictTargets = append(conflictTargets, clause.Column{Name: column})
+	}
+
+	// The version is qualified with the table: on Postgres a bare _version is
+	// ambiguous between the existing row and the one being inserted
+	currentVersion := clause.Column{Table: clause.CurrentTable, Name: "_version"}
+	assignments := append(clause.AssignmentColumns(updateColumns),
+		clause.Assignment{Column: clause.Column{Name: "updated_at"}, Value: time.Now().UTC()},
+		clause.Assignment{Column: clause.Column{Name: "_version"}, Value: gorm.Expr("? + 1", currentVersion)},
+	)
+
+	database.MarkWrite(ctx)
+	return r.write(ctx, func(db *gorm.DB) error {
+		before, err := r.snapshotConflicts(ctx, db, data, conflictColumns)
+		if err != nil {
+			return err
+		}
+
+		err = db.Clauses(clause.OnConflict{Columns: conflictTargets, DoUpdates: assignments}).
+			CreateInBatches(&data, DefaultBatchSize).Error
+		if err != nil {
+			return err
+		}
+
+		after, err := r.snapshotConflicts(ctx, db, data, conflictColumns)
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, db, r.upserted(ctx, conflictColumns, before, after))
+	})
+}
+
+// snapshotConflicts loads the existing rows data may conflict with, looking
+// them up findByIDsBatch rows of data at a time so the IN lists stay within
+// bind parameter limits
+func (r *Repository[T]) snapshotConflicts(ctx context.Context, db *gorm.DB, data []T, conflictColumns []string) ([]*T, error) {
+	if !r.audit {
+		return nil, nil
+	}
+
+	var records []*T
+	seen := make(map[string]bool)
+	for start := 0; start < len(data); start += findByIDsBatch {
+		end := min(start+findByIDsBatch, len(data))
+
+		chunk, err := r.snapshot(db, r.conflictScope(ctx, data[start:end], conflictColumns))
+		if err != nil {
+			return nil, err
+		}
+		// With several conflict columns a row can match more than one chunk
+		for _, record := range chunk {
+			if id := (*record).GetID(); !seen[id] {
+				seen[id] = true
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// conflictScope matches the existing rows data may conflict with. It can
+// match more rows than conflict when there are several conflict columns;
+// upserted pairs the rows up exactly.
+func (r *Repository[T]) conflictScope(ctx context.Context, data []T, conflictColumns []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, column := range conflictColumns {
+			values := make([]interface{}, 0, len(data))
+			for i := range data {
+				values = append(values, fieldValue(ctx, r.schema.FieldsByDBName[column], &data[i]))
+			}
+			db = db.Where(column+" IN ?", values)
+		}
+		return db
+	}
+}
+
+// upserted pairs every row after an upsert with the row holding its conflict
+// key before, which is missing for inserted rows
+func (r *Repository[T]) upserted(ctx context.Context, conflictColumns []string, before, after []*T) []auditPair[T] {
+	key := func(record *T) string {
+		values := make([]interface{}, 0, len(conflictColumns))
+		for _, column := range conflictColumns {
+			values = append(values, fieldValue(ctx, r.schema.FieldsByDBName[column], record))
+		}
+		return fmt.Sprintf("%#v", values)
+	}
+
+	existing := make(map[string]*T, len(before))
+	for _, record := range before {
+		existing[key(record)] = record
+	}
+
+	pairs := make([]auditPair[T], 0, len(after))
+	for _, record := range after {
+		previous, ok := existing[key(record)]
+		switch {
+		case !ok:
+			pairs = append(pairs, auditPair[T]{action: models.AuditActionCreate, after: record})
+		case (*previous).GetVersion() != (*record).GetVersion():
+			pairs = append(pairs, auditPair[T]{action: models.AuditActionUpdate, before: previous, after: record})
+		}
+	}
+	return pairs
+}
+
+// increment is a value for UpdateMany that adds to a column rather than
+// replacing it
+type increment struct {
+	by int
+}
+
+// Increment adds by to a column when passed as a value to UpdateMany. The sum
+// is worked out by the database, so concurrent increments all count.
+func Increment(by int) interface{} {
+	return increment{by: by}
+}
+
+// UpdateMany sets values on every row matching queryFilter and bumps each
+// row's version, returning the number of rows changed. Zero values are
+// written, and Increment adds to a column. A query without conditions must
+// ask for All.
+func (r *Repository[T]) UpdateMany(ctx context.Context, queryFilter *Query, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, ErrNothingToUpdate
+	}
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	if err := r.writable.checkWritable(columns...); err != nil {
+		return 0, err
+	}
+
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return 0, err
+	}
+	if !filter.filtered {
+		return 0, ErrUnfiltered
+	}
+
+	updates := make(map[string]interface{}, len(values)+2)
+	for column, value := range values {
+		if inc, ok := value.(increment); ok {
+			value = gorm.Expr(column+" + ?", inc.by)
+		}
+		updates[column] = value
+	}
+	updates["_version"] = gorm.Expr("_version + 1")
+	updates["updated_at"] = time.Now().UTC()
+
+	var affected int64
+	database.MarkWrite(ctx)
+	err = r.write(ctx, func(tx *gorm.DB) error {
+		before, err := r.snapshot(tx, filter.where)
+		if err != nil {
+			return err
+		}
+
+		query := func() *gorm.DB { return filter.whereAll(tx.Model(new(T))) }
+
+		affected, err = r.writeRestricted(before, query, func(db *gorm.DB) *gorm.DB {
+			return db.Updates(updates)
+		})
+		if err != nil {
+			return err
+		}
+
+		pairs, err := r.changed(tx, models.AuditActionUpdate, before)
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, tx, pairs)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/lema/cache"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+)
+
+// DefaultCacheTTL bounds how long a cached read is served
+const DefaultCacheTTL = time.Minute
+
+type (
+	// CachedRepository is a read-through cache in front of a repository. Reads
+	// are cached under the ID they look up or a fingerprint of their query, and
+	// every write moves the model to a new cache generation, so no entry from
+	// before the write is served again. Values are copied in and out, so
+	// callers may modify what they get back. Reads that can be cached leave
+	// out what models.Redacter clears, even on a miss; read those from the
+	// repository itself.
+	CachedRepository[T models.Models] struct {
+		lemaLogger logger.Logger
+		inner      RepoInterface[T]
+		store      cache.Store
+		name       string
+		ttl        time.Duration
+		columns    columnSet
+
+		// readThrough is off inside transactions, where reads can see rows
+		// other requests must not until the commit
+		readThrough bool
+	}
+
+	// cachedResult holds whatever a read returned, so every read encodes the
+	// same way
+	cachedResult[T models.Models] struct {
+		Record    *T
+		Records   []*T
+		Paginator *Paginator
+		Cursor    *CursorPage
+		Count     int64
+		Groups    []GroupCount
+	}
+)
+
+var _ RepoInterface[models.Shared] = (*CachedRepository[models.Shared])(nil)
+
+// NewCachedRepository caches the reads of inner in store for ttl, or
+// DefaultCacheTTL when ttl is not positive. name namespaces the keys and must
+// be unique per model, e.g. the table name.
+func NewCachedRepository[T models.Models](lemaLogger logger.Logger, inner RepoInterface[T], store cache.Store, name string, ttl time.Duration) *CachedRepository[T] {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	var model T
+	return &CachedRepository[T]{
+		lemaLogger:  lemaLogger,
+		inner:       inner,
+		store:       store,
+		name:        name,
+		ttl:         ttl,
+		columns:     newColumnSet(model.AllowedColumns()),
+		readThrough: true,
+	}
+}
+
+func (r *CachedRepository[T]) FindOne(ctx context.Context, queryFilter *Query, preloads ...string) (*T, error) {
+	key, ok := r.key(ctx, "one", queryFilter, preloads)
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		record, err := r.inner.FindOne(ctx, queryFilter, preloads...)
+		return cachedResult[T]{Record: record}, err
+	})
+	return result.Record, err
+}
+
+func (r *CachedRepository[T]) FindMany(ctx context.Context, queryFilter *Query, preloads ...string) ([]*T, error) {
+	key, ok := r.key(ctx, "many", queryFilter, preloads)
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		records, err := r.inner.FindMany(ctx, queryFilter, preloads...)
+		return cachedResult[T]{Records: records}, err
+	})
+	return result.Records, err
+}
+
+func (r *CachedRepository[T]) FindByIDs(ctx context.Context, ids []string, preloads ...string) ([]*T, error) {
+	key, ok := r.key(ctx, "ids", nil, preloads, strings.Join(ids, ","))
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		records, err := r.inner.FindByIDs(ctx, ids, preloads...)
+		return cachedResult[T]{Records: records}, err
+	})
+	return result.Records, err
+}
+
+func (r *CachedRepository[T]) FindManyPaginated(ctx context.Context, queryFilter *Query, page, perPage int64, preloads ...string) ([]*T, *Paginator, error) {
+	key, ok := r.key(ctx, "page", queryFilter, preloads, page, perPage)
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		records, paginator, err := r.inner.FindManyPaginated(ctx, queryFilter, page, perPage, preloads...)
+		return cachedResult[T]{Records: records, Paginator: paginator}, err
+	})
+	return result.Records, result.Paginator, err
+}
+
+func (r *CachedRepository[T]) FindManyCursor(ctx context.Context, queryFilter *Query, cursor string, limit int64, preloads ...string) ([]*T, *CursorPage, error) {
+	key, ok := r.key(ctx, "cursor", queryFilter, preloads, cursor, limit)
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		records, page, err := r.inner.FindManyCursor(ctx, queryFilter, cursor, limit, preloads...)
+		return cachedResult[T]{Records: records, Cursor: page}, err
+	})
+	return result.Records, result.Cursor, err
+}
+
+func (r *CachedRepository[T]) Count(ctx context.Context, queryFilter *Query) (int64, error) {
+	key, ok := r.key(ctx, "count", queryFilter, nil)
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		count, err := r.inner.Count(ctx, queryFilter)
+		return cachedResult[T]{Count: count}, err
+	})
+	return result.Count, err
+}
+
+func (r *CachedRepository[T]) CountBy(ctx context.Context, queryFilter *Query, group GroupBy) ([]GroupCount, error) {
+	key, ok := r.key(ctx, "countby", queryFilter, nil, group.column, group.bucket)
+	result, err := cachedRead(ctx, r, key, ok, func() (cachedResult[T], error) {
+		groups, err := r.inner.CountBy(ctx, queryFilter, group)
+		return cachedResult[T]{Groups: groups}, err
+	})
+	return result.Groups, err
+}
+
+func (r *CachedRepository[T]) Create(ctx context.Context, data T) (*T, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.Create(ctx, data)
+}
+
+func (r *CachedRepository[T]) CreateMany(ctx context.Context, data []T, batchSize int) ([]*T, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.CreateMany(ctx, data, batchSize)
+}
+
+func (r *CachedRepository[T]) Upsert(ctx context.Context, data []T, conflictColumns []string, updateColumns ...string) error {
+	defer r.Invalidate(ctx)
+	return r.inner.Upsert(ctx, data, conflictColumns, updateColumns...)
+}
+
+func (r *CachedRepository[T]) Update(ctx context.Context, dataObject T) (*T, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.Update(ctx, dataObject)
+}
+
+func (r *CachedRepository[T]) UpdateFields(ctx context.Context, dataObject T, fields ...string) (*T, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.UpdateFields(ctx, dataObject, fields...)
+}
+
+func (r *CachedRepository[T]) UpdateMany(ctx context.Context, queryFilter *Query, values map[string]interface{}) (int64, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.UpdateMany(ctx, queryFilter, values)
+}
+
+func (r *CachedRepository[T]) DeleteMany(ctx context.Context, queryFilter *Query) error {
+	defer r.Invalidate(ctx)
+	return r.inner.DeleteMany(ctx, queryFilter)
+}
+
+func (r *CachedRepository[T]) HardDelete(ctx context.Context, queryFilter *Query) error {
+	defer r.Invalidate(ctx)
+	return r.inner.HardDelete(ctx, queryFilter)
+}
+
+func (r *CachedRepository[T]) Restore(ctx context.Context, queryFilter *Query) (int64, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.Restore(ctx, queryFilter)
+}
+
+func (r *CachedRepository[T]) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer r.Invalidate(ctx)
+	return r.inner.Purge(ctx, deletedBefore)
+}
+
+// Invalidate moves the model to a new generation, so every entry cached so far
+// is ignored. Writes call it themselves; call it after changing the table any
+// other way. Entries of older generations expire with their TTL.
+func (r *CachedRepository[T]) Invalidate(ctx context.Context) {
+	// The write already happened, so a cancelled request must not skip this
+	if err := r.store.Bump(context.WithoutCancel(ctx), r.name); err != nil {
+		r.lemaLogger.Error("failed to invalidate the cache, reads may be stale until they expire",
+			logger.WithField("cache", r.name),
+			logger.WithField("ttl", r.ttl.String()),
+			logger.WithField("err", err))
+	}
+}
+
+// key names the cache entry of a read. It reports false when the read cannot
+// be cached, and the caller should go to the repository.
+func (r *CachedRepository[T]) key(ctx context.Context, method string, queryFilter *Query, preloads []string, extra ...interface{}) (string, bool) {
+	// A locking read must reach the database to take its lock
+	if !r.readThrough || (queryFilter != nil && queryFilter.forUpdate) {
+		return "", false
+	}
+
+	where, args, orderBy, err := queryFilter.build(r.columns)
+	if err != nil {
+		// Let the repository report the error
+		return "", false
+	}
+
+	generation, err := r.store.Generation(ctx, r.name)
+	if err != nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s|%s|%v", method, where, orderBy, preloads)
+	for _, arg := range append(args, extra...) {
+		fmt.Fprintf(&b, "|%T:%v", arg, arg)
+	}
+	if queryFilter != nil {
+		fmt.Fprintf(&b, "|%d|%d|%v|%v|%v", queryFilter.limit, queryFilter.deleted, queryFilter.selects, queryFilter.omits, queryFilter.preloads)
+	}
+	fingerprint := sha256.Sum256([]byte(b.String()))
+
+	prefix := fmt.Sprintf("%s:%d:", r.name, generation)
+	// Lookups of a single ID are the common case, so their keys say which record they hold
+	if method == "one" && where == "(id = ?)" {
+		prefix += fmt.Sprintf("id:%v:", args[0])
+	}
+	return prefix + hex.EncodeToString(fingerprint[:16]), true
+}
+
+func cachedRead[T models.Models](ctx context.Context, r *CachedRepository[T], key string, ok bool, load func() (cachedResult[T], error)) (cachedResult[T], error) {
+	if !ok {
+		return load()
+	}
+
+	if value, found, err := r.store.Get(ctx, key); err == nil && found {
+		var result cachedResult[T]
+		if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&result); err == nil {
+			return result, nil
+		}
+	}
+
+	result, err := load()
+	if err != nil {
+		return result, err
+	}
+	result.redact()
+
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(result); err == nil {
+		_ = r.store.Set(ctx, key, value.Bytes(), r.ttl)
+	}
+	return result, nil
+}
+
+// redact clears the credentials of the records in c, which must not be
+// stored in the cache
+func (c *cachedResult[T]) redact() {
+	for _, record := range append([]*T{c.Record}, c.Records...) {
+		if redacter, ok := any(record).(models.Redacter); ok && record != nil {
+			redacter.Redact()
+		}
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 	"log"
+	"time"
 
+	"github.com/tejiriaustin/lema/cache"
 	"github.com/tejiriaustin/lema/database"
 	"github.com/tejiriaustin/lema/logger"
 	"github.com/tejiriaustin/lema/models"
@@ -14,55 +18,179 @@ import (
 
 type (
 	Container struct {
-		UserRepo    *Repository[models.User]
+		UserRepo RepoInterface[models.User]
+		// AccountRepo reads users from the database, never the cache, along with
+		// the credentials the cache leaves out. Login, two-factor, lockout and
+		// the other account flows use it.
+		AccountRepo RepoInterface[models.User]
 		PostRepo    *Repository[models.Post]
 		AddressRepo *Repository[models.Address]
+		TokenRepo   *Repository[models.UserToken]
+
+		RecoveryCodeRepo *Repository[models.RecoveryCode]
+		IdentityRepo     *Repository[models.UserIdentity]
+		LoginHistoryRepo *Repository[models.LoginHistory]
+		AuditRepo        *Repository[models.AuditEntry]
+
+		db      *gorm.DB
+		options containerOptions
+		caches  []cacheInvalidator
+	}
+
+	// ContainerOption configures NewRepositoryContainer
+	ContainerOption func(*containerOptions)
+
+	containerOptions struct {
+		lemaLogger logger.Logger
+		cache      cache.Store
+		cacheTTL   time.Duration
+		audit      bool
+	}
+
+	cacheInvalidator interface {
+		Invalidate(ctx context.Context)
 	}
 	Repository[T models.Models] struct {
-		db *gorm.DB
+		db       *gorm.DB
+		client   database.Client
+		schema   *schema.Schema
+		columns  columnSet
+		writable columnSet
+
+		softDelete           bool
+		softDeletedRelations map[string]bool
+
+		// audit records every write in the audit log
+		audit bool
 	}
 )
 
-func NewRepositoryContainer(lemaLogger logger.Logger, dbConn *database.Client) *Container {
+// WithCache caches reads of users, which are looked up on most requests, in
+// store for ttl
+func WithCache(store cache.Store, ttl time.Duration) ContainerOption {
+	return func(o *containerOptions) {
+		o.cache = store
+		o.cacheTTL = ttl
+	}
+}
+
+func NewRepositoryContainer(lemaLogger logger.Logger, dbConn *database.Client, opts ...ContainerOption) *Container {
 	log.Println("building repository container...")
 
-	return &Container{
-		UserRepo:    NewRepository[models.User](dbConn.GetModel("users")),
-		PostRepo:    NewRepository[models.Post](dbConn.GetModel("posts")),
-		AddressRepo: NewRepository[models.Address](dbConn.GetModel("addresses")),
+	options := containerOptions{lemaLogger: lemaLogger}
+	for _, opt := range opts {
+		opt(&options)
 	}
+	return newContainer(dbConn, options, false)
+}
+
+// newContainer binds every repository to dbConn, which is either the
+// connection pool or a transaction started by Container.Transaction.
+func newContainer(dbConn *database.Client, options containerOptions, inTransaction bool) *Container {
+	c := &Container{
+		db:      dbConn.DB,
+		options: options,
+
+		PostRepo:    withAudit(NewRepository[models.Post](dbConn.GetModel("posts")), options.audit),
+		AddressRepo: withAudit(NewRepository[models.Address](dbConn.GetModel("addresses")), options.audit),
+		TokenRepo:   withAudit(NewRepository[models.UserToken](dbConn.GetModel("user_tokens")), options.audit),
+
+		RecoveryCodeRepo: withAudit(NewRepository[models.RecoveryCode](dbConn.GetModel("recovery_codes")), options.audit),
+		IdentityRepo:     withAudit(NewRepository[models.UserIdentity](dbConn.GetModel("user_identities")), options.audit),
+		LoginHistoryRepo: NewRepository[models.LoginHistory](dbConn.GetModel("login_history")),
+		AuditRepo:        NewRepository[models.AuditEntry](dbConn.GetModel("audit_log")),
+	}
+
+	var userRepo RepoInterface[models.User] = withAudit(NewRepository[models.User](dbConn.GetModel("users")), options.audit)
+	c.UserRepo, c.AccountRepo = userRepo, userRepo
+	if options.cache != nil {
+		cached := NewCachedRepository(options.lemaLogger, userRepo, options.cache, "users", options.cacheTTL)
+		// Rows read in a transaction may never be committed
+		cached.readThrough = !inTransaction
+		c.caches = append(c.caches, cached)
+		c.UserRepo = cached
+
+		// Account writes still move the cache to a new generation
+		account := NewCachedRepository(options.lemaLogger, userRepo, options.cache, "users", options.cacheTTL)
+		account.readThrough = false
+		c.AccountRepo = account
+	}
+
+	return c
 }
 
 func NewRepository[T models.Models](client database.Client) *Repository[T] {
-	return &Repository[T]{db: client.DB}
+	var model T
+	parsed := parseSchema[T](client.DB)
+	return &Repository[T]{
+		db:       client.DB,
+		client:   client,
+		schema:   parsed,
+		columns:  newColumnSet(model.AllowedColumns()),
+		writable: writableColumns(parsed),
+
+		softDelete:           softDeletes[T](),
+		softDeletedRelations: softDeletedRelations(parsed),
+	}
 }
 
-var _ RepoInterface[models.Shared] = (*Repository[models.Shared])(nil)
+// read runs fn on a replica when the client has a healthy one, and again on
+// the primary if the replica fails and is ejected mid-read
+func (r *Repository[T]) read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	db, retryOnPrimary := r.client.Reader(ctx)
+
+	err := fn(db.WithContext(ctx))
+	if retryOnPrimary(err) {
+		err = fn(r.db.WithContext(ctx))
+	}
+	return err
+}
+
+// readLocked reads like read, unless the query locks the rows it reads:
+// locks are taken on the primary, in the caller's transaction
+func (r *Repository[T]) readLocked(ctx context.Context, filter compiledQuery, fn func(db *gorm.DB) error) error {
+	if !filter.forUpdate {
+		return r.read(ctx, fn)
+	}
+	return fn(r.db.WithContext(ctx).Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}))
+}
+
+var (
+	_ RepoInterface[models.Shared] = (*Repository[models.Shared])(nil)
+	_ Purger                       = (*Repository[models.Shared])(nil)
+)
 
 func (r *Repository[T]) Create(ctx context.Context, data T) (*T, error) {
 	if preValidator, ok := any(&data).(models.PreValidator); ok {
 		preValidator.PreValidate()
 	}
 
-	result := r.db.WithContext(ctx).Create(&data)
-	if result.Error != nil {
-		return &data, result.Error
+	database.MarkWrite(ctx)
+	err := r.write(ctx, func(db *gorm.DB) error {
+		if err := db.Create(&data).Error; err != nil {
+			return err
+		}
+		return r.record(ctx, db, []auditPair[T]{{action: models.AuditActionCreate, after: &data}})
+	})
+	if err != nil {
+		return &data, err
 	}
 	return &data, nil
 }
 
 func (r *Repository[T]) FindOne(ctx context.Context, queryFilter *Query, preloads ...string) (*T, error) {
 	var result *T
-	db := r.db.WithContext(ctx)
 
-	if queryFilter != nil {
-		db = db.Where(queryFilter.query, queryFilter.args...)
-	}
-	for _, preload := range preloads {
-		db = db.Preload(preload)
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := db.First(&result).Error; err != nil {
+	err = r.readLocked(ctx, filter, func(db *gorm.DB) error {
+		db = r.project(filter.order(filter.where(db)), filter, preloads)
+		return db.First(&result).Error
+	})
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
 		}
@@ -71,19 +199,83 @@ func (r *Repository[T]) FindOne(ctx context.Context, queryFilter *Query, preload
 	return result, nil
 }
 
+// FindMany returns every matching record, honouring the query's ordering and limit
+func (r *Repository[T]) FindMany(ctx context.Context, queryFilter *Query, preloads ...string) ([]*T, error) {
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*T
+	err = r.readLocked(ctx, filter, func(db *gorm.DB) error {
+		db = r.project(filter.limit(filter.order(filter.where(db.Model(new(T))))), filter, preloads)
+		return db.Find(&results).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find failed: %w", err)
+	}
+	return results, nil
+}
+
+// findByIDsBatch keeps each IN list well under the bind parameter limits of
+// every dialect
+const findByIDsBatch = 500
+
+// FindByIDs loads the records with the given IDs in as few queries as the
+// list allows. Records come back in the order of ids, once each; IDs with
+// no record are skipped.
+func (r *Repository[T]) FindByIDs(ctx context.Context, ids []string, preloads ...string) ([]*T, error) {
+	ids = distinct(ids)
+
+	byID := make(map[string]*T, len(ids))
+	for start := 0; start < len(ids); start += findByIDsBatch {
+		end := min(start+findByIDsBatch, len(ids))
+		records, err := r.FindMany(ctx, NewQueryFilter().Where(In("id", ids[start:end]...)), preloads...)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			byID[(*record).GetID()] = record
+		}
+	}
+
+	results := make([]*T, 0, len(byID))
+	for _, id := range ids {
+		if record, ok := byID[id]; ok {
+			results = append(results, record)
+		}
+	}
+	return results, nil
+}
+
 func (r *Repository[T]) FindManyPaginated(ctx context.Context, queryFilter *Query, page, perPage int64, preloads ...string) ([]*T, *Paginator, error) {
 	paginator := newPaginator(page, perPage)
 	paginator.setOffset()
 
-	var total int64
-	db := r.db.WithContext(ctx).Model(new(T))
-
-	if queryFilter != nil {
-		db = db.Where(queryFilter.query, queryFilter.args...)
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := db.Count(&total).Error; err != nil {
-		return nil, nil, fmt.Errorf("count failed: %w", err)
+	var (
+		total   int64
+		results []*T
+	)
+	err = r.read(ctx, func(db *gorm.DB) error {
+		db = filter.where(db.Model(new(T)))
+		if err := db.Count(&total).Error; err != nil {
+			return fmt.Errorf("count failed: %w", err)
+		}
+
+		db = r.project(filter.order(db), filter, preloads)
+
+		if err := db.Offset(int(paginator.Offset)).Limit(int(paginator.PerPage)).Find(&results).Error; err != nil {
+			return fmt.Errorf("find failed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
 	paginator.TotalRows = total
@@ -91,39 +283,175 @@ func (r *Repository[T]) FindManyPaginated(ctx context.Context, queryFilter *Quer
 	paginator.setPrevPage()
 	paginator.setNextPage()
 
-	for _, preload := range preloads {
-		db = db.Preload(preload)
+	return results, paginator, nil
+}
+
+// FindManyCursor pages through records newest first using the (created_at, id)
+// keyset instead of OFFSET, so it costs the same on every page and never counts
+// rows. An empty cursor starts at the newest record.
+func (r *Repository[T]) FindManyCursor(ctx context.Context, queryFilter *Query, encodedCursor string, limit int64, preloads ...string) ([]*T, *CursorPage, error) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var position *cursor
+	if encodedCursor != "" {
+		decoded, err := decodeCursor(encodedCursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		position = decoded
+	}
+
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	backwards := position != nil && position.Direction == cursorPrev
+
 	var results []*T
-	if err := db.Offset(int(paginator.Offset)).Limit(int(paginator.PerPage)).Find(&results).Error; err != nil {
+	err = r.read(ctx, func(db *gorm.DB) error {
+		// The keyset decides the ordering and the page size, so only the conditions apply
+		db = r.project(filter.where(db.Model(new(T))), filter, preloads, "created_at")
+
+		switch {
+		case position == nil:
+			db = db.Order("created_at DESC, id DESC")
+		case backwards:
+			db = db.Where("(created_at > ? OR (created_at = ? AND id > ?))", position.CreatedAt, position.CreatedAt, position.ID).
+				Order("created_at ASC, id ASC")
+		default:
+			db = db.Where("(created_at < ? OR (created_at = ? AND id < ?))", position.CreatedAt, position.CreatedAt, position.ID).
+				Order("created_at DESC, id DESC")
+		}
+
+		// One extra row tells us whether there is another page without counting
+		return db.Limit(int(limit) + 1).Find(&results).Error
+	})
+	if err != nil {
 		return nil, nil, fmt.Errorf("find failed: %w", err)
 	}
 
-	return results, paginator, nil
-}
+	hasMore := int64(len(results)) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
 
-func (r *Repository[T]) DeleteMany(ctx context.Context, queryFilter *Query) error {
-	db := r.db.WithContext(ctx)
+	page := &CursorPage{Limit: limit}
+	if backwards {
+		page.HasPrev, page.HasNext = hasMore, true
+	} else {
+		page.HasPrev, page.HasNext = position != nil, hasMore
+	}
 
-	if queryFilter != nil {
-		db = db.Where(queryFilter.query, queryFilter.args...)
+	if len(results) > 0 {
+		if page.HasNext {
+			page.NextCursor = cursorAt(results[len(results)-1], cursorNext)
+		}
+		if page.HasPrev {
+			page.PrevCursor = cursorAt(results[0], cursorPrev)
+		}
 	}
 
-	var model *T
-	if err := db.Delete(&model).Error; err != nil {
+	return results, page, nil
+}
+
+func cursorAt[T models.Models](record *T, direction cursorDirection) string {
+	c := cursor{ID: (*record).GetID(), Direction: direction}
+	if createdAt := (*record).GetCreatedAt(); createdAt != nil {
+		c.CreatedAt = createdAt.UTC()
+	}
+	return encodeCursor(c)
+}
+
+// DeleteMany removes the records matching queryFilter. Records of models that
+// opt into soft delete are only marked deleted, and can be restored until
+// they are purged. A query without conditions must ask for All.
+func (r *Repository[T]) DeleteMany(ctx context.Context, queryFilter *Query) error {
+	filter, err := r.compile(queryFilter)
+	if err != nil {
 		return err
 	}
-	return nil
+	if !filter.filtered {
+		return ErrUnfiltered
+	}
+
+	database.MarkWrite(ctx)
+	return r.write(ctx, func(db *gorm.DB) error {
+		before, err := r.snapshot(db, filter.where)
+		if err != nil {
+			return err
+		}
+		query := func() *gorm.DB { return filter.whereAll(db) }
+
+		if r.softDelete {
+			now := time.Now().UTC()
+			_, err := r.writeRestricted(before, query, func(matched *gorm.DB) *gorm.DB {
+				return matched.Model(new(T)).Updates(map[string]interface{}{
+					"deleted_at": now,
+					"updated_at": now,
+					"_version":   gorm.Expr("_version + 1"),
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			pairs, err := r.changed(db, models.AuditActionDelete, before)
+			if err != nil {
+				return err
+			}
+			return r.record(ctx, db, pairs)
+		}
+
+		_, err = r.writeRestricted(before, query, func(matched *gorm.DB) *gorm.DB {
+			var model *T
+			return matched.Delete(&model)
+		})
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, db, removed(models.AuditActionDelete, before))
+	})
 }
 
 func (r *Repository[T]) Update(ctx context.Context, dataObject T) (*T, error) {
+	return r.update(ctx, dataObject)
+}
+
+// UpdateFields writes only the named columns, including zero values that
+// Update would skip, such as resetting a counter or clearing a timestamp.
+func (r *Repository[T]) UpdateFields(ctx context.Context, dataObject T, fields ...string) (*T, error) {
+	// Copied so the caller's slice, which may have spare capacity, is left alone
+	return r.update(ctx, dataObject, append(append([]string{}, fields...), "_version", "updated_at")...)
+}
+
+func (r *Repository[T]) update(ctx context.Context, dataObject T, fields ...string) (*T, error) {
 	if preValidator, ok := any(&dataObject).(models.PreValidator); ok {
 		preValidator.PreValidate()
 	}
 
+	database.MarkWrite(ctx)
 	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		result := tx.Model(&dataObject).
+		before, err := r.snapshot(tx, func(db *gorm.DB) *gorm.DB {
+			return db.Where(r.primaryKey()+" = ?", dataObject.GetID())
+		})
+		if err != nil {
+			return err
+		}
+
+		db := tx.Model(&dataObject)
+		if len(fields) > 0 {
+			db = db.Select(fields)
+		}
+
+		result := db.
 			Where("_version = ?", dataObject.GetVersion()-1).
 			Updates(dataObject)
 
@@ -135,7 +463,11 @@ func (r *Repository[T]) Update(ctx context.Context, dataObject T) (*T, error) {
 			return ErrConcurrentModification
 		}
 
-		return nil
+		pairs, err := r.changed(tx, models.AuditActionUpdate, before)
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, tx, pairs)
 	})
 
 	if err != nil {
@@ -146,16 +478,106 @@ func (r *Repository[T]) Update(ctx context.Context, dataObject T) (*T, error) {
 }
 
 func (r *Repository[T]) Count(ctx context.Context, queryFilter *Query) (int64, error) {
+	filter, err := r.compile(queryFilter)
+	if err != nil {
+		return 0, err
+	}
+
 	var count int64
-	db := r.db.WithContext(ctx).Model(new(T))
+	err = r.read(ctx, func(db *gorm.DB) error {
+		return filter.where(db.Model(new(T))).Count(&count).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	return count, nil
+}
+
+// compiledQuery is a Query rendered to SQL after its columns have been checked
+type compiledQuery struct {
+	conditions string
+	args       []interface{}
+	orderBy    string
+	limitTo    int
+	deleted    deletedScope
+	forUpdate  bool
+	// filtered is false when the query has no conditions of its own and
+	// didn't ask for All
+	filtered bool
+
+	selects  []string
+	omits    []string
+	preloads []preload
+}
+
+// compile renders queryFilter, leaving out soft deleted records unless the
+// query asks for them
+func (r *Repository[T]) compile(queryFilter *Query) (compiledQuery, error) {
+	return r.compileScoped(queryFilter, scopeLive)
+}
+
+// compileScoped renders queryFilter, applying fallback to soft deleted
+// records when the query does not choose itself
+func (r *Repository[T]) compileScoped(queryFilter *Query, fallback deletedScope) (compiledQuery, error) {
+	conditions, args, orderBy, err := queryFilter.build(r.columns)
+	if err != nil {
+		return compiledQuery{}, err
+	}
 
+	compiled := compiledQuery{conditions: conditions, args: args, orderBy: orderBy, deleted: fallback, filtered: conditions != ""}
 	if queryFilter != nil {
-		db = db.Where(queryFilter.query, queryFilter.args...)
+		compiled.filtered = compiled.filtered || queryFilter.all
+		compiled.limitTo = queryFilter.limit
+		compiled.forUpdate = queryFilter.forUpdate
+		if queryFilter.deleted != scopeUnset {
+			compiled.deleted = queryFilter.deleted
+		}
+		if err := r.checkProjection(queryFilter); err != nil {
+			return compiledQuery{}, err
+		}
+		compiled.selects, compiled.omits, compiled.preloads = queryFilter.selects, queryFilter.omits, queryFilter.preloads
 	}
 
-	if err := db.Count(&count).Error; err != nil {
-		return 0, fmt.Errorf("failed to count records: %w", err)
+	if !r.softDelete {
+		return compiled, nil
 	}
+	if scope := compiled.deleted.condition(); scope != "" {
+		if compiled.conditions == "" {
+			compiled.conditions = scope
+		} else {
+			compiled.conditions += " AND " + scope
+		}
+	}
+	return compiled, nil
+}
 
-	return count, nil
+func (q compiledQuery) where(db *gorm.DB) *gorm.DB {
+	if q.conditions == "" {
+		return db
+	}
+	return db.Where(q.conditions, q.args...)
+}
+
+// whereAll is where for bulk writes, which gorm refuses to run without a
+// condition even when the query asked for All
+func (q compiledQuery) whereAll(db *gorm.DB) *gorm.DB {
+	if q.conditions == "" {
+		return db.Where("1 = 1")
+	}
+	return q.where(db)
+}
+
+func (q compiledQuery) order(db *gorm.DB) *gorm.DB {
+	if q.orderBy == "" {
+		return db
+	}
+	return db.Order(q.orderBy)
+}
+
+func (q compiledQuery) limit(db *gorm.DB) *gorm.DB {
+	if q.limitTo <= 0 {
+		return db
+	}
+	return db.Limit(q.limitTo)
 }
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+type cursorDirection string
+
+const (
+	cursorNext cursorDirection = "next"
+	cursorPrev cursorDirection = "prev"
+)
+
+// CursorPage describes where a keyset page sits. Cursors are opaque to callers
+// and are only meant to be passed back to FindManyCursor.
+type CursorPage struct {
+	Limit      int64  `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+}
+
+// cursor marks a position in the (created_at DESC, id DESC) ordering
+type cursor struct {
+	CreatedAt time.Time       `json:"t"`
+	ID        string          `json:"id"`
+	Direction cursorDirection `json:"d"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
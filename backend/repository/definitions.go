@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/tejiriaustin/lema/models"
 )
@@ -9,19 +10,34 @@ import (
 type (
 	Creator[T models.Models] interface {
 		Create(ctx context.Context, data T) (*T, error)
+		CreateMany(ctx context.Context, data []T, batchSize int) ([]*T, error)
+		Upsert(ctx context.Context, data []T, conflictColumns []string, updateColumns ...string) error
 	}
 	Finder[T models.Models] interface {
 		FindOne(ctx context.Context, queryFilter *Query, preloads ...string) (*T, error)
+		FindMany(ctx context.Context, queryFilter *Query, preloads ...string) ([]*T, error)
+		FindByIDs(ctx context.Context, ids []string, preloads ...string) ([]*T, error)
 		FindManyPaginated(ctx context.Context, queryFilter *Query, page, perPage int64, preloads ...string) ([]*T, *Paginator, error)
+		FindManyCursor(ctx context.Context, queryFilter *Query, cursor string, limit int64, preloads ...string) ([]*T, *CursorPage, error)
 	}
 	Deleter[T models.Models] interface {
 		DeleteMany(ctx context.Context, queryFilter *Query) error
+		HardDelete(ctx context.Context, queryFilter *Query) error
+		Restore(ctx context.Context, queryFilter *Query) (int64, error)
+		Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 	}
 	Updater[T models.Models] interface {
 		Update(ctx context.Context, dataObject T) (*T, error)
+		UpdateFields(ctx context.Context, dataObject T, fields ...string) (*T, error)
+		UpdateMany(ctx context.Context, queryFilter *Query, values map[string]interface{}) (int64, error)
+	}
+	// Purger permanently removes soft deleted records once they are old enough
+	Purger interface {
+		Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 	}
 	Counter[T models.Models] interface {
 		Count(ctx context.Context, queryFilter *Query) (int64, error)
+		CountBy(ctx context.Context, queryFilter *Query, group GroupBy) ([]GroupCount, error)
 	}
 
 	RepoInterface[T models.Models] interface {
@@ -6,4 +6,10 @@ var (
 	ErrConcurrentModification = errors.New("concurrent modification detected")
 
 	ErrNotFound = errors.New("not found")
+
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	ErrColumnNotAllowed = errors.New("column cannot be used in a query")
+
+	ErrUnfiltered = errors.New("bulk write without a condition, use All to touch every record")
 )
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/tejiriaustin/lema/models"
+)
+
+type (
+	// IDFinder loads records by ID, see Repository.FindByIDs
+	IDFinder[T models.Models] interface {
+		FindByIDs(ctx context.Context, ids []string, preloads ...string) ([]*T, error)
+	}
+
+	// Loader memoizes lookups by ID for the length of a request and batches
+	// them: every ID asked for or primed since the last query is fetched by
+	// the next one. Concurrent lookups of an ID share one query. It is not
+	// meant to outlive a request, as it never sees later writes.
+	Loader[T models.Models] struct {
+		finder   IDFinder[T]
+		preloads []string
+
+		mu      sync.Mutex
+		entries map[string]*loaderEntry[T]
+		queued  []string
+	}
+
+	loaderEntry[T models.Models] struct {
+		done   chan struct{}
+		record *T
+		err    error
+	}
+
+	// Loaders holds a request's loaders
+	Loaders struct {
+		Users *Loader[models.User]
+	}
+
+	loadersKey struct{}
+)
+
+// NewLoader returns a Loader reading through finder. Records are loaded with
+// preloads, so every caller of the loader gets the same relations.
+func NewLoader[T models.Models](finder IDFinder[T], preloads ...string) *Loader[T] {
+	return &Loader[T]{
+		finder:   finder,
+		preloads: preloads,
+		entries:  make(map[string]*loaderEntry[T]),
+	}
+}
+
+// NewLoaders returns fresh loaders over the container's repositories. Users
+// are loaded with their address, which every user response includes.
+func (c *Container) NewLoaders() *Loaders {
+	return &Loaders{
+		Users: NewLoader[models.User](c.UserRepo, "Address"),
+	}
+}
+
+// WithLoaders attaches loaders to ctx for the rest of the request
+func WithLoaders(ctx context.Context, loaders *Loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, loaders)
+}
+
+// LoadersFromContext returns the loaders WithLoaders attached to ctx
+func LoadersFromContext(ctx context.Context) (*Loaders, bool) {
+	loaders, ok := ctx.Value(loadersKey{}).(*Loaders)
+	return loaders, ok && loaders != nil
+}
+
+// Prime queues ids to be fetched with the next lookup, so a list can ask for
+// the records it will need one at a time and still cost a single query
+func (l *Loader[T]) Prime(ids ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := l.entries[id]; !ok {
+			l.entries[id] = &loaderEntry[T]{done: make(chan struct{})}
+			l.queued = append(l.queued, id)
+		}
+	}
+}
+
+// Load returns the record with id, or ErrNotFound when there is none
+func (l *Loader[T]) Load(ctx context.Context, id string) (*T, error) {
+	l.Prime(id)
+
+	l.mu.Lock()
+	entry := l.entries[id]
+	batch := l.queued
+	l.queued = nil
+	l.mu.Unlock()
+
+	if len(batch) > 0 {
+		l.fetch(ctx, batch)
+	}
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	if entry.record == nil {
+		return nil, ErrNotFound
+	}
+	return entry.record, nil
+}
+
+// LoadMany returns the records with ids by ID, leaving out IDs without one
+func (l *Loader[T]) LoadMany(ctx context.Context, ids []string) (map[string]*T, error) {
+	l.Prime(ids...)
+
+	records := make(map[string]*T, len(ids))
+	for _, id := range ids {
+		record, err := l.Load(ctx, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			records[id] = record
+		}
+	}
+	return records, nil
+}
+
+// fetch loads batch and settles its entries. A failed batch is forgotten so
+// a later lookup tries again.
+func (l *Loader[T]) fetch(ctx context.Context, batch []string) {
+	records, err := l.finder.FindByIDs(ctx, batch, l.preloads...)
+
+	byID := make(map[string]*T, len(records))
+	for _, record := range records {
+		byID[(*record).GetID()] = record
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range batch {
+		entry := l.entries[id]
+		entry.record, entry.err = byID[id], err
+		if err != nil {
+			delete(l.entries, id)
+		}
+		close(entry.done)
+	}
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/tejiriaustin/lema/models"
+)
+
+var ErrUnknownRelation = errors.New("unknown relation")
+
+var schemaCache sync.Map
+
+// preload is a relation loaded with the records, limited to columns when any
+// are given
+type preload struct {
+	relation string
+	columns  []string
+}
+
+// Select loads only columns. The primary key, and the keys linking preloaded
+// relations, are always loaded too. Records loaded this way are partial, so
+// don't write them back with Update.
+func (f *Query) Select(columns ...string) *Query {
+	f.selects = append(f.selects, columns...)
+	return f
+}
+
+// Omit loads every column except columns, such as a large text column a
+// listing doesn't show. Keys are never omitted.
+func (f *Query) Omit(columns ...string) *Query {
+	f.omits = append(f.omits, columns...)
+	return f
+}
+
+// Preload loads relation with the records. Given columns, only those and the
+// keys linking the relation are loaded, e.g. Preload("Address", "city", "state").
+func (f *Query) Preload(relation string, columns ...string) *Query {
+	f.preloads = append(f.preloads, preload{relation: relation, columns: columns})
+	return f
+}
+
+// parseSchema reads the columns and relations of T, or returns nil without a
+// connection to take the naming strategy from
+func parseSchema[T models.Models](db *gorm.DB) *schema.Schema {
+	if db == nil {
+		return nil
+	}
+
+	parsed, err := schema.Parse(new(T), &schemaCache, db.NamingStrategy)
+	if err != nil {
+		return nil
+	}
+	return parsed
+}
+
+// checkProjection makes sure the selected columns and preloads exist
+func (r *Repository[T]) checkProjection(queryFilter *Query) error {
+	if r.schema == nil {
+		return nil
+	}
+
+	for _, column := range append(append([]string{}, queryFilter.selects...), queryFilter.omits...) {
+		if _, ok := r.schema.FieldsByDBName[column]; !ok {
+			return fmt.Errorf("%w: %s", ErrColumnNotAllowed, column)
+		}
+	}
+
+	for _, p := range queryFilter.preloads {
+		relation, ok := r.schema.Relationships.Relations[p.relation]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownRelation, p.relation)
+		}
+		if len(p.columns) > 0 && relation.JoinTable != nil {
+			return fmt.Errorf("%w: columns of many to many relation %s", ErrColumnNotAllowed, p.relation)
+		}
+		for _, column := range p.columns {
+			if _, ok := relation.FieldSchema.FieldsByDBName[column]; !ok {
+				return fmt.Errorf("%w: %s.%s", ErrColumnNotAllowed, p.relation, column)
+			}
+		}
+	}
+	return nil
+}
+
+// project applies the query's column selection and every preload, whether
+// named by the query or passed to the finder. required lists columns the
+// finder itself reads from the records, which are loaded whatever is selected.
+func (r *Repository[T]) project(db *gorm.DB, filter compiledQuery, preloads []string, required ...string) *gorm.DB {
+	all := make([]preload, 0, len(preloads)+len(filter.preloads))
+	for _, relation := range preloads {
+		all = append(all, preload{relation: relation})
+	}
+	all = append(all, filter.preloads...)
+
+	keys := append([]string{r.primaryKey()}, required...)
+	for _, p := range all {
+		relation := r.relation(p.relation)
+		keys = append(keys, linkingKeys(relation, relation.Schema)...)
+		db = r.preloadRelation(db, filter, p, relation)
+	}
+
+	if len(filter.selects) > 0 {
+		db = db.Select(distinct(append(append([]string{}, filter.selects...), keys...)))
+	}
+	if omits := without(filter.omits, keys); len(omits) > 0 {
+		db = db.Omit(omits...)
+	}
+	return db
+}
+
+func (r *Repository[T]) preloadRelation(db *gorm.DB, filter compiledQuery, p preload, relation *schema.Relationship) *gorm.DB {
+	hideDeleted := r.softDeletedRelations[p.relation] && filter.deleted == scopeLive
+	if len(p.columns) == 0 && !hideDeleted {
+		return db.Preload(p.relation)
+	}
+
+	var columns []string
+	if len(p.columns) > 0 {
+		columns = append(append([]string{}, p.columns...), linkingKeys(relation, relation.FieldSchema)...)
+		if relation.FieldSchema != nil && relation.FieldSchema.PrioritizedPrimaryField != nil {
+			columns = append(columns, relation.FieldSchema.PrioritizedPrimaryField.DBName)
+		}
+		columns = distinct(columns)
+	}
+
+	return db.Preload(p.relation, func(tx *gorm.DB) *gorm.DB {
+		if len(columns) > 0 {
+			tx = tx.Select(columns)
+		}
+		if hideDeleted {
+			tx = tx.Where(scopeLive.condition())
+		}
+		return tx
+	})
+}
+
+func (r *Repository[T]) primaryKey() string {
+	if r.schema == nil || r.schema.PrioritizedPrimaryField == nil {
+		return "id"
+	}
+	return r.schema.PrioritizedPrimaryField.DBName
+}
+
+// relation looks up a direct relation of T. Nested preloads such as
+// "Posts.User" aren't found and are preloaded without projection.
+func (r *Repository[T]) relation(name string) *schema.Relationship {
+	if r.schema == nil {
+		return &schema.Relationship{}
+	}
+	if relation, ok := r.schema.Relationships.Relations[name]; ok {
+		return relation
+	}
+	return &schema.Relationship{}
+}
+
+// linkingKeys lists the columns of side that join relation's records, such as
+// addresses.user_id for a user's address
+func linkingKeys(relation *schema.Relationship, side *schema.Schema) []string {
+	if side == nil {
+		return nil
+	}
+
+	var keys []string
+	for _, reference := range relation.References {
+		for _, field := range []*schema.Field{reference.PrimaryKey, reference.ForeignKey} {
+			if field != nil && field.Schema == side {
+				keys = append(keys, field.DBName)
+			}
+		}
+	}
+	return keys
+}
+
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func without(values, excluded []string) []string {
+	var kept []string
+	for _, value := range values {
+		if !contains(excluded, value) {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
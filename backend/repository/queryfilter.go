@@ -1,22 +1,215 @@
 package repository
 
-type Query struct {
-	query string
-	args  []interface{}
-}
+import (
+	"fmt"
+	"strings"
+)
+
+type SortDirection string
+
+const (
+	Asc  SortDirection = "ASC"
+	Desc SortDirection = "DESC"
+)
+
+type (
+	// Condition is a single filter built from a column and bound values.
+	// Columns are checked against the model's allow-list before any SQL is produced.
+	Condition struct {
+		build func(allowed columnSet) (string, []interface{}, error)
+	}
+
+	// Query filters, orders and limits the records a repository method touches.
+	// Conditions passed to Where are combined with AND.
+	Query struct {
+		conditions []Condition
+		orders     []ordering
+		limit      int
+		deleted    deletedScope
+		forUpdate  bool
+		all        bool
+
+		selects  []string
+		omits    []string
+		preloads []preload
+	}
+
+	ordering struct {
+		column    string
+		direction SortDirection
+	}
+
+	columnSet map[string]struct{}
+)
 
 func NewQueryFilter() *Query {
 	return &Query{}
 }
 
-func (f *Query) Where(query string, args ...interface{}) *Query {
-	f.query += query
-	f.args = append(f.args, args)
+// Where adds conditions that records must all satisfy
+func (f *Query) Where(conditions ...Condition) *Query {
+	f.conditions = append(f.conditions, conditions...)
+	return f
+}
+
+func (f *Query) OrderBy(column string, direction SortDirection) *Query {
+	f.orders = append(f.orders, ordering{column: column, direction: direction})
 	return f
 }
 
-func (f *Query) Raw(query string, args ...interface{}) *Query {
-	f.query += query
-	f.args = append(f.args, args...)
+func (f *Query) Limit(limit int) *Query {
+	f.limit = limit
 	return f
 }
+
+// ForUpdate locks the rows the query reads until the transaction it runs in
+// ends, so they can't be changed or deleted before it commits. Locked reads
+// always go to the primary. SQLite ignores the lock, as its transactions
+// already take turns on the one write connection.
+func (f *Query) ForUpdate() *Query {
+	f.forUpdate = true
+	return f
+}
+
+// All lets UpdateMany, DeleteMany, HardDelete and Restore touch every record
+// when the query has no conditions. Without it they return ErrUnfiltered, so a
+// filter that was never filled in can't change a whole table.
+func (f *Query) All() *Query {
+	f.all = true
+	return f
+}
+
+// Eq matches records where column equals value
+func Eq(column string, value interface{}) Condition {
+	return columnCondition(column, func() (string, []interface{}) {
+		return column + " = ?", []interface{}{value}
+	})
+}
+
+// In matches records where column equals any of values. No values matches nothing.
+func In[V any](column string, values ...V) Condition {
+	return columnCondition(column, func() (string, []interface{}) {
+		if len(values) == 0 {
+			return "1 = 0", nil
+		}
+		return column + " IN ?", []interface{}{values}
+	})
+}
+
+// Like matches column against a SQL LIKE pattern
+func Like(column, pattern string) Condition {
+	return columnCondition(column, func() (string, []interface{}) {
+		return column + " LIKE ?", []interface{}{pattern}
+	})
+}
+
+// Range matches from <= column < to. A nil bound leaves that side open.
+func Range(column string, from, to interface{}) Condition {
+	return columnCondition(column, func() (string, []interface{}) {
+		switch {
+		case from != nil && to != nil:
+			return "(" + column + " >= ? AND " + column + " < ?)", []interface{}{from, to}
+		case from != nil:
+			return column + " >= ?", []interface{}{from}
+		case to != nil:
+			return column + " < ?", []interface{}{to}
+		default:
+			return "1 = 1", nil
+		}
+	})
+}
+
+func IsNull(column string) Condition {
+	return columnCondition(column, func() (string, []interface{}) {
+		return column + " IS NULL", nil
+	})
+}
+
+// And groups conditions that must all match
+func And(conditions ...Condition) Condition {
+	return groupCondition("AND", conditions)
+}
+
+// Or groups conditions where at least one must match
+func Or(conditions ...Condition) Condition {
+	return groupCondition("OR", conditions)
+}
+
+func columnCondition(column string, render func() (string, []interface{})) Condition {
+	return Condition{build: func(allowed columnSet) (string, []interface{}, error) {
+		if err := allowed.check(column); err != nil {
+			return "", nil, err
+		}
+		sql, args := render()
+		return sql, args, nil
+	}}
+}
+
+func groupCondition(operator string, conditions []Condition) Condition {
+	return Condition{build: func(allowed columnSet) (string, []interface{}, error) {
+		if len(conditions) == 0 {
+			// An empty AND is always true, an empty OR never is
+			if operator == "AND" {
+				return "1 = 1", nil, nil
+			}
+			return "1 = 0", nil, nil
+		}
+
+		parts := make([]string, 0, len(conditions))
+		var args []interface{}
+		for _, condition := range conditions {
+			if condition.build == nil {
+				return "", nil, fmt.Errorf("empty condition in %s group", operator)
+			}
+			sql, conditionArgs, err := condition.build(allowed)
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, sql)
+			args = append(args, conditionArgs...)
+		}
+		return "(" + strings.Join(parts, " "+operator+" ") + ")", args, nil
+	}}
+}
+
+// build renders the filter as a WHERE clause and ORDER BY list
+func (f *Query) build(allowed columnSet) (where string, args []interface{}, orderBy string, err error) {
+	if f == nil {
+		return "", nil, "", nil
+	}
+
+	if len(f.conditions) > 0 {
+		where, args, err = And(f.conditions...).build(allowed)
+		if err != nil {
+			return "", nil, "", err
+		}
+	}
+
+	orders := make([]string, 0, len(f.orders))
+	for _, o := range f.orders {
+		if err := allowed.check(o.column); err != nil {
+			return "", nil, "", err
+		}
+		if o.direction != Asc && o.direction != Desc {
+			return "", nil, "", fmt.Errorf("invalid sort direction %q", o.direction)
+		}
+		orders = append(orders, o.column+" "+string(o.direction))
+	}
+
+	return where, args, strings.Join(orders, ", "), nil
+}
+
+func newColumnSet(columns []string) columnSet {
+	set := make(columnSet, len(columns))
+	for _, column := range columns {
+		set[column] = struct{}{}
+	}
+	return set
+}
+
+func (s columnSet) check(column string) error {
+	if _, ok := s[column]; !ok {
+		return fmt.Errorf("%w: %s", ErrColumnNotAllowed, column)
+	}
+	return nil
+}
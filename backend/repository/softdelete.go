@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+)
+
+var ErrNotSoftDeletable = errors.New("records of this model are not soft deleted")
+
+// deletedScope decides whether a query sees soft deleted records
+type deletedScope int
+
+const (
+	// scopeUnset leaves the choice to the repository method
+	scopeUnset deletedScope = iota
+	scopeLive
+	scopeWithDeleted
+	scopeOnlyDeleted
+)
+
+// WithDeleted makes the query match soft deleted records as well as live ones
+func (f *Query) WithDeleted() *Query {
+	f.deleted = scopeWithDeleted
+	return f
+}
+
+// OnlyDeleted makes the query match soft deleted records only
+func (f *Query) OnlyDeleted() *Query {
+	f.deleted = scopeOnlyDeleted
+	return f
+}
+
+func (s deletedScope) condition() string {
+	switch s {
+	case scopeLive:
+		return "deleted_at IS NULL"
+	case scopeOnlyDeleted:
+		return "deleted_at IS NOT NULL"
+	default:
+		return ""
+	}
+}
+
+func softDeletes[T any]() bool {
+	softDeleter, ok := any(new(T)).(models.SoftDeleter)
+	return ok && softDeleter.SoftDeletes()
+}
+
+// softDeletedRelations lists the relations whose records are soft deleted,
+// so preloading them can leave deleted records out
+func softDeletedRelations(parsed *schema.Schema) map[string]bool {
+	relations := map[string]bool{}
+	if parsed == nil {
+		return relations
+	}
+
+	for name, relation := range parsed.Relationships.Relations {
+		target := reflect.New(relation.FieldSchema.ModelType).Interface()
+		if softDeleter, ok := target.(models.SoftDeleter); ok && softDeleter.SoftDeletes() {
+			relations[name] = true
+		}
+	}
+	return relations
+}
+
+// Restore brings back the soft deleted records matching queryFilter, bumping
+// their version, and returns how many were restored.
+func (r *Repository[T]) Restore(ctx context.Context, queryFilter *Query) (int64, error) {
+	if !r.softDelete {
+		return 0, ErrNotSoftDeletable
+	}
+
+	filter, err := r.compileScoped(queryFilter, scopeOnlyDeleted)
+	if err != nil {
+		return 0, err
+	}
+	if !filter.filtered {
+		return 0, ErrUnfiltered
+	}
+
+	var restored int64
+	database.MarkWrite(ctx)
+	err = r.write(ctx, func(db *gorm.DB) error {
+		before, err := r.snapshot(db, filter.where)
+		if err != nil {
+			return err
+		}
+
+		query := func() *gorm.DB { return filter.where(db.Model(new(T))) }
+		restored, err = r.writeRestricted(before, query, func(matched *gorm.DB) *gorm.DB {
+			return matched.Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"updated_at": time.Now().UTC(),
+				"_version":   gorm.Expr("_version + 1"),
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		pairs, err := r.changed(db, models.AuditActionRestore, before)
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, db, pairs)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return restored, nil
+}
+
+// HardDelete permanently removes the records matching queryFilter, whether or
+// not they were soft deleted. A query without conditions must ask for All.
+func (r *Repository[T]) HardDelete(ctx context.Context, queryFilter *Query) error {
+	filter, err := r.compileScoped(queryFilter, scopeWithDeleted)
+	if err != nil {
+		return err
+	}
+	if !filter.filtered {
+		return ErrUnfiltered
+	}
+
+	database.MarkWrite(ctx)
+	return r.write(ctx, func(db *gorm.DB) error {
+		before, err := r.snapshot(db, filter.where)
+		if err != nil {
+			return err
+		}
+
+		query := func() *gorm.DB { return filter.whereAll(db) }
+		_, err = r.writeRestricted(before, query, func(matched *gorm.DB) *gorm.DB {
+			return matched.Delete(new(T))
+		})
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, db, removed(models.AuditActionDelete, before))
+	})
+}
+
+// Purge permanently removes records soft deleted before deletedBefore and
+// returns how many were removed. It does nothing for models that are not
+// soft deleted.
+func (r *Repository[T]) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	if !r.softDelete {
+		return 0, nil
+	}
+
+	expired := func(db *gorm.DB) *gorm.DB {
+		return db.Where("deleted_at < ?", deletedBefore.UTC())
+	}
+
+	var purged int64
+	database.MarkWrite(ctx)
+	err := r.write(ctx, func(db *gorm.DB) error {
+		before, err := r.snapshot(db, expired)
+		if err != nil {
+			return err
+		}
+
+		query := func() *gorm.DB { return expired(db) }
+		purged, err = r.writeRestricted(before, query, func(matched *gorm.DB) *gorm.DB {
+			return matched.Delete(new(T))
+		})
+		if err != nil {
+			return err
+		}
+
+		return r.record(ctx, db, removed(models.AuditActionPurge, before))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
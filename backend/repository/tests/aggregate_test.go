@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type AggregateTestSuite struct {
+	testutils.BaseSuite
+	postRepo *repository.Repository[models.Post]
+}
+
+func TestAggregate(t *testing.T) {
+	suite.Run(t, new(AggregateTestSuite))
+}
+
+func (suite *AggregateTestSuite) SetupSuite() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "aggregate", models.Post{})
+	suite.postRepo = repository.NewRepository[models.Post](dbConn.GetModel("posts"))
+
+	// 2024-01-01 is a Monday. Lagos is an hour ahead of UTC, so the last
+	// post is still on Sunday the 7th in UTC.
+	lagos := time.FixedZone("WAT", 60*60)
+	posts := []struct {
+		userID string
+		at     time.Time
+	}{
+		{"alice", time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{"alice", time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)},
+		{"bob", time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)},
+		{"alice", time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)},
+		{"carol", time.Date(2024, 1, 8, 0, 30, 0, 0, lagos)},
+	}
+	for _, post := range posts {
+		at := post.at
+		_, err := suite.postRepo.Create(context.Background(), models.Post{
+			Shared: models.Shared{CreatedAt: &at},
+			UserID: post.userID, Title: "Title", Body: "Body",
+		})
+		suite.Require().NoError(err)
+	}
+
+	deleted, err := suite.postRepo.Create(context.Background(), models.Post{UserID: "bob", Title: "Deleted", Body: "Body"})
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.postRepo.DeleteMany(context.Background(), repository.NewQueryFilter().Where(repository.Eq("id", deleted.ID))))
+}
+
+func (suite *AggregateTestSuite) TestCountByColumn() {
+	groups, err := suite.postRepo.CountBy(context.Background(), nil, repository.ByColumn("user_id"))
+	suite.Require().NoError(err)
+	suite.Equal([]repository.GroupCount{
+		{Key: "alice", Count: 3},
+		{Key: "bob", Count: 1},
+		{Key: "carol", Count: 1},
+	}, groups, "largest first, soft deleted posts left out")
+
+	top, err := suite.postRepo.CountBy(context.Background(), repository.NewQueryFilter().Limit(1), repository.ByColumn("user_id"))
+	suite.Require().NoError(err)
+	suite.Equal([]repository.GroupCount{{Key: "alice", Count: 3}}, top)
+}
+
+func (suite *AggregateTestSuite) TestCountByTime() {
+	days, err := suite.postRepo.CountBy(context.Background(), nil, repository.ByTime("created_at", repository.Day))
+	suite.Require().NoError(err)
+	suite.Equal([]repository.GroupCount{
+		{Key: "2024-01-01", Count: 2},
+		{Key: "2024-01-03", Count: 1},
+		{Key: "2024-01-07", Count: 1},
+		{Key: "2024-01-08", Count: 1},
+	}, days)
+
+	weeks, err := suite.postRepo.CountBy(context.Background(),
+		repository.NewQueryFilter().Where(repository.Range("created_at", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))),
+		repository.ByTime("created_at", repository.Week))
+	suite.Require().NoError(err)
+	suite.Equal([]repository.GroupCount{
+		{Key: "2024-01-01", Count: 4},
+		{Key: "2024-01-08", Count: 1},
+	}, weeks)
+}
+
+func (suite *AggregateTestSuite) TestRejectsUnknownColumnsAndBuckets() {
+	_, err := suite.postRepo.CountBy(context.Background(), nil, repository.ByColumn("body"))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+
+	_, err = suite.postRepo.CountBy(context.Background(), nil, repository.ByTime("created_at", "month"))
+	suite.Error(err)
+}
+
+func (suite *AggregateTestSuite) TestTimeBucket() {
+	sunday := time.Date(2024, 1, 7, 23, 0, 0, 0, time.UTC)
+	suite.Equal(time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), repository.Day.Truncate(sunday))
+	suite.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), repository.Week.Truncate(sunday))
+	suite.Equal(time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), repository.Week.Next(repository.Week.Truncate(sunday)))
+}
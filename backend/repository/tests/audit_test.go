@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type AuditTestSuite struct {
+	testutils.BaseSuite
+	repos *repository.Container
+	ctx   context.Context
+}
+
+func TestAudit(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) SetupTest() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "audit",
+		models.User{}, models.Address{}, models.Post{}, models.LoginHistory{}, models.AuditEntry{})
+	suite.repos = repository.NewRepositoryContainer(new(loggermocks.Logger), dbConn, repository.WithAuditLog())
+
+	// Requests reach the repositories with the gin context
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(string(constants.ContextKeyAccountInfo), models.AccountInfo{Id: "admin-id", Role: models.RoleAdmin})
+	c.Set(string(constants.ContextKeyRequestID), "request-1")
+	suite.ctx = c
+}
+
+func (suite *AuditTestSuite) entries(entity, recordID string) []*models.AuditEntry {
+	entries, err := suite.repos.AuditRepo.FindMany(context.Background(), repository.NewQueryFilter().
+		Where(repository.Eq("entity", entity), repository.Eq("record_id", recordID)).
+		OrderBy("created_at", repository.Asc))
+	suite.Require().NoError(err)
+	return entries
+}
+
+func (suite *AuditTestSuite) changes(entry *models.AuditEntry) map[string]repository.AuditChange {
+	var changes map[string]repository.AuditChange
+	suite.Require().NoError(json.Unmarshal([]byte(entry.Changes), &changes))
+	return changes
+}
+
+func (suite *AuditTestSuite) TestCreateAndUpdateAreRecorded() {
+	user, err := suite.repos.UserRepo.Create(suite.ctx, models.User{Name: "Jane", Email: "jane@example.com", PasswordHash: "secret"})
+	suite.Require().NoError(err)
+
+	user.Name = "Jane Doe"
+	_, err = suite.repos.UserRepo.Update(suite.ctx, *user)
+	suite.Require().NoError(err)
+
+	entries := suite.entries("users", user.ID)
+	suite.Require().Len(entries, 2)
+
+	created := entries[0]
+	suite.Equal(models.AuditActionCreate, created.Action)
+	suite.Equal("admin-id", created.ActorID)
+	suite.Equal("request-1", created.RequestID)
+	suite.Equal(uint(1), created.RecordVersion)
+	changes := suite.changes(created)
+	suite.Equal(repository.AuditChange{New: "Jane"}, changes["name"])
+	suite.Equal(repository.AuditChange{New: "[redacted]"}, changes["password_hash"], "hidden columns are not logged")
+	suite.NotContains(changes, "username", "unset columns are left out of inserts")
+
+	updated := entries[1]
+	suite.Equal(models.AuditActionUpdate, updated.Action)
+	suite.Equal(uint(2), updated.RecordVersion)
+	suite.Equal(map[string]repository.AuditChange{"name": {Old: "Jane", New: "Jane Doe"}}, suite.changes(updated))
+}
+
+func (suite *AuditTestSuite) TestDeleteRestoreAndPurgeAreRecorded() {
+	user, err := suite.repos.UserRepo.Create(suite.ctx, models.User{Name: "Jane", Email: "jane@example.com"})
+	suite.Require().NoError(err)
+	address, err := suite.repos.AddressRepo.Create(suite.ctx, models.Address{UserID: user.ID, Street: "1 Main St", City: "Lagos", State: "LA", Zipcode: "100001"})
+	suite.Require().NoError(err)
+
+	byID := repository.NewQueryFilter().Where(repository.Eq("id", address.ID))
+	suite.Require().NoError(suite.repos.AddressRepo.DeleteMany(suite.ctx, byID))
+	_, err = suite.repos.AddressRepo.Restore(suite.ctx, repository.NewQueryFilter().Where(repository.Eq("id", address.ID)))
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.repos.AddressRepo.DeleteMany(suite.ctx, repository.NewQueryFilter().Where(repository.Eq("id", address.ID))))
+	_, err = suite.repos.AddressRepo.Purge(suite.ctx, time.Now().Add(time.Minute))
+	suite.Require().NoError(err)
+
+	entries := suite.entries("addresses", address.ID)
+	suite.Require().Len(entries, 5)
+
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	suite.Equal([]string{
+		models.AuditActionCreate, models.AuditActionDelete, models.AuditActionRestore, models.AuditActionDelete, models.AuditActionPurge,
+	}, actions)
+
+	deleted := suite.changes(entries[1])
+	suite.Nil(deleted["deleted_at"].Old)
+	suite.NotNil(deleted["deleted_at"].New)
+	suite.Len(deleted, 1)
+
+	purged := suite.changes(entries[4])
+	suite.Equal("Lagos", purged["city"].Old)
+	suite.Nil(purged["city"].New)
+}
+
+func (suite *AuditTestSuite) TestBulkWritesRecordEveryRow() {
+	users, err := suite.repos.UserRepo.CreateMany(suite.ctx, []models.User{
+		{Name: "A", Email: "a@example.com", Role: models.RoleUser},
+		{Name: "B", Email: "b@example.com", Role: models.RoleUser},
+	}, 0)
+	suite.Require().NoError(err)
+
+	affected, err := suite.repos.UserRepo.UpdateMany(suite.ctx,
+		repository.NewQueryFilter().Where(repository.Eq("role", models.RoleUser)),
+		map[string]interface{}{"role": models.RoleAdmin})
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), affected)
+
+	err = suite.repos.UserRepo.Upsert(suite.ctx, []models.User{
+		{Name: "A2", Email: "a@example.com"},
+		{Name: "C", Email: "c@example.com"},
+	}, []string{"email"}, "name")
+	suite.Require().NoError(err)
+
+	first := suite.entries("users", users[0].ID)
+	suite.Require().Len(first, 3)
+	suite.Equal(map[string]repository.AuditChange{"role": {Old: models.RoleUser, New: models.RoleAdmin}}, suite.changes(first[1]))
+	suite.Equal(models.AuditActionUpdate, first[2].Action)
+	suite.Equal(map[string]repository.AuditChange{"name": {Old: "A", New: "A2"}}, suite.changes(first[2]))
+	suite.Equal(uint(3), first[2].RecordVersion)
+
+	suite.Len(suite.entries("users", users[1].ID), 2, "the upsert left B alone")
+
+	inserted, err := suite.repos.UserRepo.FindOne(suite.ctx, repository.NewQueryFilter().Where(repository.Eq("email", "c@example.com")))
+	suite.Require().NoError(err)
+	entries := suite.entries("users", inserted.ID)
+	suite.Require().Len(entries, 1)
+	suite.Equal(models.AuditActionCreate, entries[0].Action)
+}
+
+// Writes to more rows than fit in one IN list are snapshotted, restricted
+// and reloaded in chunks, with an entry for every row
+func (suite *AuditTestSuite) TestLargeWritesAreRecordedInChunks() {
+	const count = 1201
+
+	countEntries := func(entity, action string) int64 {
+		n, err := suite.repos.AuditRepo.Count(context.Background(), repository.NewQueryFilter().
+			Where(repository.Eq("entity", entity), repository.Eq("action", action)))
+		suite.Require().NoError(err)
+		return n
+	}
+
+	users := make([]models.User, 0, count)
+	for i := 0; i < count; i++ {
+		users = append(users, models.User{Name: "User", Email: fmt.Sprintf("user%d@example.com", i), Role: models.RoleUser})
+	}
+	suite.Require().NoError(suite.repos.UserRepo.Upsert(suite.ctx, users, []string{"email"}, "name"))
+	for i := range users {
+		users[i].Name = "Renamed"
+	}
+	suite.Require().NoError(suite.repos.UserRepo.Upsert(suite.ctx, users, []string{"email"}, "name"))
+	suite.Equal(int64(count), countEntries("users", models.AuditActionCreate))
+	suite.Equal(int64(count), countEntries("users", models.AuditActionUpdate))
+
+	owner, err := suite.repos.UserRepo.FindOne(suite.ctx, repository.NewQueryFilter().Where(repository.Eq("email", "user0@example.com")))
+	suite.Require().NoError(err)
+
+	addresses := make([]models.Address, 0, count)
+	for i := 0; i < count; i++ {
+		addresses = append(addresses, models.Address{UserID: owner.ID, Street: "1 Main St", City: "Lagos", State: "LA", Zipcode: "100001"})
+	}
+	_, err = suite.repos.AddressRepo.CreateMany(suite.ctx, addresses, 0)
+	suite.Require().NoError(err)
+
+	byOwner := func() *repository.Query {
+		return repository.NewQueryFilter().Where(repository.Eq("user_id", owner.ID))
+	}
+
+	affected, err := suite.repos.AddressRepo.UpdateMany(suite.ctx, byOwner(), map[string]interface{}{"city": "Abuja"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(count), affected)
+	suite.Equal(int64(count), countEntries("addresses", models.AuditActionUpdate))
+
+	suite.Require().NoError(suite.repos.AddressRepo.DeleteMany(suite.ctx, byOwner()))
+	suite.Equal(int64(count), countEntries("addresses", models.AuditActionDelete))
+
+	restored, err := suite.repos.AddressRepo.Restore(suite.ctx, byOwner())
+	suite.Require().NoError(err)
+	suite.Equal(int64(count), restored)
+
+	suite.Require().NoError(suite.repos.AddressRepo.DeleteMany(suite.ctx, byOwner()))
+	purged, err := suite.repos.AddressRepo.Purge(suite.ctx, time.Now().Add(time.Minute))
+	suite.Require().NoError(err)
+	suite.Equal(int64(count), purged)
+	suite.Equal(int64(count), countEntries("addresses", models.AuditActionPurge))
+}
+
+func (suite *AuditTestSuite) TestEntriesRollBackWithTheWrite() {
+	err := suite.repos.Transaction(suite.ctx, func(tx *repository.Container) error {
+		if _, err := tx.UserRepo.Create(suite.ctx, models.User{Name: "Jane", Email: "jane@example.com"}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	suite.Error(err)
+
+	count, err := suite.repos.AuditRepo.Count(context.Background(), nil)
+	suite.Require().NoError(err)
+	suite.Zero(count)
+}
+
+func (suite *AuditTestSuite) TestAnonymousAndExemptWrites() {
+	user, err := suite.repos.UserRepo.Create(context.Background(), models.User{Name: "Jane", Email: "jane@example.com"})
+	suite.Require().NoError(err)
+
+	entries := suite.entries("users", user.ID)
+	suite.Require().Len(entries, 1)
+	suite.Empty(entries[0].ActorID)
+	suite.Empty(entries[0].RequestID)
+
+	_, err = suite.repos.LoginHistoryRepo.Create(suite.ctx, models.LoginHistory{UserID: user.ID, Method: models.LoginMethodPassword})
+	suite.Require().NoError(err)
+
+	count, err := suite.repos.AuditRepo.Count(context.Background(), repository.NewQueryFilter().Where(repository.Eq("entity", "login_history")))
+	suite.Require().NoError(err)
+	suite.Zero(count, "login history is not audited")
+}
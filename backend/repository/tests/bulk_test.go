@@ -0,0 +1,308 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type BulkTestSuite struct {
+	testutils.BaseSuite
+	repos *repository.Container
+}
+
+func TestBulk(t *testing.T) {
+	suite.Run(t, new(BulkTestSuite))
+}
+
+func (suite *BulkTestSuite) SetupTest() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "bulk", models.User{}, models.Address{}, models.Post{})
+	suite.repos = repository.NewRepositoryContainer(new(loggermocks.Logger), dbConn)
+}
+
+func (suite *BulkTestSuite) newUsers(count int) []models.User {
+	users := make([]models.User, 0, count)
+	for i := 0; i < count; i++ {
+		users = append(users, models.User{
+			Name:  fmt.Sprintf("User %d", i),
+			Email: fmt.Sprintf("user%d@example.com", i),
+			Role:  models.RoleUser,
+		})
+	}
+	return users
+}
+
+func (suite *BulkTestSuite) findByEmail(email string) *models.User {
+	user, err := suite.repos.UserRepo.FindOne(context.Background(), repository.NewQueryFilter().Where(repository.Eq("email", email)))
+	suite.Require().NoError(err)
+	return user
+}
+
+func (suite *BulkTestSuite) TestCreateManyInChunks() {
+	ctx := context.Background()
+
+	created, err := suite.repos.UserRepo.CreateMany(ctx, suite.newUsers(7), 3)
+	suite.Require().NoError(err)
+	suite.Len(created, 7)
+
+	for _, user := range created {
+		suite.NotEmpty(user.ID, "PreValidate should run on every element")
+		suite.NotNil(user.CreatedAt)
+		suite.Equal(uint(1), user.Version)
+	}
+
+	count, err := suite.repos.UserRepo.Count(ctx, nil)
+	suite.Require().NoError(err)
+	suite.Equal(int64(7), count)
+}
+
+func (suite *BulkTestSuite) TestCreateManyIsAllOrNothing() {
+	ctx := context.Background()
+
+	users := suite.newUsers(5)
+	users[4].Email = users[0].Email
+
+	_, err := suite.repos.UserRepo.CreateMany(ctx, users, 2)
+	suite.Error(err)
+
+	count, err := suite.repos.UserRepo.Count(ctx, nil)
+	suite.Require().NoError(err)
+	suite.Zero(count)
+}
+
+func (suite *BulkTestSuite) TestCreateManyWithNothing() {
+	created, err := suite.repos.UserRepo.CreateMany(context.Background(), nil, 0)
+	suite.NoError(err)
+	suite.Empty(created)
+}
+
+func (suite *BulkTestSuite) TestUpsertOnEmail() {
+	ctx := context.Background()
+
+	_, err := suite.repos.UserRepo.CreateMany(ctx, suite.newUsers(2), 0)
+	suite.Require().NoError(err)
+	existing := suite.findByEmail("user0@example.com")
+
+	err = suite.repos.UserRepo.Upsert(ctx, []models.User{
+		{Name: "Renamed", Email: "user0@example.com", Role: models.RoleAdmin},
+		{Name: "New", Email: "new@example.com", Role: models.RoleUser},
+	}, []string{"email"}, "name")
+	suite.Require().NoError(err)
+
+	updated := suite.findByEmail("user0@example.com")
+	suite.Equal(existing.ID, updated.ID)
+	suite.Equal("Renamed", updated.Name)
+	suite.Equal(models.RoleUser, updated.Role, "only the named columns are updated")
+	suite.Equal(existing.Version+1, updated.Version)
+	suite.NotNil(updated.UpdatedAt)
+
+	inserted := suite.findByEmail("new@example.com")
+	suite.Equal("New", inserted.Name)
+	suite.Equal(uint(1), inserted.Version)
+
+	untouched := suite.findByEmail("user1@example.com")
+	suite.Equal(uint(1), untouched.Version)
+}
+
+// serverSQL runs fn on a server dialect backed by sqlmock, which stands in
+// for a live server, and returns the statements it sent
+func serverSQL(suite *testutils.BaseSuite, dialect database.Dialect, fn func(repos *repository.Container)) string {
+	var statements []string
+	sqlDB, sqlMock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(_, actual string) error {
+		statements = append(statements, actual)
+		return nil
+	})))
+	suite.Require().NoError(err)
+	defer sqlDB.Close()
+
+	sqlMock.MatchExpectationsInOrder(false)
+	for i := 0; i < 10; i++ {
+		sqlMock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	var dialector gorm.Dialector
+	switch dialect {
+	case database.DialectPostgres:
+		dialector = postgres.New(postgres.Config{Conn: sqlDB})
+	case database.DialectMySQL:
+		dialector = mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true})
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: gormlogger.Discard, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	suite.Require().NoError(err)
+
+	fn(repository.NewRepositoryContainer(new(loggermocks.Logger), &database.Client{DB: db, Dialect: dialect}))
+	return strings.Join(statements, ";\n")
+}
+
+func (suite *BulkTestSuite) TestUpsertQualifiesVersionOnServerDialects() {
+	upsert := func(repos *repository.Container) {
+		suite.Require().NoError(repos.UserRepo.Upsert(context.Background(), suite.newUsers(2), []string{"email"}, "name"))
+	}
+
+	suite.Contains(serverSQL(&suite.BaseSuite, database.DialectPostgres, upsert), `"_version"="users"."_version" + 1`)
+	suite.Contains(serverSQL(&suite.BaseSuite, database.DialectMySQL, upsert), "`_version`=`users`.`_version` + 1")
+}
+
+func (suite *BulkTestSuite) TestUpsertUpdatesEveryColumnByDefault() {
+	ctx := context.Background()
+
+	_, err := suite.repos.UserRepo.CreateMany(ctx, suite.newUsers(1), 0)
+	suite.Require().NoError(err)
+
+	err = suite.repos.UserRepo.Upsert(ctx, []models.User{
+		{Name: "Renamed", Username: "renamed", Email: "user0@example.com", Role: models.RoleAdmin},
+	}, []string{"email"})
+	suite.Require().NoError(err)
+
+	updated := suite.findByEmail("user0@example.com")
+	suite.Equal("Renamed", updated.Name)
+	suite.Equal("renamed", updated.Username)
+	suite.Equal(models.RoleAdmin, updated.Role)
+	suite.Equal(uint(2), updated.Version)
+}
+
+func (suite *BulkTestSuite) TestUpsertRejectsBadColumns() {
+	ctx := context.Background()
+	users := suite.newUsers(1)
+
+	suite.ErrorIs(suite.repos.UserRepo.Upsert(ctx, users, nil), repository.ErrNoConflictColumns)
+	suite.ErrorIs(suite.repos.UserRepo.Upsert(ctx, users, []string{"nope"}), repository.ErrColumnNotWritable)
+	suite.ErrorIs(suite.repos.UserRepo.Upsert(ctx, users, []string{"email"}, "_version"), repository.ErrColumnNotWritable)
+}
+
+func (suite *BulkTestSuite) TestUpdateManyBumpsVersion() {
+	ctx := context.Background()
+
+	users := suite.newUsers(3)
+	users[2].Role = models.RoleAdmin
+	_, err := suite.repos.UserRepo.CreateMany(ctx, users, 0)
+	suite.Require().NoError(err)
+
+	affected, err := suite.repos.UserRepo.UpdateMany(ctx,
+		repository.NewQueryFilter().Where(repository.Eq("role", models.RoleUser)),
+		map[string]interface{}{"name": "", "username": "bulk"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), affected)
+
+	updated := suite.findByEmail("user0@example.com")
+	suite.Empty(updated.Name, "zero values are written")
+	suite.Equal("bulk", updated.Username)
+	suite.Equal(uint(2), updated.Version)
+	suite.NotNil(updated.UpdatedAt)
+
+	admin := suite.findByEmail("user2@example.com")
+	suite.Equal("User 2", admin.Name)
+	suite.Equal(uint(1), admin.Version)
+}
+
+func (suite *BulkTestSuite) TestUpdateManyIncrementsConcurrently() {
+	ctx := context.Background()
+
+	users := suite.newUsers(1)
+	_, err := suite.repos.UserRepo.CreateMany(ctx, users, 0)
+	suite.Require().NoError(err)
+
+	filter := func() *repository.Query {
+		return repository.NewQueryFilter().Where(repository.Eq("email", "user0@example.com"))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := suite.repos.UserRepo.UpdateMany(ctx, filter(), map[string]interface{}{
+				"failed_login_attempts": repository.Increment(1),
+			})
+			suite.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	updated := suite.findByEmail("user0@example.com")
+	suite.Equal(10, updated.FailedLoginAttempts)
+	suite.Equal(uint(11), updated.Version)
+}
+
+func (suite *BulkTestSuite) TestUpdateManyRejectsBadColumns() {
+	ctx := context.Background()
+	filter := repository.NewQueryFilter().Where(repository.Eq("role", models.RoleUser))
+
+	_, err := suite.repos.UserRepo.UpdateMany(ctx, filter, nil)
+	suite.ErrorIs(err, repository.ErrNothingToUpdate)
+
+	_, err = suite.repos.UserRepo.UpdateMany(ctx, filter, map[string]interface{}{"_version": 9})
+	suite.ErrorIs(err, repository.ErrColumnNotWritable)
+
+	_, err = suite.repos.UserRepo.UpdateMany(ctx, filter, map[string]interface{}{"nope": 1})
+	suite.ErrorIs(err, repository.ErrColumnNotWritable)
+
+	_, err = suite.repos.UserRepo.UpdateMany(ctx, repository.NewQueryFilter().Where(repository.Eq("username", "x")), map[string]interface{}{"name": "x"})
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+}
+
+func (suite *BulkTestSuite) TestBulkWritesNeedAFilter() {
+	ctx := context.Background()
+
+	users := suite.newUsers(3)
+	_, err := suite.repos.UserRepo.CreateMany(ctx, users, 0)
+	suite.Require().NoError(err)
+
+	for _, filter := range []*repository.Query{nil, repository.NewQueryFilter()} {
+		_, err = suite.repos.UserRepo.UpdateMany(ctx, filter, map[string]interface{}{"name": "wiped"})
+		suite.ErrorIs(err, repository.ErrUnfiltered)
+		suite.ErrorIs(suite.repos.UserRepo.DeleteMany(ctx, filter), repository.ErrUnfiltered)
+		suite.ErrorIs(suite.repos.UserRepo.HardDelete(ctx, filter), repository.ErrUnfiltered)
+		_, err = suite.repos.UserRepo.Restore(ctx, filter)
+		suite.ErrorIs(err, repository.ErrUnfiltered)
+	}
+	suite.Equal("User 0", suite.findByEmail("user0@example.com").Name)
+
+	affected, err := suite.repos.UserRepo.UpdateMany(ctx, repository.NewQueryFilter().All(), map[string]interface{}{"name": "renamed"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), affected)
+
+	suite.Require().NoError(suite.repos.UserRepo.DeleteMany(ctx, repository.NewQueryFilter().All()))
+	count, err := suite.repos.UserRepo.Count(ctx, nil)
+	suite.Require().NoError(err)
+	suite.Zero(count)
+
+	restored, err := suite.repos.UserRepo.Restore(ctx, repository.NewQueryFilter().All())
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), restored)
+}
+
+func (suite *BulkTestSuite) TestUpdateFieldsLeavesCallerSliceAlone() {
+	ctx := context.Background()
+
+	created, err := suite.repos.UserRepo.CreateMany(ctx, suite.newUsers(1), 1)
+	suite.Require().NoError(err)
+
+	fields := make([]string, 1, 4)
+	fields[0] = "name"
+	spare := fields[:cap(fields)]
+
+	user := created[0]
+	user.Name = "Renamed"
+	_, err = suite.repos.UserRepo.UpdateFields(ctx, *user, fields...)
+	suite.Require().NoError(err)
+
+	suite.Equal([]string{"name", "", "", ""}, spare)
+	suite.Equal("Renamed", suite.findByEmail(user.Email).Name)
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/cache"
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type CachedRepositoryTestSuite struct {
+	testutils.BaseSuite
+	dbConn *database.Client
+	user   *models.User
+}
+
+func TestCachedRepository(t *testing.T) {
+	suite.Run(t, new(CachedRepositoryTestSuite))
+}
+
+func (suite *CachedRepositoryTestSuite) SetupTest() {
+	suite.dbConn = testutils.NewTestDatabase(suite.T(), "cached", models.User{}, models.Address{}, models.Post{})
+
+	user, err := suite.uncached().Create(context.Background(), models.User{
+		Name:    "Jane",
+		Email:   "jane@example.com",
+		Address: &models.Address{Street: "1 Main St", City: "Lagos", State: "Lagos", Zipcode: "100001"},
+	})
+	suite.Require().NoError(err)
+	suite.user = user
+}
+
+// uncached writes behind the cache's back, as another instance without it would
+func (suite *CachedRepositoryTestSuite) uncached() *repository.Repository[models.User] {
+	return repository.NewRepository[models.User](suite.dbConn.GetModel("users"))
+}
+
+func (suite *CachedRepositoryTestSuite) stores() map[string]cache.Store {
+	miniRedis := miniredis.RunT(suite.T())
+	return map[string]cache.Store{
+		"lru":   cache.NewLRU(0),
+		"redis": cache.NewRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}), "test:"),
+	}
+}
+
+func (suite *CachedRepositoryTestSuite) byID() *repository.Query {
+	return repository.NewQueryFilter().Where(repository.Eq("id", suite.user.ID))
+}
+
+func (suite *CachedRepositoryTestSuite) rename(name string) {
+	user := *suite.user
+	user.Name = name
+	updated, err := suite.uncached().Update(context.Background(), user)
+	suite.Require().NoError(err)
+	suite.user = updated
+}
+
+func (suite *CachedRepositoryTestSuite) TestServesReadsFromTheCache() {
+	ctx := context.Background()
+
+	for name, store := range suite.stores() {
+		suite.Run(name, func() {
+			repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(store, time.Minute))
+
+			user, err := repos.UserRepo.FindOne(ctx, suite.byID(), "Address")
+			suite.Require().NoError(err)
+			suite.Equal(suite.user.Name, user.Name)
+			suite.Require().NotNil(user.Address)
+
+			before := user.Name
+			suite.rename(before + " (renamed)")
+
+			cached, err := repos.UserRepo.FindOne(ctx, suite.byID(), "Address")
+			suite.Require().NoError(err)
+			suite.Equal(before, cached.Name, "the second read is served from the cache")
+			suite.Equal("Lagos", cached.Address.City, "preloads are cached with the record")
+
+			withoutAddress, err := repos.UserRepo.FindOne(ctx, suite.byID())
+			suite.Require().NoError(err)
+			suite.Equal(suite.user.Name, withoutAddress.Name, "other preloads are a different entry")
+		})
+	}
+}
+
+func (suite *CachedRepositoryTestSuite) TestWritesInvalidate() {
+	ctx := context.Background()
+
+	for name, store := range suite.stores() {
+		suite.Run(name, func() {
+			repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(store, time.Minute))
+
+			count, err := repos.UserRepo.Count(ctx, nil)
+			suite.Require().NoError(err)
+
+			created, err := repos.UserRepo.Create(ctx, models.User{Name: "John", Email: "john-" + name + "@example.com"})
+			suite.Require().NoError(err)
+
+			recount, err := repos.UserRepo.Count(ctx, nil)
+			suite.Require().NoError(err)
+			suite.Equal(count+1, recount)
+
+			byID := repository.NewQueryFilter().Where(repository.Eq("id", created.ID))
+			_, err = repos.UserRepo.FindOne(ctx, byID)
+			suite.Require().NoError(err)
+
+			created.Name = "Johnny"
+			_, err = repos.UserRepo.Update(ctx, *created)
+			suite.Require().NoError(err)
+
+			found, err := repos.UserRepo.FindOne(ctx, byID)
+			suite.Require().NoError(err)
+			suite.Equal("Johnny", found.Name)
+
+			suite.Require().NoError(repos.UserRepo.DeleteMany(ctx, byID))
+			_, err = repos.UserRepo.FindOne(ctx, byID)
+			suite.ErrorIs(err, repository.ErrNotFound)
+		})
+	}
+}
+
+func (suite *CachedRepositoryTestSuite) TestReturnsCopies() {
+	ctx := context.Background()
+	repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(cache.NewLRU(0), time.Minute))
+
+	user, err := repos.UserRepo.FindOne(ctx, suite.byID())
+	suite.Require().NoError(err)
+	user.Name = "changed by the caller"
+
+	again, err := repos.UserRepo.FindOne(ctx, suite.byID())
+	suite.Require().NoError(err)
+	suite.Equal(suite.user.Name, again.Name)
+}
+
+func (suite *CachedRepositoryTestSuite) TestEntriesExpire() {
+	ctx := context.Background()
+	repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(cache.NewLRU(0), 20*time.Millisecond))
+
+	_, err := repos.UserRepo.FindOne(ctx, suite.byID())
+	suite.Require().NoError(err)
+	suite.rename("Expired")
+	time.Sleep(40 * time.Millisecond)
+
+	user, err := repos.UserRepo.FindOne(ctx, suite.byID())
+	suite.Require().NoError(err)
+	suite.Equal("Expired", user.Name)
+}
+
+func (suite *CachedRepositoryTestSuite) TestTransactionsBypassAndInvalidate() {
+	ctx := context.Background()
+	repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(cache.NewLRU(0), time.Minute))
+
+	_, err := repos.UserRepo.FindOne(ctx, suite.byID())
+	suite.Require().NoError(err)
+
+	// Renamed behind the cache's back; outside the transaction since
+	// SQLite queues every write for one connection
+	suite.rename("Renamed outside")
+
+	err = repos.Transaction(ctx, func(tx *repository.Container) error {
+		user, err := tx.UserRepo.FindOne(ctx, suite.byID())
+		suite.Require().NoError(err)
+		suite.Equal("Renamed outside", user.Name, "reads in a transaction skip the cache")
+		return nil
+	})
+	suite.Require().NoError(err)
+
+	user, err := repos.UserRepo.FindOne(ctx, suite.byID())
+	suite.Require().NoError(err)
+	suite.Equal("Renamed outside", user.Name, "the commit invalidates the cache")
+}
+
+func (suite *CachedRepositoryTestSuite) TestCredentialsAreNotCached() {
+	ctx := context.Background()
+
+	for name, store := range suite.stores() {
+		suite.Run(name, func() {
+			repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(store, time.Minute))
+
+			lockedUntil := time.Now().Add(time.Hour)
+			user := *suite.user
+			user.PasswordHash = "old-hash"
+			user.LockedUntil = &lockedUntil
+			updated, err := suite.uncached().UpdateFields(ctx, user, "password_hash", "locked_until")
+			suite.Require().NoError(err)
+
+			cached, err := repos.UserRepo.FindOne(ctx, suite.byID())
+			suite.Require().NoError(err)
+			suite.Empty(cached.PasswordHash)
+			suite.Nil(cached.LockedUntil)
+
+			// A password reset on another instance is seen at once
+			updated.PasswordHash = "new-hash"
+			_, err = suite.uncached().UpdateFields(ctx, *updated, "password_hash")
+			suite.Require().NoError(err)
+			account, err := repos.AccountRepo.FindOne(ctx, suite.byID())
+			suite.Require().NoError(err)
+			suite.Equal("new-hash", account.PasswordHash)
+			suite.NotNil(account.LockedUntil)
+
+			// Account writes invalidate the cached reads
+			verifiedAt := time.Now()
+			account.EmailVerifiedAt = &verifiedAt
+			updated, err = repos.AccountRepo.UpdateFields(ctx, *account, "email_verified_at")
+			suite.Require().NoError(err)
+			suite.user = updated
+			cached, err = repos.UserRepo.FindOne(ctx, suite.byID())
+			suite.Require().NoError(err)
+			suite.NotNil(cached.EmailVerifiedAt)
+		})
+	}
+}
+
+func (suite *CachedRepositoryTestSuite) TestFailedInvalidationIsLogged() {
+	ctx := context.Background()
+	miniRedis := miniredis.RunT(suite.T())
+	store := cache.NewRedis(redis.NewClient(&redis.Options{Addr: miniRedis.Addr()}), "test:")
+
+	mockLogger := new(loggermocks.Logger)
+	mockLogger.On("Error", "failed to invalidate the cache, reads may be stale until they expire", mock.Anything, mock.Anything, mock.Anything).Return().Once()
+	repos := repository.NewRepositoryContainer(mockLogger, suite.dbConn, repository.WithCache(store, time.Minute))
+
+	miniRedis.Close()
+	_, err := repos.UserRepo.Create(ctx, models.User{Name: "John", Email: "john@example.com"})
+	suite.Require().NoError(err, "the write succeeds even when the cache is down")
+	mockLogger.AssertExpectations(suite.T())
+}
+
+func (suite *CachedRepositoryTestSuite) TestInvalidQueriesReachTheRepository() {
+	repos := repository.NewRepositoryContainer(new(loggermocks.Logger), suite.dbConn, repository.WithCache(cache.NewLRU(0), time.Minute))
+
+	_, err := repos.UserRepo.FindOne(context.Background(), repository.NewQueryFilter().Where(repository.Eq("password_hash", "x")))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type CursorTestSuite struct {
+	testutils.BaseSuite
+	postRepo *repository.Repository[models.Post]
+	posts    []models.Post // newest first
+}
+
+func TestCursorPagination(t *testing.T) {
+	suite.Run(t, new(CursorTestSuite))
+}
+
+func (suite *CursorTestSuite) SetupSuite() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "cursor", models.Post{})
+
+	suite.postRepo = repository.NewRepository[models.Post](dbConn.GetModel("posts"))
+
+	// Pairs of posts share a timestamp so the id tie-breaker is exercised
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		createdAt := base.Add(time.Duration(i/2) * time.Minute)
+		post := models.Post{
+			Shared: models.Shared{ID: fmt.Sprintf("post-%02d", i), CreatedAt: &createdAt},
+			UserID: "user-1",
+			Title:  fmt.Sprintf("Post %d", i),
+			Body:   "body",
+		}
+		_, err := suite.postRepo.Create(context.Background(), post)
+		suite.Require().NoError(err)
+		suite.posts = append([]models.Post{post}, suite.posts...)
+	}
+
+	other := models.Post{UserID: "user-2", Title: "Someone else's", Body: "body"}
+	_, err := suite.postRepo.Create(context.Background(), other)
+	suite.Require().NoError(err)
+}
+
+func (suite *CursorTestSuite) ids(posts []*models.Post) []string {
+	ids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func (suite *CursorTestSuite) expectedIDs(from, to int) []string {
+	ids := make([]string, 0, to-from)
+	for _, p := range suite.posts[from:to] {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func (suite *CursorTestSuite) TestWalksForwardAndBack() {
+	ctx := context.Background()
+	filter := func() *repository.Query { return repository.NewQueryFilter().Where(repository.Eq("user_id", "user-1")) }
+
+	first, page, err := suite.postRepo.FindManyCursor(ctx, filter(), "", 10)
+	suite.Require().NoError(err)
+	suite.Equal(suite.expectedIDs(0, 10), suite.ids(first))
+	suite.True(page.HasNext)
+	suite.False(page.HasPrev)
+	suite.Empty(page.PrevCursor)
+
+	second, page, err := suite.postRepo.FindManyCursor(ctx, filter(), page.NextCursor, 10)
+	suite.Require().NoError(err)
+	suite.Equal(suite.expectedIDs(10, 20), suite.ids(second))
+	suite.True(page.HasNext)
+	suite.True(page.HasPrev)
+
+	secondPrev := page.PrevCursor
+
+	last, page, err := suite.postRepo.FindManyCursor(ctx, filter(), page.NextCursor, 10)
+	suite.Require().NoError(err)
+	suite.Equal(suite.expectedIDs(20, 25), suite.ids(last))
+	suite.False(page.HasNext)
+	suite.Empty(page.NextCursor)
+	suite.True(page.HasPrev)
+
+	back, page, err := suite.postRepo.FindManyCursor(ctx, filter(), page.PrevCursor, 10)
+	suite.Require().NoError(err)
+	suite.Equal(suite.ids(second), suite.ids(back))
+	suite.True(page.HasNext)
+	suite.True(page.HasPrev)
+
+	backToStart, page, err := suite.postRepo.FindManyCursor(ctx, filter(), secondPrev, 10)
+	suite.Require().NoError(err)
+	suite.Equal(suite.ids(first), suite.ids(backToStart))
+	suite.False(page.HasPrev)
+	suite.True(page.HasNext)
+}
+
+func (suite *CursorTestSuite) TestExactPageBoundary() {
+	ctx := context.Background()
+	filter := repository.NewQueryFilter().Where(repository.Eq("user_id", "user-1"))
+
+	posts, page, err := suite.postRepo.FindManyCursor(ctx, filter, "", 25)
+	suite.Require().NoError(err)
+	suite.Len(posts, 25)
+	suite.False(page.HasNext)
+	suite.Empty(page.NextCursor)
+}
+
+func (suite *CursorTestSuite) TestRejectsInvalidCursor() {
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "eyJpZCI6IiJ9"} {
+		_, _, err := suite.postRepo.FindManyCursor(context.Background(), nil, cursor, 10)
+		suite.ErrorIs(err, repository.ErrInvalidCursor, cursor)
+	}
+}
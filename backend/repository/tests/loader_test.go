@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+// countingFinder records the batches a Loader asks for
+type countingFinder struct {
+	inner repository.IDFinder[models.User]
+	mu    sync.Mutex
+	calls [][]string
+	err   error
+}
+
+func (f *countingFinder) FindByIDs(ctx context.Context, ids []string, preloads ...string) ([]*models.User, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, ids)
+	err := f.err
+	f.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return f.inner.FindByIDs(ctx, ids, preloads...)
+}
+
+type LoaderTestSuite struct {
+	testutils.BaseSuite
+	userRepo *repository.Repository[models.User]
+}
+
+func TestLoader(t *testing.T) {
+	suite.Run(t, new(LoaderTestSuite))
+}
+
+func (suite *LoaderTestSuite) SetupTest() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "loader", models.User{}, models.Address{})
+	suite.userRepo = repository.NewRepository[models.User](dbConn.GetModel("users"))
+
+	for _, id := range []string{"alice", "bob", "carol"} {
+		_, err := suite.userRepo.Create(context.Background(), models.User{Shared: models.Shared{ID: id}, Name: id, Email: id + "@example.com"})
+		suite.Require().NoError(err)
+	}
+}
+
+func (suite *LoaderTestSuite) ids(users []*models.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func (suite *LoaderTestSuite) TestFindByIDs() {
+	users, err := suite.userRepo.FindByIDs(context.Background(), []string{"carol", "missing", "alice", "carol"})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"carol", "alice"}, suite.ids(users), "in the order asked for, once each, missing IDs skipped")
+
+	users, err = suite.userRepo.FindByIDs(context.Background(), nil)
+	suite.Require().NoError(err)
+	suite.Empty(users)
+}
+
+func (suite *LoaderTestSuite) TestFindByIDsSplitsLongLists() {
+	ids := make([]string, 0, 1200)
+	for i := 0; i < 1200; i++ {
+		ids = append(ids, fmt.Sprintf("user-%d", i))
+	}
+	ids = append(ids, "bob")
+
+	users, err := suite.userRepo.FindByIDs(context.Background(), ids)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"bob"}, suite.ids(users))
+}
+
+func (suite *LoaderTestSuite) TestPrimedLookupsShareAQuery() {
+	finder := &countingFinder{inner: suite.userRepo}
+	loader := repository.NewLoader[models.User](finder)
+	ctx := context.Background()
+
+	loader.Prime("alice", "bob", "missing")
+	for _, id := range []string{"alice", "bob", "alice"} {
+		user, err := loader.Load(ctx, id)
+		suite.Require().NoError(err)
+		suite.Equal(id, user.ID)
+	}
+	_, err := loader.Load(ctx, "missing")
+	suite.ErrorIs(err, repository.ErrNotFound)
+
+	suite.Equal([][]string{{"alice", "bob", "missing"}}, finder.calls)
+
+	users, err := loader.LoadMany(ctx, []string{"alice", "carol", "nobody"})
+	suite.Require().NoError(err)
+	suite.Len(users, 2)
+	suite.Equal("carol", users["carol"].ID)
+	suite.Equal([][]string{{"alice", "bob", "missing"}, {"carol", "nobody"}}, finder.calls, "only new IDs are fetched")
+}
+
+func (suite *LoaderTestSuite) TestConcurrentLookups() {
+	finder := &countingFinder{inner: suite.userRepo}
+	loader := repository.NewLoader[models.User](finder)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := loader.Load(context.Background(), "alice")
+			suite.NoError(err)
+			suite.Equal("alice", user.ID)
+		}()
+	}
+	wg.Wait()
+
+	suite.Len(finder.calls, 1)
+}
+
+func (suite *LoaderTestSuite) TestFailedLookupsAreRetried() {
+	finder := &countingFinder{inner: suite.userRepo, err: errors.New("database is locked")}
+	loader := repository.NewLoader[models.User](finder)
+
+	_, err := loader.Load(context.Background(), "alice")
+	suite.EqualError(err, "database is locked")
+
+	finder.err = nil
+	user, err := loader.Load(context.Background(), "alice")
+	suite.Require().NoError(err)
+	suite.Equal("alice", user.ID)
+	suite.Len(finder.calls, 2)
+}
+
+func (suite *LoaderTestSuite) TestLoadersTravelWithTheContext() {
+	_, ok := repository.LoadersFromContext(context.Background())
+	suite.False(ok)
+
+	loaders := &repository.Loaders{Users: repository.NewLoader[models.User](suite.userRepo)}
+	found, ok := repository.LoadersFromContext(repository.WithLoaders(context.Background(), loaders))
+	suite.True(ok)
+	suite.Same(loaders, found)
+}
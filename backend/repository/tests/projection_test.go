@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type ProjectionTestSuite struct {
+	testutils.BaseSuite
+	repos *repository.Container
+	user  *models.User
+}
+
+func TestProjection(t *testing.T) {
+	suite.Run(t, new(ProjectionTestSuite))
+}
+
+func (suite *ProjectionTestSuite) SetupTest() {
+	ctx := context.Background()
+	dbConn := testutils.NewTestDatabase(suite.T(), "projection", models.User{}, models.Address{}, models.Post{})
+	suite.repos = repository.NewRepositoryContainer(new(loggermocks.Logger), dbConn)
+
+	user, err := suite.repos.UserRepo.Create(ctx, models.User{
+		Name:     "Jane",
+		Username: "jane",
+		Email:    "jane@example.com",
+		Address:  &models.Address{Street: "1 Main St", City: "Lagos", State: "Lagos", Zipcode: "100001"},
+	})
+	suite.Require().NoError(err)
+	suite.user = user
+
+	_, err = suite.repos.PostRepo.CreateMany(ctx, []models.Post{
+		{UserID: user.ID, Title: "first", Body: "a long body"},
+		{UserID: user.ID, Title: "second", Body: "another long body"},
+	}, 0)
+	suite.Require().NoError(err)
+}
+
+func (suite *ProjectionTestSuite) byID() *repository.Query {
+	return repository.NewQueryFilter().Where(repository.Eq("id", suite.user.ID))
+}
+
+func (suite *ProjectionTestSuite) TestSelectLoadsOnlyColumnsAndKeys() {
+	user, err := suite.repos.UserRepo.FindOne(context.Background(), suite.byID().Select("name"))
+	suite.Require().NoError(err)
+
+	suite.Equal(suite.user.ID, user.ID)
+	suite.Equal("Jane", user.Name)
+	suite.Empty(user.Email)
+	suite.Empty(user.Username)
+}
+
+func (suite *ProjectionTestSuite) TestOmitSkipsColumns() {
+	posts, paginator, err := suite.repos.PostRepo.FindManyPaginated(context.Background(),
+		repository.NewQueryFilter().Omit("body", "id").OrderBy("title", repository.Asc), 1, 10)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), paginator.TotalRows)
+	suite.Require().Len(posts, 2)
+
+	for _, post := range posts {
+		suite.NotEmpty(post.ID, "keys are never omitted")
+		suite.NotEmpty(post.Title)
+		suite.Empty(post.Body)
+	}
+}
+
+func (suite *ProjectionTestSuite) TestPreloadWithColumns() {
+	user, err := suite.repos.UserRepo.FindOne(context.Background(),
+		suite.byID().Select("name").Preload("Address", "city", "state"))
+	suite.Require().NoError(err)
+
+	suite.Require().NotNil(user.Address, "the keys linking the address are loaded")
+	suite.Equal(suite.user.ID, user.Address.UserID)
+	suite.NotEmpty(user.Address.ID)
+	suite.Equal("Lagos", user.Address.City)
+	suite.Empty(user.Address.Street)
+}
+
+func (suite *ProjectionTestSuite) TestPreloadWithColumnsHidesDeletedRecords() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, repository.NewQueryFilter().Where(repository.Eq("title", "first"))))
+
+	user, err := suite.repos.UserRepo.FindOne(ctx, suite.byID().Preload("Posts", "title"))
+	suite.Require().NoError(err)
+	suite.Require().Len(user.Posts, 1)
+	suite.Equal("second", user.Posts[0].Title)
+	suite.Empty(user.Posts[0].Body)
+}
+
+func (suite *ProjectionTestSuite) TestCursorWithSelect() {
+	posts, page, err := suite.repos.PostRepo.FindManyCursor(context.Background(),
+		repository.NewQueryFilter().Select("title"), "", 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(posts, 1)
+	suite.True(page.HasNext)
+	suite.NotEmpty(page.NextCursor, "the cursor keys are loaded")
+
+	next, _, err := suite.repos.PostRepo.FindManyCursor(context.Background(),
+		repository.NewQueryFilter().Select("title"), page.NextCursor, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(next, 1)
+	suite.NotEqual(posts[0].ID, next[0].ID)
+}
+
+func (suite *ProjectionTestSuite) TestRejectsUnknownColumnsAndRelations() {
+	ctx := context.Background()
+
+	_, err := suite.repos.UserRepo.FindOne(ctx, suite.byID().Select("nope"))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+
+	_, err = suite.repos.UserRepo.FindOne(ctx, suite.byID().Omit("nope"))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+
+	_, err = suite.repos.UserRepo.FindOne(ctx, suite.byID().Preload("Friends"))
+	suite.ErrorIs(err, repository.ErrUnknownRelation)
+
+	_, err = suite.repos.UserRepo.FindOne(ctx, suite.byID().Preload("Address", "nope"))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type QueryTestSuite struct {
+	testutils.BaseSuite
+	tokenRepo *repository.Repository[models.UserToken]
+	base      time.Time
+}
+
+func TestQueryBuilder(t *testing.T) {
+	suite.Run(t, new(QueryTestSuite))
+}
+
+func (suite *QueryTestSuite) SetupSuite() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "query", models.UserToken{})
+
+	suite.tokenRepo = repository.NewRepository[models.UserToken](dbConn.GetModel("user_tokens"))
+	suite.base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	usedAt := suite.base
+	tokens := []models.UserToken{
+		{Shared: models.Shared{ID: "t1"}, UserID: "alice", Purpose: models.TokenPurposeEmailVerification, TokenHash: "hash-1", ExpiresAt: suite.base.Add(1 * time.Hour)},
+		{Shared: models.Shared{ID: "t2"}, UserID: "alice", Purpose: models.TokenPurposePasswordReset, TokenHash: "hash-2", ExpiresAt: suite.base.Add(2 * time.Hour), UsedAt: &usedAt},
+		{Shared: models.Shared{ID: "t3"}, UserID: "bob", Purpose: models.TokenPurposePasswordReset, TokenHash: "other-3", ExpiresAt: suite.base.Add(3 * time.Hour)},
+		{Shared: models.Shared{ID: "t4"}, UserID: "carol", Purpose: models.TokenPurposeTwoFactorLogin, TokenHash: "other-4", ExpiresAt: suite.base.Add(4 * time.Hour)},
+	}
+	for _, token := range tokens {
+		_, err := suite.tokenRepo.Create(context.Background(), token)
+		suite.Require().NoError(err)
+	}
+}
+
+func (suite *QueryTestSuite) find(query *repository.Query) []string {
+	tokens, err := suite.tokenRepo.FindMany(context.Background(), query.OrderBy("id", repository.Asc))
+	suite.Require().NoError(err)
+
+	ids := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.ID)
+	}
+	return ids
+}
+
+func (suite *QueryTestSuite) TestConditions() {
+	testCases := []struct {
+		name     string
+		query    *repository.Query
+		expected []string
+	}{
+		{
+			name:     "eq",
+			query:    repository.NewQueryFilter().Where(repository.Eq("user_id", "alice")),
+			expected: []string{"t1", "t2"},
+		},
+		{
+			name:     "conditions are combined with and",
+			query:    repository.NewQueryFilter().Where(repository.Eq("user_id", "alice")).Where(repository.Eq("purpose", models.TokenPurposePasswordReset)),
+			expected: []string{"t2"},
+		},
+		{
+			name:     "in",
+			query:    repository.NewQueryFilter().Where(repository.In("user_id", "bob", "carol")),
+			expected: []string{"t3", "t4"},
+		},
+		{
+			name:     "in without values matches nothing",
+			query:    repository.NewQueryFilter().Where(repository.In[string]("user_id")),
+			expected: []string{},
+		},
+		{
+			name:     "like",
+			query:    repository.NewQueryFilter().Where(repository.Like("token_hash", "hash-%")),
+			expected: []string{"t1", "t2"},
+		},
+		{
+			name:     "range",
+			query:    repository.NewQueryFilter().Where(repository.Range("expires_at", suite.base.Add(2*time.Hour), suite.base.Add(4*time.Hour))),
+			expected: []string{"t2", "t3"},
+		},
+		{
+			name:     "open ended range",
+			query:    repository.NewQueryFilter().Where(repository.Range("expires_at", suite.base.Add(3*time.Hour), nil)),
+			expected: []string{"t3", "t4"},
+		},
+		{
+			name:     "is null",
+			query:    repository.NewQueryFilter().Where(repository.Eq("user_id", "alice"), repository.IsNull("used_at")),
+			expected: []string{"t1"},
+		},
+		{
+			name: "or group",
+			query: repository.NewQueryFilter().Where(
+				repository.IsNull("used_at"),
+				repository.Or(
+					repository.Eq("user_id", "alice"),
+					repository.And(repository.Eq("user_id", "carol"), repository.Eq("purpose", models.TokenPurposeTwoFactorLogin)),
+				),
+			),
+			expected: []string{"t1", "t4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.Equal(tc.expected, suite.find(tc.query))
+		})
+	}
+}
+
+func (suite *QueryTestSuite) TestOrderAndLimit() {
+	query := repository.NewQueryFilter().
+		Where(repository.Eq("purpose", models.TokenPurposePasswordReset)).
+		OrderBy("expires_at", repository.Desc)
+
+	token, err := suite.tokenRepo.FindOne(context.Background(), query)
+	suite.Require().NoError(err)
+	suite.Equal("t3", token.ID)
+
+	tokens, err := suite.tokenRepo.FindMany(context.Background(), repository.NewQueryFilter().OrderBy("expires_at", repository.Desc).Limit(2))
+	suite.Require().NoError(err)
+	suite.Require().Len(tokens, 2)
+	suite.Equal("t4", tokens[0].ID)
+	suite.Equal("t3", tokens[1].ID)
+}
+
+func (suite *QueryTestSuite) TestRejectsColumnsOutsideAllowList() {
+	ctx := context.Background()
+
+	_, err := suite.tokenRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("1=1; DROP TABLE user_tokens; --", "x")))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+
+	_, _, err = suite.tokenRepo.FindManyPaginated(ctx, repository.NewQueryFilter().OrderBy("version", repository.Asc), 1, 10)
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+
+	err = suite.tokenRepo.DeleteMany(ctx, repository.NewQueryFilter().Where(repository.Or(repository.IsNull("not_a_column"))))
+	suite.ErrorIs(err, repository.ErrColumnNotAllowed)
+
+	count, err := suite.tokenRepo.Count(ctx, nil)
+	suite.Require().NoError(err)
+	suite.Equal(int64(4), count)
+}
+
+func (suite *QueryTestSuite) TestForUpdateLocksOnServerDialects() {
+	lock := func(repos *repository.Container) {
+		_, _ = repos.UserRepo.FindOne(context.Background(), repository.NewQueryFilter().Where(repository.Eq("id", "user-1")).ForUpdate())
+	}
+
+	suite.Contains(serverSQL(&suite.BaseSuite, database.DialectPostgres, lock), "FOR UPDATE")
+	suite.Contains(serverSQL(&suite.BaseSuite, database.DialectMySQL, lock), "FOR UPDATE")
+
+	// SQLite has no row locks, so the clause is left out
+	token, err := suite.tokenRepo.FindOne(context.Background(), repository.NewQueryFilter().Where(repository.Eq("id", "t1")).ForUpdate())
+	suite.Require().NoError(err)
+	suite.Equal("alice", token.UserID)
+}
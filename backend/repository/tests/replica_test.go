@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/database"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+)
+
+type ReplicaTestSuite struct {
+	testutils.BaseSuite
+
+	// primary and replica are direct connections used to arrange and inspect data
+	primary *database.Client
+	replica *database.Client
+
+	routed         *database.Client
+	postRepo       *repository.Repository[models.Post]
+	replicaHealthy atomic.Bool
+}
+
+func TestReplicaRouting(t *testing.T) {
+	suite.Run(t, new(ReplicaTestSuite))
+}
+
+func (suite *ReplicaTestSuite) open(path string) *database.Client {
+	dbConn, err := database.Initialize(&database.Config{DB: path})
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { _ = dbConn.Close() })
+	suite.Require().NoError(dbConn.Migrate(models.Post{}))
+	return dbConn
+}
+
+func (suite *ReplicaTestSuite) SetupTest() {
+	dir := suite.T().TempDir()
+	primaryPath, replicaPath := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+
+	// Replication is simulated: the two files are kept apart so the test can
+	// tell which one answered
+	suite.primary = suite.open(primaryPath)
+	suite.replica = suite.open(replicaPath)
+
+	suite.replicaHealthy.Store(true)
+	routed, err := database.Initialize(&database.Config{
+		DB:                   primaryPath,
+		Replicas:             []string{"sqlite://" + replicaPath},
+		ReplicaCheckInterval: 20 * time.Millisecond,
+		ReplicaHealthCheck: func(ctx context.Context, db *sql.DB) error {
+			if !suite.replicaHealthy.Load() {
+				return errors.New("replica is down")
+			}
+			return db.PingContext(ctx)
+		},
+	})
+	suite.Require().NoError(err)
+	suite.T().Cleanup(func() { _ = routed.Close() })
+
+	suite.routed = routed
+	suite.postRepo = repository.NewRepository[models.Post](routed.GetModel("posts"))
+}
+
+func (suite *ReplicaTestSuite) insert(dbConn *database.Client, title string) {
+	post := models.Post{UserID: "user-1", Title: title, Body: "body"}
+	post.PreValidate()
+	suite.Require().NoError(dbConn.DB.Create(&post).Error)
+}
+
+func (suite *ReplicaTestSuite) count(ctx context.Context) int64 {
+	count, err := suite.postRepo.Count(ctx, nil)
+	suite.Require().NoError(err)
+	return count
+}
+
+func (suite *ReplicaTestSuite) TestReadsUseReplicaAndWritesUsePrimary() {
+	ctx := context.Background()
+	suite.insert(suite.replica, "only on the replica")
+
+	post, err := suite.postRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("title", "only on the replica")))
+	suite.Require().NoError(err)
+	suite.Equal("only on the replica", post.Title)
+
+	posts, paginator, err := suite.postRepo.FindManyPaginated(ctx, nil, 1, 10)
+	suite.Require().NoError(err)
+	suite.Len(posts, 1)
+	suite.Equal(int64(1), paginator.TotalRows)
+
+	_, err = suite.postRepo.Create(ctx, models.Post{UserID: "user-1", Title: "written", Body: "body"})
+	suite.Require().NoError(err)
+
+	var onPrimary int64
+	suite.Require().NoError(suite.primary.DB.Model(&models.Post{}).Count(&onPrimary).Error)
+	suite.Equal(int64(1), onPrimary)
+	suite.Equal(int64(1), suite.count(ctx), "the write has not reached the replica")
+}
+
+func (suite *ReplicaTestSuite) TestReadYourWrites() {
+	ctx := database.ReadYourWrites(context.Background())
+	suite.insert(suite.replica, "replica a")
+	suite.insert(suite.replica, "replica b")
+
+	suite.Equal(int64(2), suite.count(ctx))
+
+	_, err := suite.postRepo.Create(ctx, models.Post{UserID: "user-1", Title: "mine", Body: "body"})
+	suite.Require().NoError(err)
+
+	suite.Equal(int64(1), suite.count(ctx), "reads after a write must come from the primary")
+	suite.Equal(int64(2), suite.count(context.Background()), "other contexts keep using the replica")
+	suite.Equal(int64(1), suite.count(database.UsePrimary(context.Background())))
+}
+
+func (suite *ReplicaTestSuite) TestFailingReplicaIsEjectedAndReadmitted() {
+	ctx := context.Background()
+	suite.insert(suite.primary, "primary")
+
+	// The replica breaks: its reads fail and so does its health check
+	suite.Require().NoError(suite.replica.DB.Migrator().DropTable(&models.Post{}))
+	suite.replicaHealthy.Store(false)
+
+	suite.Equal(int64(1), suite.count(ctx), "a failed replica read is retried on the primary")
+	suite.Equal([]database.ReplicaStatus{{Name: suite.routed.ReplicaStatuses()[0].Name, Healthy: false}}, suite.routed.ReplicaStatuses())
+	suite.Equal(int64(1), suite.count(ctx))
+
+	suite.Require().NoError(suite.replica.Migrate(models.Post{}))
+	suite.replicaHealthy.Store(true)
+
+	suite.Eventually(func() bool {
+		return suite.routed.ReplicaStatuses()[0].Healthy
+	}, time.Second, 10*time.Millisecond)
+	suite.Equal(int64(0), suite.count(ctx), "the recovered replica serves reads again")
+}
+
+func (suite *ReplicaTestSuite) TestQueryErrorOnHealthyReplicaIsReturned() {
+	suite.Require().NoError(suite.replica.DB.Migrator().DropTable(&models.Post{}))
+
+	_, err := suite.postRepo.Count(context.Background(), nil)
+	suite.Error(err)
+	suite.True(suite.routed.ReplicaStatuses()[0].Healthy, "a replica that passes its check stays in rotation")
+}
+
+func (suite *ReplicaTestSuite) TestReplicaMustShareDialect() {
+	_, err := database.Initialize(&database.Config{
+		DB:       filepath.Join(suite.T().TempDir(), "primary.db"),
+		Replicas: []string{"postgres://lema@localhost/lema"},
+	})
+	suite.Error(err)
+}
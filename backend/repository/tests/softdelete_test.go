@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type SoftDeleteTestSuite struct {
+	testutils.BaseSuite
+	repos *repository.Container
+	user  *models.User
+}
+
+func TestSoftDelete(t *testing.T) {
+	suite.Run(t, new(SoftDeleteTestSuite))
+}
+
+func (suite *SoftDeleteTestSuite) SetupTest() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "softdelete",
+		models.User{}, models.Address{}, models.Post{}, models.RecoveryCode{})
+	suite.repos = repository.NewRepositoryContainer(new(loggermocks.Logger), dbConn)
+
+	user, err := suite.repos.UserRepo.Create(context.Background(), models.User{Name: "Jane", Email: "jane@example.com"})
+	suite.Require().NoError(err)
+	suite.user = user
+
+	_, err = suite.repos.PostRepo.CreateMany(context.Background(), []models.Post{
+		{UserID: user.ID, Title: "first", Body: "body"},
+		{UserID: user.ID, Title: "second", Body: "body"},
+	}, 0)
+	suite.Require().NoError(err)
+}
+
+func (suite *SoftDeleteTestSuite) byTitle(title string) *repository.Query {
+	return repository.NewQueryFilter().Where(repository.Eq("title", title))
+}
+
+func (suite *SoftDeleteTestSuite) count(query *repository.Query) int64 {
+	count, err := suite.repos.PostRepo.Count(context.Background(), query)
+	suite.Require().NoError(err)
+	return count
+}
+
+func (suite *SoftDeleteTestSuite) TestDeleteManyHidesRecords() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, suite.byTitle("first")))
+
+	_, err := suite.repos.PostRepo.FindOne(ctx, suite.byTitle("first"))
+	suite.ErrorIs(err, repository.ErrNotFound)
+	suite.Equal(int64(1), suite.count(nil))
+	suite.Equal(int64(2), suite.count(repository.NewQueryFilter().WithDeleted()))
+	suite.Equal(int64(1), suite.count(repository.NewQueryFilter().OnlyDeleted()))
+
+	deleted, err := suite.repos.PostRepo.FindOne(ctx, suite.byTitle("first").OnlyDeleted())
+	suite.Require().NoError(err)
+	suite.NotNil(deleted.DeletedAt)
+	suite.Equal(uint(2), deleted.Version)
+}
+
+func (suite *SoftDeleteTestSuite) TestPreloadLeavesOutDeletedRecords() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, suite.byTitle("first")))
+
+	byID := repository.NewQueryFilter().Where(repository.Eq("id", suite.user.ID))
+	user, err := suite.repos.UserRepo.FindOne(ctx, byID, "Posts")
+	suite.Require().NoError(err)
+	suite.Len(user.Posts, 1)
+
+	user, err = suite.repos.UserRepo.FindOne(ctx, byID.WithDeleted(), "Posts")
+	suite.Require().NoError(err)
+	suite.Len(user.Posts, 2)
+}
+
+func (suite *SoftDeleteTestSuite) TestUpdateManySkipsDeletedRecords() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, suite.byTitle("first")))
+
+	affected, err := suite.repos.PostRepo.UpdateMany(ctx,
+		repository.NewQueryFilter().Where(repository.Eq("user_id", suite.user.ID)),
+		map[string]interface{}{"body": "edited"})
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), affected)
+}
+
+func (suite *SoftDeleteTestSuite) TestRestore() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, suite.byTitle("first")))
+
+	restored, err := suite.repos.PostRepo.Restore(ctx, suite.byTitle("first"))
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), restored)
+
+	post, err := suite.repos.PostRepo.FindOne(ctx, suite.byTitle("first"))
+	suite.Require().NoError(err)
+	suite.Nil(post.DeletedAt)
+	suite.Equal(uint(3), post.Version)
+
+	restored, err = suite.repos.PostRepo.Restore(ctx, suite.byTitle("second"))
+	suite.Require().NoError(err)
+	suite.Zero(restored, "live records are not restored")
+}
+
+func (suite *SoftDeleteTestSuite) TestHardDelete() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, suite.byTitle("first")))
+	suite.Require().NoError(suite.repos.PostRepo.HardDelete(ctx, repository.NewQueryFilter().Where(repository.Eq("user_id", suite.user.ID))))
+
+	suite.Zero(suite.count(repository.NewQueryFilter().WithDeleted()))
+}
+
+func (suite *SoftDeleteTestSuite) TestPurgeRemovesOldDeletedRecords() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.repos.PostRepo.DeleteMany(ctx, suite.byTitle("first")))
+
+	purged, err := suite.repos.PostRepo.Purge(ctx, time.Now().Add(-time.Hour))
+	suite.Require().NoError(err)
+	suite.Zero(purged, "recently deleted records are kept")
+
+	purged, err = suite.repos.PostRepo.Purge(ctx, time.Now().Add(time.Second))
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), purged)
+
+	suite.Equal(int64(1), suite.count(repository.NewQueryFilter().WithDeleted()))
+}
+
+func (suite *SoftDeleteTestSuite) TestModelsWithoutSoftDeleteAreRemoved() {
+	ctx := context.Background()
+
+	_, err := suite.repos.RecoveryCodeRepo.Create(ctx, models.RecoveryCode{UserID: suite.user.ID, CodeHash: "hash"})
+	suite.Require().NoError(err)
+
+	byUser := repository.NewQueryFilter().Where(repository.Eq("user_id", suite.user.ID))
+	suite.Require().NoError(suite.repos.RecoveryCodeRepo.DeleteMany(ctx, byUser))
+
+	count, err := suite.repos.RecoveryCodeRepo.Count(ctx, repository.NewQueryFilter().WithDeleted())
+	suite.Require().NoError(err)
+	suite.Zero(count)
+
+	_, err = suite.repos.RecoveryCodeRepo.Restore(ctx, byUser)
+	suite.ErrorIs(err, repository.ErrNotSoftDeletable)
+}
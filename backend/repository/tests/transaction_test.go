@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+var errAbort = errors.New("abort")
+
+type TransactionTestSuite struct {
+	testutils.BaseSuite
+	repos  *repository.Container
+	userID string
+}
+
+func TestTransaction(t *testing.T) {
+	suite.Run(t, new(TransactionTestSuite))
+}
+
+func (suite *TransactionTestSuite) SetupTest() {
+	dbConn := testutils.NewTestDatabase(suite.T(), "tx", models.User{}, models.Address{}, models.Post{})
+
+	suite.repos = repository.NewRepositoryContainer(new(loggermocks.Logger), dbConn)
+
+	// Posts reference their author, which server databases enforce
+	user, err := suite.repos.UserRepo.Create(context.Background(), models.User{Name: "Jane", Email: "jane@example.com"})
+	suite.Require().NoError(err)
+	suite.userID = user.ID
+}
+
+func (suite *TransactionTestSuite) createPost(ctx context.Context, repos *repository.Container, title string) {
+	_, err := repos.PostRepo.Create(ctx, models.Post{UserID: suite.userID, Title: title, Body: "body"})
+	suite.Require().NoError(err)
+}
+
+func (suite *TransactionTestSuite) titles() []string {
+	posts, err := suite.repos.PostRepo.FindMany(context.Background(), repository.NewQueryFilter().OrderBy("title", repository.Asc))
+	suite.Require().NoError(err)
+
+	titles := make([]string, 0, len(posts))
+	for _, p := range posts {
+		titles = append(titles, p.Title)
+	}
+	return titles
+}
+
+func (suite *TransactionTestSuite) TestCommitsAcrossRepositories() {
+	ctx := context.Background()
+
+	err := suite.repos.Transaction(ctx, func(tx *repository.Container) error {
+		user, err := tx.UserRepo.Create(ctx, models.User{Name: "John", Email: "john@example.com"})
+		if err != nil {
+			return err
+		}
+		_, err = tx.PostRepo.Create(ctx, models.Post{UserID: user.ID, Title: "a", Body: "body"})
+		return err
+	})
+	suite.Require().NoError(err)
+
+	suite.Equal([]string{"a"}, suite.titles())
+	count, err := suite.repos.UserRepo.Count(ctx, nil)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), count)
+}
+
+func (suite *TransactionTestSuite) TestRollsBackOnError() {
+	ctx := context.Background()
+
+	err := suite.repos.Transaction(ctx, func(tx *repository.Container) error {
+		suite.createPost(ctx, tx, "a")
+		return errAbort
+	})
+	suite.ErrorIs(err, errAbort)
+	suite.Empty(suite.titles())
+}
+
+func (suite *TransactionTestSuite) TestRollsBackOnPanic() {
+	ctx := context.Background()
+
+	suite.PanicsWithValue("boom", func() {
+		_ = suite.repos.Transaction(ctx, func(tx *repository.Container) error {
+			suite.createPost(ctx, tx, "a")
+			panic("boom")
+		})
+	})
+	suite.Empty(suite.titles())
+}
+
+func (suite *TransactionTestSuite) TestNestedSavepoints() {
+	ctx := context.Background()
+
+	err := suite.repos.Transaction(ctx, func(tx *repository.Container) error {
+		suite.createPost(ctx, tx, "outer")
+
+		innerErr := tx.Transaction(ctx, func(inner *repository.Container) error {
+			suite.createPost(ctx, inner, "discarded")
+			return errAbort
+		})
+		suite.ErrorIs(innerErr, errAbort)
+
+		return tx.Transaction(ctx, func(inner *repository.Container) error {
+			suite.createPost(ctx, inner, "kept")
+			return nil
+		})
+	})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"kept", "outer"}, suite.titles())
+}
+
+func (suite *TransactionTestSuite) TestOuterRollbackUndoesSavepoints() {
+	ctx := context.Background()
+
+	err := suite.repos.Transaction(ctx, func(tx *repository.Container) error {
+		suite.Require().NoError(tx.Transaction(ctx, func(inner *repository.Container) error {
+			suite.createPost(ctx, inner, "inner")
+			return nil
+		}))
+		return errAbort
+	})
+	suite.ErrorIs(err, errAbort)
+	suite.Empty(suite.titles())
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/tejiriaustin/lema/database"
+)
+
+// UnitOfWork runs a group of repository calls atomically
+type UnitOfWork interface {
+	Transaction(ctx context.Context, fn func(tx *Container) error) error
+}
+
+var _ UnitOfWork = (*Container)(nil)
+
+// Transaction calls fn with a Container whose repositories all share one
+// database transaction. It commits when fn returns nil and rolls back when fn
+// returns an error or panics; a panic is re-raised after the rollback.
+//
+// Calling Transaction on the Container handed to fn starts a savepoint, so a
+// failing inner unit of work only undoes its own changes.
+//
+// Cached repositories in the transaction don't cache reads, and their caches
+// are invalidated again after the commit, since another request may have
+// cached a row between the write and the commit.
+func (c *Container) Transaction(ctx context.Context, fn func(tx *Container) error) error {
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(newContainer(&database.Client{DB: tx}, c.options, true))
+	})
+	if err == nil {
+		for _, cached := range c.caches {
+			cached.Invalidate(ctx)
+		}
+	}
+	return err
+}
@@ -6,14 +6,41 @@ type ()
 
 type (
 	CreatePostRequest struct {
-		Title  string `json:"title" binding:"required,min=1,max=200"`
-		Body   string `json:"body" binding:"required"`
-		UserID string `json:"user_id" binding:"required"`
+		Title string `json:"title" binding:"required,min=1,max=200"`
+		Body  string `json:"body" binding:"required"`
 	}
 
 	CreateUserRequest struct {
 		FullName string         `json:"full_name" binding:"required,min=1,max=200"`
 		Email    string         `json:"email" binding:"required,email"`
+		Password string         `json:"password" binding:"omitempty,min=8,max=72"`
 		Address  models.Address `json:"address" binding:"required"`
 	}
+
+	LoginRequest struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	EmailRequest struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	VerifyEmailRequest struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	TwoFactorLoginRequest struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+
+	TwoFactorCodeRequest struct {
+		Code string `json:"code" binding:"required,len=6,numeric"`
+	}
+
+	ResetPasswordRequest struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8,max=72"`
+	}
 )
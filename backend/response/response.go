@@ -1,7 +1,52 @@
 package response
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+// The types below hold what a response shows, so this package needn't
+// depend on the services; controllers copy service results into them.
+type (
+	Login struct {
+		AccessToken       string
+		TwoFactorRequired bool
+		ChallengeToken    string
+		ExpiresAt         time.Time
+	}
+
+	TwoFactorEnrollment struct {
+		Secret          string
+		ProvisioningURI string
+	}
+
+	LockStatus struct {
+		UserID         string
+		Locked         bool
+		LockedUntil    *time.Time
+		FailedAttempts int
+	}
+
+	StatsOverview struct {
+		From        time.Time
+		To          time.Time
+		Interval    repository.TimeBucket
+		TotalUsers  int64
+		TotalPosts  int64
+		NewUsers    int64
+		NewPosts    int64
+		Signups     []repository.GroupCount
+		PostsPerDay []repository.GroupCount
+		TopPosters  []TopPoster
+	}
+
+	TopPoster struct {
+		User  *models.User
+		Posts int64
+	}
 )
 
 func SingleUserResponse(account *models.User) map[string]interface{} {
@@ -9,11 +54,38 @@ func SingleUserResponse(account *models.User) map[string]interface{} {
 		"id":       account.ID,
 		"email":    account.Email,
 		"fullName": account.Name,
+		"verified": account.IsEmailVerified(),
 		"address":  SingleAddressResponse(account.Address),
 	}
 }
 
 func SingleAddressResponse(address *models.Address) map[string]interface{} {
+	if address == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":      address.ID,
+		"street":  address.Street,
+		"city":    address.City,
+		"state":   address.State,
+		"zipCode": address.Zipcode,
+	}
+}
+
+func UserSummaryResponse(user *models.UserSummary) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       user.ID,
+		"email":    user.Email,
+		"fullName": user.Name,
+		"verified": user.EmailVerified,
+		"address":  AddressSummaryResponse(user.Address),
+	}
+}
+
+func AddressSummaryResponse(address *models.AddressSummary) map[string]interface{} {
+	if address == nil {
+		return nil
+	}
 	return map[string]interface{}{
 		"id":      address.ID,
 		"street":  address.Street,
@@ -23,10 +95,10 @@ func SingleAddressResponse(address *models.Address) map[string]interface{} {
 	}
 }
 
-func MultipleUserResponse(users []*models.User) []map[string]interface{} {
+func MultipleUserResponse(users []*models.UserSummary) []map[string]interface{} {
 	m := make([]map[string]interface{}, 0, len(users))
 	for _, a := range users {
-		m = append(m, SingleUserResponse(a))
+		m = append(m, UserSummaryResponse(a))
 	}
 	return m
 }
@@ -46,3 +118,136 @@ func MultiplePostResponse(posts []*models.Post) []map[string]interface{} {
 	}
 	return m
 }
+
+// FeedPostResponse lists posts with their authors, leaving the author out of
+// posts whose author is missing from authors. The feed shows titles only, so
+// bodies are left out.
+func FeedPostResponse(posts []*models.Post, authors map[string]*models.User) []map[string]interface{} {
+	m := make([]map[string]interface{}, 0, len(posts))
+	for _, post := range posts {
+		item := map[string]interface{}{
+			"id":    post.ID,
+			"title": post.Title,
+		}
+		if author, ok := authors[post.UserID]; ok {
+			item["author"] = map[string]interface{}{
+				"id":       author.ID,
+				"fullName": author.Name,
+			}
+		}
+		m = append(m, item)
+	}
+	return m
+}
+
+func LoginResponse(result Login) map[string]interface{} {
+	if result.TwoFactorRequired {
+		return map[string]interface{}{
+			"twoFactorRequired": true,
+			"challengeToken":    result.ChallengeToken,
+			"expiresAt":         result.ExpiresAt,
+		}
+	}
+
+	return map[string]interface{}{
+		"accessToken": result.AccessToken,
+		"tokenType":   "Bearer",
+		"expiresAt":   result.ExpiresAt,
+	}
+}
+
+func TwoFactorEnrollmentResponse(enrollment TwoFactorEnrollment) map[string]interface{} {
+	return map[string]interface{}{
+		"secret":          enrollment.Secret,
+		"provisioningUri": enrollment.ProvisioningURI,
+	}
+}
+
+func SingleLoginHistoryResponse(entry *models.LoginHistory) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            entry.ID,
+		"createdAt":     entry.CreatedAt,
+		"ipAddress":     entry.IPAddress,
+		"userAgent":     entry.UserAgent,
+		"method":        entry.Method,
+		"success":       entry.Success,
+		"failureReason": entry.FailureReason,
+	}
+}
+
+func MultipleLoginHistoryResponse(history []*models.LoginHistory) []map[string]interface{} {
+	m := make([]map[string]interface{}, 0, len(history))
+	for _, a := range history {
+		m = append(m, SingleLoginHistoryResponse(a))
+	}
+	return m
+}
+
+func SingleAuditEntryResponse(entry *models.AuditEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            entry.ID,
+		"createdAt":     entry.CreatedAt,
+		"entity":        entry.Entity,
+		"recordId":      entry.RecordID,
+		"action":        entry.Action,
+		"changes":       json.RawMessage(entry.Changes),
+		"recordVersion": entry.RecordVersion,
+		"actorId":       entry.ActorID,
+		"requestId":     entry.RequestID,
+	}
+}
+
+func MultipleAuditEntryResponse(entries []*models.AuditEntry) []map[string]interface{} {
+	m := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		m = append(m, SingleAuditEntryResponse(entry))
+	}
+	return m
+}
+
+func LockStatusResponse(status LockStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"userId":         status.UserID,
+		"locked":         status.Locked,
+		"lockedUntil":    status.LockedUntil,
+		"failedAttempts": status.FailedAttempts,
+	}
+}
+
+func StatsOverviewResponse(overview StatsOverview) map[string]interface{} {
+	topPosters := make([]map[string]interface{}, 0, len(overview.TopPosters))
+	for _, poster := range overview.TopPosters {
+		topPosters = append(topPosters, map[string]interface{}{
+			"userId":   poster.User.ID,
+			"fullName": poster.User.Name,
+			"email":    poster.User.Email,
+			"posts":    poster.Posts,
+		})
+	}
+
+	return map[string]interface{}{
+		"from":     overview.From,
+		"to":       overview.To,
+		"interval": overview.Interval,
+		"totals": map[string]interface{}{
+			"users":    overview.TotalUsers,
+			"posts":    overview.TotalPosts,
+			"newUsers": overview.NewUsers,
+			"newPosts": overview.NewPosts,
+		},
+		"signups":     periodCounts(overview.Signups),
+		"postsPerDay": periodCounts(overview.PostsPerDay),
+		"topPosters":  topPosters,
+	}
+}
+
+func periodCounts(groups []repository.GroupCount) []map[string]interface{} {
+	m := make([]map[string]interface{}, 0, len(groups))
+	for _, group := range groups {
+		m = append(m, map[string]interface{}{
+			"date":  group.Key,
+			"count": group.Count,
+		})
+	}
+	return m
+}
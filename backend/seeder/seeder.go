@@ -0,0 +1,160 @@
+// Package seeder fills a database with fake but realistic users, addresses
+// and posts for development, frontend work and demos.
+package seeder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+// batchSize is how many users, with their posts, share one transaction
+const batchSize = 100
+
+var (
+	ErrInvalidPostRange = errors.New("posts per user must be a number or a range such as 0-20")
+
+	// epoch anchors the generated timestamps, so a seed produces the same rows
+	// whatever day it runs
+	epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type (
+	Config struct {
+		Users           int
+		MinPostsPerUser int
+		MaxPostsPerUser int
+
+		// Seed makes runs reproducible; 0 picks a random seed
+		Seed int64
+
+		// PasswordHash, when set, is given to every user so they can log in
+		PasswordHash string
+	}
+
+	Result struct {
+		Users int
+		Posts int
+	}
+)
+
+// ParsePostRange reads "5" or "0-20" into an inclusive range
+func ParsePostRange(value string) (int, int, error) {
+	lower, upper, isRange := strings.Cut(value, "-")
+	if !isRange {
+		upper = lower
+	}
+
+	minimum, err := strconv.Atoi(strings.TrimSpace(lower))
+	if err != nil {
+		return 0, 0, ErrInvalidPostRange
+	}
+	maximum, err := strconv.Atoi(strings.TrimSpace(upper))
+	if err != nil {
+		return 0, 0, ErrInvalidPostRange
+	}
+	if minimum < 0 || maximum < minimum {
+		return 0, 0, ErrInvalidPostRange
+	}
+	return minimum, maximum, nil
+}
+
+// Seed creates config.Users users, each with an address and a random number of
+// posts, through the repositories of uow. Every value, including ids and
+// timestamps, comes from the seeded generator, so the same config always
+// produces the same data; seed an empty database or the emails will clash.
+func Seed(ctx context.Context, config Config, uow repository.UnitOfWork) (*Result, error) {
+	if config.MaxPostsPerUser < config.MinPostsPerUser || config.MinPostsPerUser < 0 {
+		return nil, ErrInvalidPostRange
+	}
+
+	faker := gofakeit.New(config.Seed)
+	result := &Result{}
+
+	for start := 0; start < config.Users; start += batchSize {
+		end := min(start+batchSize, config.Users)
+
+		// Generated before inserting so the faker is drawn in the same order
+		// whatever the batch size
+		users := make([]models.User, 0, end-start)
+		var posts []models.Post
+		for i := start; i < end; i++ {
+			user := fakeUser(faker, i, config.PasswordHash)
+			users = append(users, user)
+			posts = append(posts, fakePosts(faker, user, config.MinPostsPerUser, config.MaxPostsPerUser)...)
+		}
+
+		err := uow.Transaction(ctx, func(tx *repository.Container) error {
+			if _, err := tx.UserRepo.CreateMany(ctx, users, 0); err != nil {
+				return fmt.Errorf("failed to create users %d-%d: %w", start+1, end, err)
+			}
+			if _, err := tx.PostRepo.CreateMany(ctx, posts, 0); err != nil {
+				return fmt.Errorf("failed to create posts for users %d-%d: %w", start+1, end, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+
+		// Counted after the commit so a rolled back batch is not reported
+		result.Users += end - start
+		result.Posts += len(posts)
+	}
+
+	return result, nil
+}
+
+func fakeUser(faker *gofakeit.Faker, index int, passwordHash string) models.User {
+	firstName, lastName := faker.FirstName(), faker.LastName()
+	createdAt := faker.DateRange(epoch, epoch.AddDate(1, 0, 0))
+	address := faker.Address()
+
+	userID := faker.UUID()
+	return models.User{
+		Shared:   models.Shared{ID: userID, CreatedAt: &createdAt},
+		Name:     firstName + " " + lastName,
+		Username: strings.ToLower(fmt.Sprintf("%s.%s%d", firstName, lastName, index+1)),
+		// The index keeps emails unique however often names repeat
+		Email:           strings.ToLower(fmt.Sprintf("%s.%s.%d@example.com", firstName, lastName, index+1)),
+		PasswordHash:    passwordHash,
+		EmailVerifiedAt: &createdAt,
+		Address: &models.Address{
+			Shared:  models.Shared{ID: faker.UUID(), CreatedAt: &createdAt},
+			UserID:  userID,
+			Street:  address.Street,
+			City:    address.City,
+			State:   address.State,
+			Zipcode: address.Zip,
+		},
+	}
+}
+
+func fakePosts(faker *gofakeit.Faker, author models.User, minPosts, maxPosts int) []models.Post {
+	count := faker.IntRange(minPosts, maxPosts)
+	posts := make([]models.Post, 0, count)
+
+	for i := 0; i < count; i++ {
+		createdAt := faker.DateRange(*author.CreatedAt, author.CreatedAt.AddDate(0, 6, 0))
+		title := strings.TrimSuffix(faker.Sentence(faker.IntRange(3, 9)), ".")
+		if len(title) > 200 {
+			title = title[:200]
+		}
+
+		posts = append(posts, models.Post{
+			Shared: models.Shared{ID: faker.UUID(), CreatedAt: &createdAt},
+			UserID: author.ID,
+			Title:  title,
+			Body:   faker.Paragraph(faker.IntRange(1, 4), faker.IntRange(3, 6), faker.IntRange(8, 16), "\n\n"),
+		})
+	}
+	return posts
+}
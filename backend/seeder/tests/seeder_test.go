@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/seeder"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+)
+
+type SeederTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestSeeder(t *testing.T) {
+	suite.Run(t, new(SeederTestSuite))
+}
+
+func (suite *SeederTestSuite) newContainer(name string) *repository.Container {
+	dbConn := testutils.NewTestDatabase(suite.T(), name, models.User{}, models.Address{}, models.Post{})
+	return repository.NewRepositoryContainer(new(loggermocks.Logger), dbConn)
+}
+
+// snapshot lists the seeded rows in a stable order for comparison
+func (suite *SeederTestSuite) snapshot(repos *repository.Container) ([]*models.User, []*models.Post) {
+	ctx := context.Background()
+
+	users, err := repos.UserRepo.FindMany(ctx, repository.NewQueryFilter().OrderBy("id", repository.Asc), "Address")
+	suite.Require().NoError(err)
+	posts, err := repos.PostRepo.FindMany(ctx, repository.NewQueryFilter().OrderBy("id", repository.Asc))
+	suite.Require().NoError(err)
+	return users, posts
+}
+
+func (suite *SeederTestSuite) TestSeedIsDeterministic() {
+	ctx := context.Background()
+	config := seeder.Config{Users: 120, MinPostsPerUser: 0, MaxPostsPerUser: 5, Seed: 42}
+
+	first, second := suite.newContainer("first"), suite.newContainer("second")
+
+	result, err := seeder.Seed(ctx, config, first)
+	suite.Require().NoError(err)
+	suite.Equal(120, result.Users)
+
+	_, err = seeder.Seed(ctx, config, second)
+	suite.Require().NoError(err)
+
+	firstUsers, firstPosts := suite.snapshot(first)
+	secondUsers, secondPosts := suite.snapshot(second)
+	suite.Require().Len(firstUsers, 120)
+	suite.Len(firstPosts, result.Posts)
+
+	for i := range firstUsers {
+		suite.Equal(firstUsers[i].ID, secondUsers[i].ID)
+		suite.Equal(firstUsers[i].Email, secondUsers[i].Email)
+		suite.Equal(firstUsers[i].Address.Street, secondUsers[i].Address.Street)
+		suite.True(firstUsers[i].IsEmailVerified())
+	}
+	suite.Require().Len(secondPosts, len(firstPosts))
+	for i := range firstPosts {
+		suite.Equal(firstPosts[i].Title, secondPosts[i].Title)
+		suite.Equal(firstPosts[i].Body, secondPosts[i].Body)
+	}
+
+	other := suite.newContainer("other")
+	_, err = seeder.Seed(ctx, seeder.Config{Users: 120, MaxPostsPerUser: 5, Seed: 7}, other)
+	suite.Require().NoError(err)
+	otherUsers, _ := suite.snapshot(other)
+	suite.NotEqual(firstUsers[0].ID, otherUsers[0].ID)
+}
+
+func (suite *SeederTestSuite) TestPostsPerUserStayInRange() {
+	ctx := context.Background()
+	repos := suite.newContainer("range")
+
+	result, err := seeder.Seed(ctx, seeder.Config{Users: 30, MinPostsPerUser: 2, MaxPostsPerUser: 4, Seed: 1}, repos)
+	suite.Require().NoError(err)
+
+	users, posts := suite.snapshot(repos)
+	suite.Len(posts, result.Posts)
+
+	perUser := map[string]int{}
+	for _, post := range posts {
+		perUser[post.UserID]++
+	}
+	for _, user := range users {
+		suite.GreaterOrEqual(perUser[user.ID], 2)
+		suite.LessOrEqual(perUser[user.ID], 4)
+	}
+}
+
+func (suite *SeederTestSuite) TestParsePostRange() {
+	testCases := []struct {
+		value     string
+		min, max  int
+		expectErr bool
+	}{
+		{value: "0-20", min: 0, max: 20},
+		{value: "5", min: 5, max: 5},
+		{value: " 3 - 7 ", min: 3, max: 7},
+		{value: "20-0", expectErr: true},
+		{value: "-3", expectErr: true},
+		{value: "many", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.value, func() {
+			minimum, maximum, err := seeder.ParsePostRange(tc.value)
+			if tc.expectErr {
+				suite.ErrorIs(err, seeder.ErrInvalidPostRange)
+				return
+			}
+			suite.Require().NoError(err)
+			suite.Equal(tc.min, minimum)
+			suite.Equal(tc.max, maximum)
+		})
+	}
+}
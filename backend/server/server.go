@@ -3,10 +3,12 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	constants "github.com/tejiriaustin/lema/constants"
 	"github.com/tejiriaustin/lema/controllers"
 	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/logger"
 	"github.com/tejiriaustin/lema/middleware"
 	"github.com/tejiriaustin/lema/repository"
 	"github.com/tejiriaustin/lema/service"
@@ -25,19 +28,39 @@ func Start(
 	service *service.Container,
 	repo *repository.Container,
 	conf *env.Environment,
+	rateLimiter *middleware.RateLimiter,
+	corsConfig middleware.CORSConfig,
+	lemaLogger logger.Logger,
 ) error {
 	router := gin.New()
+	// Lets database routing see values stored on the request's context
+	router.ContextWithFallback = true
 
-	rateLimiter := middleware.NewRateLimiter(5, 10, time.Hour)
+	// Client IPs key rate limits and lockouts, so they are only taken from
+	// X-Forwarded-For when a trusted proxy sent it
+	var proxies []string
+	for _, proxy := range strings.Split(conf.GetAsString(constants.TrustedProxies), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("%s: %v", constants.TrustedProxies, err)
+	}
 
 	router.Use(
-		rateLimiter.RateLimit(),
-		middleware.CORSMiddleware(),
+		middleware.RequestID(),
+		// Ahead of rate limiting, so a 429 can be read by the page and
+		// preflights don't use up the limit
+		middleware.CORSMiddleware(corsConfig),
+		rateLimiter.RateLimit(rateLimiter.Policies.Default),
 		middleware.DefaultStructuredLogs(),
 		middleware.ReadPaginationOptions(),
+		middleware.ReadYourWrites(),
+		middleware.Loaders(repo),
 	)
 
-	controllers.BindRoutes(ctx, router, service, repo, conf)
+	controllers.BindRoutes(ctx, router, service, repo, conf, rateLimiter, lemaLogger)
 
 	srv := &http.Server{
 		Addr:    conf.GetAsString(constants.Port),
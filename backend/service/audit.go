@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+type (
+	AuditService struct {
+		_          struct{}
+		lemaLogger logger.Logger
+	}
+
+	// GetAuditLogInput selects the entries of one table, optionally only
+	// those of one record
+	GetAuditLogInput struct {
+		Pager
+		Entity   string
+		RecordID string
+	}
+)
+
+var _ AuditServiceInterface = (*AuditService)(nil)
+
+func NewAuditService(lemaLogger logger.Logger) AuditServiceInterface {
+	return &AuditService{
+		lemaLogger: lemaLogger,
+	}
+}
+
+// GetAuditLog lists the entries matching input, newest first
+func (s *AuditService) GetAuditLog(ctx context.Context,
+	input GetAuditLogInput,
+	auditRepo repository.RepoInterface[models.AuditEntry],
+) ([]*models.AuditEntry, *repository.Paginator, error) {
+	if input.Entity == "" {
+		return nil, nil, ErrAuditEntityRequired
+	}
+
+	filter := repository.NewQueryFilter().Where(repository.Eq("entity", input.Entity))
+	if input.RecordID != "" {
+		filter.Where(repository.Eq("record_id", input.RecordID))
+	}
+	filter.OrderBy("created_at", repository.Desc)
+
+	entries, paginate, err := auditRepo.FindManyPaginated(ctx, filter, input.Page, input.PerPage)
+	if err != nil {
+		s.lemaLogger.Error("failed to get audit log",
+			logger.WithField("err", err),
+			logger.WithField("entity", input.Entity),
+			logger.WithField("record_id", input.RecordID))
+		return nil, nil, err
+	}
+	return entries, paginate, nil
+}
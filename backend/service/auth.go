@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/secrets"
+)
+
+const (
+	AccessTokenTTL        = 24 * time.Hour
+	EmailVerificationTTL  = 24 * time.Hour
+	PasswordResetTTL      = time.Hour
+	TwoFactorChallengeTTL = 5 * time.Minute
+)
+
+type (
+	AuthService struct {
+		_          struct{}
+		lemaLogger logger.Logger
+		conf       *env.Environment
+		mailer     mailer.Mailer
+		secretBox  *secrets.Box
+		now        func() time.Time
+	}
+
+	LoginInput struct {
+		Email     string
+		Password  string
+		IPAddress string
+		UserAgent string
+	}
+
+	// LoginResult carries either an access token or, for accounts with
+	// two-factor authentication, a challenge token to complete with a code.
+	LoginResult struct {
+		AccessToken       string
+		TwoFactorRequired bool
+		ChallengeToken    string
+		ExpiresAt         time.Time
+	}
+
+	ResetPasswordInput struct {
+		Token    string
+		Password string
+	}
+)
+
+var _ AuthServiceInterface = (*AuthService)(nil)
+
+func NewAuthService(lemaLogger logger.Logger, conf *env.Environment, mailClient mailer.Mailer, secretBox *secrets.Box) AuthServiceInterface {
+	return &AuthService{
+		lemaLogger: lemaLogger,
+		conf:       conf,
+		mailer:     mailClient,
+		secretBox:  secretBox,
+		now:        time.Now,
+	}
+}
+
+func (s *AuthService) Login(ctx context.Context,
+	input LoginInput,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) (*LoginResult, error) {
+	if s.tooManyFailuresFromIP(ctx, input.IPAddress, historyRepo) {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, input, nil, models.LoginFailureTooManyAttempts, historyRepo)
+		return nil, ErrTooManyLoginAttempts
+	}
+
+	filter := repository.NewQueryFilter().Where(repository.Eq("email", input.Email))
+
+	user, err := userRepo.FindOne(ctx, filter)
+	if err != nil || user == nil || user.PasswordHash == "" {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, input, user, models.LoginFailureInvalidCredentials, historyRepo)
+		return nil, ErrInvalidCredentials
+	}
+
+	// A locked account doesn't get its password checked at all, so guessing
+	// can't continue while the lock is in place
+	if user.IsLocked(s.now()) {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, input, user, models.LoginFailureAccountLocked, historyRepo)
+		return nil, ErrAccountLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		s.registerFailedLogin(ctx, user, userRepo)
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, input, user, models.LoginFailureInvalidCredentials, historyRepo)
+		return nil, ErrInvalidCredentials
+	}
+
+	// With two-factor authentication the login isn't done until a code passes,
+	// so failures are only forgotten then
+	if !user.IsTwoFactorEnabled() {
+		if err := s.completeLogin(ctx, input, user, userRepo, historyRepo); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.StartSession(ctx, user, tokenRepo)
+}
+
+// completeLogin forgets the account's failed logins and records the successful one
+func (s *AuthService) completeLogin(ctx context.Context,
+	input LoginInput,
+	user *models.User,
+	userRepo repository.RepoInterface[models.User],
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) error {
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		if err := s.resetFailedLogins(ctx, user, userRepo); err != nil {
+			s.lemaLogger.Error("failed to reset failed logins",
+				logger.WithField("err", err),
+				logger.WithField("user_id", user.ID))
+			return err
+		}
+	}
+	recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, input, user, "", historyRepo)
+	return nil
+}
+
+// StartSession is called once a user has proven who they are. Accounts with
+// two-factor authentication get a challenge instead of an access token.
+func (s *AuthService) StartSession(ctx context.Context,
+	user *models.User,
+	tokenRepo repository.RepoInterface[models.UserToken],
+) (*LoginResult, error) {
+	if user.IsTwoFactorEnabled() {
+		challengeToken, err := s.createToken(ctx, user.ID, models.TokenPurposeTwoFactorLogin, TwoFactorChallengeTTL, tokenRepo)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+			ExpiresAt:         s.now().Add(TwoFactorChallengeTTL),
+		}, nil
+	}
+
+	return s.issueAccessToken(user)
+}
+
+func (s *AuthService) SendEmailVerification(ctx context.Context,
+	user *models.User,
+	tokenRepo repository.RepoInterface[models.UserToken],
+) error {
+	rawToken, err := s.createToken(ctx, user.ID, models.TokenPurposeEmailVerification, EmailVerificationTTL, tokenRepo)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThis link expires in %s.\n",
+			user.Name, s.frontendLink("/verify-email", rawToken), EmailVerificationTTL),
+	}
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.lemaLogger.Error("failed to send verification email",
+			logger.WithField("err", err),
+			logger.WithField("user_id", user.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) RequestEmailVerification(ctx context.Context,
+	email string,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+) error {
+	filter := repository.NewQueryFilter().Where(repository.Eq("email", email))
+
+	user, err := userRepo.FindOne(ctx, filter)
+	if err != nil || user == nil || user.IsEmailVerified() {
+		// Don't reveal whether the address is registered
+		return nil
+	}
+
+	return s.SendEmailVerification(ctx, user, tokenRepo)
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context,
+	token string,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+) error {
+	user, err := s.redeemToken(ctx, token, models.TokenPurposeEmailVerification, userRepo, tokenRepo)
+	if err != nil {
+		return err
+	}
+
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	now := s.now().UTC()
+	user.EmailVerifiedAt = &now
+
+	if _, err := userRepo.Update(ctx, *user); err != nil {
+		s.lemaLogger.Error("failed to mark email as verified",
+			logger.WithField("err", err),
+			logger.WithField("user_id", user.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) RequestPasswordReset(ctx context.Context,
+	email string,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+) error {
+	filter := repository.NewQueryFilter().Where(repository.Eq("email", email))
+
+	user, err := userRepo.FindOne(ctx, filter)
+	if err != nil || user == nil {
+		// Don't reveal whether the address is registered
+		return nil
+	}
+
+	rawToken, err := s.createToken(ctx, user.ID, models.TokenPurposePasswordReset, PasswordResetTTL, tokenRepo)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s\n\nThis link expires in %s. If you didn't ask for this, you can ignore this email.\n",
+			user.Name, s.frontendLink("/reset-password", rawToken), PasswordResetTTL),
+	}
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.lemaLogger.Error("failed to send password reset email",
+			logger.WithField("err", err),
+			logger.WithField("user_id", user.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) ResetPassword(ctx context.Context,
+	input ResetPasswordInput,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+) error {
+	user, err := s.redeemToken(ctx, input.Token, models.TokenPurposePasswordReset, userRepo, tokenRepo)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := HashPassword(input.Password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = passwordHash
+
+	// Receiving the reset link proves ownership of the address
+	if !user.IsEmailVerified() {
+		now := s.now().UTC()
+		user.EmailVerifiedAt = &now
+	}
+
+	if _, err := userRepo.Update(ctx, *user); err != nil {
+		s.lemaLogger.Error("failed to reset password",
+			logger.WithField("err", err),
+			logger.WithField("user_id", user.ID))
+		return err
+	}
+	return nil
+}
+
+// DeleteCredentials removes everything a user could sign in with: outstanding
+// tokens, recovery codes and linked identity provider accounts.
+func (s *AuthService) DeleteCredentials(ctx context.Context,
+	userID string,
+	tokenRepo repository.RepoInterface[models.UserToken],
+	recoveryRepo repository.RepoInterface[models.RecoveryCode],
+	identityRepo repository.RepoInterface[models.UserIdentity],
+) error {
+	filter := repository.NewQueryFilter().Where(repository.Eq("user_id", userID))
+
+	if err := tokenRepo.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if err := recoveryRepo.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if err := identityRepo.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	return nil
+}
+
+// HashPassword hashes a plain-text password for storage
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (s *AuthService) issueAccessToken(user *models.User) (*LoginResult, error) {
+	now := s.now()
+	expiresAt := now.Add(AccessTokenTTL)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":        user.ID,
+		"full_name": user.Name,
+		"email":     user.Email,
+		"role":      user.Role,
+		"typ":       constants.TokenTypeAccess,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+	})
+
+	signed, err := token.SignedString(s.conf.GetAsBytes(constants.JwtSecret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return &LoginResult{AccessToken: signed, ExpiresAt: expiresAt}, nil
+}
+
+// createToken stores the hash of a new random token and returns the raw value.
+// Outstanding tokens for the same purpose are discarded so only the latest link works.
+func (s *AuthService) createToken(ctx context.Context,
+	userID string,
+	purpose models.TokenPurpose,
+	ttl time.Duration,
+	tokenRepo repository.RepoInterface[models.UserToken],
+) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	rawToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	stale := repository.NewQueryFilter().Where(
+		repository.Eq("user_id", userID),
+		repository.Eq("purpose", purpose),
+		repository.IsNull("used_at"),
+	)
+	if err := tokenRepo.DeleteMany(ctx, stale); err != nil {
+		s.lemaLogger.Error("failed to discard outstanding tokens",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID),
+			logger.WithField("purpose", string(purpose)))
+		return "", err
+	}
+
+	token := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(rawToken),
+		ExpiresAt: s.now().UTC().Add(ttl),
+	}
+
+	if _, err := tokenRepo.Create(ctx, token); err != nil {
+		s.lemaLogger.Error("failed to create token",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID),
+			logger.WithField("purpose", string(purpose)))
+		return "", err
+	}
+	return rawToken, nil
+}
+
+// redeemToken marks a token as used and returns the user it was issued to
+func (s *AuthService) redeemToken(ctx context.Context,
+	rawToken string,
+	purpose models.TokenPurpose,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+) (*models.User, error) {
+	token, err := s.findToken(ctx, rawToken, purpose, tokenRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.consumeToken(ctx, token, tokenRepo); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", token.UserID)))
+	if err != nil || user == nil {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+// findToken looks up a token that can still be redeemed without consuming it
+func (s *AuthService) findToken(ctx context.Context,
+	rawToken string,
+	purpose models.TokenPurpose,
+	tokenRepo repository.RepoInterface[models.UserToken],
+) (*models.UserToken, error) {
+	if rawToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	filter := repository.NewQueryFilter().Where(
+		repository.Eq("token_hash", hashToken(rawToken)),
+		repository.Eq("purpose", purpose),
+	)
+
+	token, err := tokenRepo.FindOne(ctx, filter)
+	if err != nil || token == nil || !token.IsUsable(s.now()) {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
+
+// consumeToken marks a token as used. Losing a race to another request
+// redeeming the same token is reported as an invalid token.
+func (s *AuthService) consumeToken(ctx context.Context,
+	token *models.UserToken,
+	tokenRepo repository.RepoInterface[models.UserToken],
+) error {
+	now := s.now().UTC()
+	token.UsedAt = &now
+
+	if _, err := tokenRepo.Update(ctx, *token); err != nil {
+		if errors.Is(err, repository.ErrConcurrentModification) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) frontendLink(path, rawToken string) string {
+	base := strings.TrimRight(s.conf.GetAsString(constants.FrontendUrl), "/")
+	return base + path + "?token=" + url.QueryEscape(rawToken)
+}
+
+func hashToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -17,12 +17,27 @@ type (
 		GetUsers(ctx context.Context,
 			input GetUsersInput,
 			userRepo repository.RepoInterface[models.User],
-		) ([]*models.User, *repository.Paginator, error)
+		) ([]*models.UserSummary, *repository.Paginator, error)
 
 		GetUserByID(ctx context.Context,
 			id string,
 			userRepo repository.RepoInterface[models.User],
 		) (*models.User, error)
+		GetUsersByIDs(ctx context.Context,
+			ids []string,
+			userRepo repository.RepoInterface[models.User],
+		) (map[string]*models.User, error)
+
+		LockUser(ctx context.Context,
+			userID string,
+			userRepo repository.RepoInterface[models.User],
+		) (*models.User, error)
+
+		DeleteUser(ctx context.Context,
+			userID string,
+			userRepo repository.RepoInterface[models.User],
+			addressRepo repository.RepoInterface[models.Address],
+		) error
 
 		GetUserCount(ctx context.Context,
 			userRepo repository.RepoInterface[models.User],
@@ -40,9 +55,138 @@ type (
 			postRepo repository.RepoInterface[models.Post],
 		) ([]*models.Post, *repository.Paginator, error)
 
+		GetUserPostsByCursor(ctx context.Context,
+			input GetUserPostCursorInput,
+			postRepo repository.RepoInterface[models.Post],
+		) ([]*models.Post, *repository.CursorPage, error)
+
+		GetFeed(ctx context.Context,
+			input GetFeedInput,
+			postRepo repository.RepoInterface[models.Post],
+		) ([]*models.Post, *repository.CursorPage, error)
+
 		DeletePost(ctx context.Context,
 			userID string,
 			postRepo repository.RepoInterface[models.Post],
 		) error
+
+		DeleteUserPosts(ctx context.Context,
+			userID string,
+			postRepo repository.RepoInterface[models.Post],
+		) error
+	}
+
+	AuthServiceInterface interface {
+		Login(ctx context.Context,
+			input LoginInput,
+			userRepo repository.RepoInterface[models.User],
+			tokenRepo repository.RepoInterface[models.UserToken],
+			historyRepo repository.RepoInterface[models.LoginHistory],
+		) (*LoginResult, error)
+
+		GetLoginHistory(ctx context.Context,
+			input GetLoginHistoryInput,
+			historyRepo repository.RepoInterface[models.LoginHistory],
+		) ([]*models.LoginHistory, *repository.Paginator, error)
+
+		GetLockStatus(ctx context.Context,
+			userID string,
+			userRepo repository.RepoInterface[models.User],
+		) (*LockStatus, error)
+
+		UnlockAccount(ctx context.Context,
+			userID string,
+			userRepo repository.RepoInterface[models.User],
+		) error
+
+		StartSession(ctx context.Context,
+			user *models.User,
+			tokenRepo repository.RepoInterface[models.UserToken],
+		) (*LoginResult, error)
+
+		CompleteTwoFactorLogin(ctx context.Context,
+			input TwoFactorLoginInput,
+			userRepo repository.RepoInterface[models.User],
+			tokenRepo repository.RepoInterface[models.UserToken],
+			recoveryRepo repository.RepoInterface[models.RecoveryCode],
+			historyRepo repository.RepoInterface[models.LoginHistory],
+		) (*LoginResult, error)
+
+		BeginTwoFactorEnrollment(ctx context.Context,
+			userID string,
+			userRepo repository.RepoInterface[models.User],
+		) (*TwoFactorEnrollment, error)
+
+		ConfirmTwoFactorEnrollment(ctx context.Context,
+			userID string,
+			code string,
+			userRepo repository.RepoInterface[models.User],
+			recoveryRepo repository.RepoInterface[models.RecoveryCode],
+		) ([]string, error)
+
+		SendEmailVerification(ctx context.Context,
+			user *models.User,
+			tokenRepo repository.RepoInterface[models.UserToken],
+		) error
+
+		RequestEmailVerification(ctx context.Context,
+			email string,
+			userRepo repository.RepoInterface[models.User],
+			tokenRepo repository.RepoInterface[models.UserToken],
+		) error
+
+		VerifyEmail(ctx context.Context,
+			token string,
+			userRepo repository.RepoInterface[models.User],
+			tokenRepo repository.RepoInterface[models.UserToken],
+		) error
+
+		RequestPasswordReset(ctx context.Context,
+			email string,
+			userRepo repository.RepoInterface[models.User],
+			tokenRepo repository.RepoInterface[models.UserToken],
+		) error
+
+		ResetPassword(ctx context.Context,
+			input ResetPasswordInput,
+			userRepo repository.RepoInterface[models.User],
+			tokenRepo repository.RepoInterface[models.UserToken],
+		) error
+
+		DeleteCredentials(ctx context.Context,
+			userID string,
+			tokenRepo repository.RepoInterface[models.UserToken],
+			recoveryRepo repository.RepoInterface[models.RecoveryCode],
+			identityRepo repository.RepoInterface[models.UserIdentity],
+		) error
+	}
+
+	SSOServiceInterface interface {
+		BeginLogin(ctx context.Context,
+			provider string,
+		) (*SSOLoginRedirect, error)
+
+		CompleteLogin(ctx context.Context,
+			input SSOCallbackInput,
+			userRepo repository.RepoInterface[models.User],
+			identityRepo repository.RepoInterface[models.UserIdentity],
+			tokenRepo repository.RepoInterface[models.UserToken],
+			historyRepo repository.RepoInterface[models.LoginHistory],
+		) (*LoginResult, error)
+	}
+
+	AuditServiceInterface interface {
+		GetAuditLog(ctx context.Context,
+			input GetAuditLogInput,
+			auditRepo repository.RepoInterface[models.AuditEntry],
+		) ([]*models.AuditEntry, *repository.Paginator, error)
+	}
+
+	StatsServiceInterface interface {
+		GetOverview(ctx context.Context,
+			input StatsOverviewInput,
+			userRepo repository.RepoInterface[models.User],
+			postRepo repository.RepoInterface[models.Post],
+		) (*StatsOverview, error)
 	}
 )
@@ -0,0 +1,37 @@
+package service
+
+import "errors"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+
+	ErrInvalidToken = errors.New("token is invalid or has expired")
+
+	ErrUserNotFound = errors.New("user not found")
+
+	ErrEmailNotVerified = errors.New("email address has not been verified")
+
+	ErrAccountLocked = errors.New("account is temporarily locked after too many failed login attempts")
+
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+
+	ErrInvalidTwoFactorCode = errors.New("two-factor code is invalid")
+
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+	ErrTwoFactorNotPending = errors.New("two-factor enrollment has not been started")
+
+	ErrTwoFactorUnavailable = errors.New("two-factor authentication is not configured")
+
+	ErrInvalidSSOState = errors.New("sso login state is invalid or has expired")
+
+	ErrSSOEmailNotVerified = errors.New("identity provider has not verified this email address")
+
+	ErrSSOAccountNotFound = errors.New("no account matches this identity")
+
+	ErrAuditEntityRequired = errors.New("entity is required")
+
+	ErrInvalidStatsRange = errors.New("to must be after from and at most a year later")
+
+	ErrInvalidStatsInterval = errors.New("interval must be day or week")
+)
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+const (
+	// MaxFailedLoginAttempts is how many wrong passwords an account tolerates before it is locked
+	MaxFailedLoginAttempts = 5
+
+	// LockoutBaseDuration doubles with every failure past MaxFailedLoginAttempts, up to MaxLockoutDuration
+	LockoutBaseDuration = time.Minute
+	MaxLockoutDuration  = 24 * time.Hour
+
+	// MaxFailedLoginsPerIP is how many failures a single address may produce within FailedLoginIPWindow
+	MaxFailedLoginsPerIP = 20
+	FailedLoginIPWindow  = 15 * time.Minute
+)
+
+type (
+	LockStatus struct {
+		UserID         string
+		Locked         bool
+		LockedUntil    *time.Time
+		FailedAttempts int
+	}
+
+	GetLoginHistoryInput struct {
+		Pager
+		UserID string
+	}
+)
+
+func (s *AuthService) GetLoginHistory(ctx context.Context,
+	input GetLoginHistoryInput,
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) ([]*models.LoginHistory, *repository.Paginator, error) {
+	filter := repository.NewQueryFilter().
+		Where(repository.Eq("user_id", input.UserID)).
+		OrderBy("created_at", repository.Desc)
+
+	history, paginate, err := historyRepo.FindManyPaginated(ctx, filter, input.Page, input.PerPage)
+	if err != nil {
+		s.lemaLogger.Error("failed to get login history",
+			logger.WithField("err", err),
+			logger.WithField("user_id", input.UserID))
+		return nil, nil, err
+	}
+	return history, paginate, nil
+}
+
+func (s *AuthService) GetLockStatus(ctx context.Context,
+	userID string,
+	userRepo repository.RepoInterface[models.User],
+) (*LockStatus, error) {
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", userID)))
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	status := &LockStatus{
+		UserID:         user.ID,
+		Locked:         user.IsLocked(s.now()),
+		FailedAttempts: user.FailedLoginAttempts,
+	}
+	if status.Locked {
+		status.LockedUntil = user.LockedUntil
+	}
+	return status, nil
+}
+
+// UnlockAccount lifts a lock and forgets previous failures, so the next
+// failure starts the backoff from the beginning.
+func (s *AuthService) UnlockAccount(ctx context.Context,
+	userID string,
+	userRepo repository.RepoInterface[models.User],
+) error {
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", userID)))
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.resetFailedLogins(ctx, user, userRepo); err != nil {
+		s.lemaLogger.Error("failed to unlock account",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return err
+	}
+	return nil
+}
+
+// tooManyFailuresFromIP reports whether an address has produced enough recent
+// failures, across any accounts, that further attempts should be refused.
+func (s *AuthService) tooManyFailuresFromIP(ctx context.Context,
+	ipAddress string,
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) bool {
+	if ipAddress == "" {
+		return false
+	}
+
+	since := s.now().UTC().Add(-FailedLoginIPWindow)
+	filter := repository.NewQueryFilter().Where(
+		repository.Eq("ip_address", ipAddress),
+		repository.Eq("success", false),
+		repository.Range("created_at", since, nil),
+	)
+
+	failures, err := historyRepo.Count(ctx, filter)
+	if err != nil {
+		s.lemaLogger.Error("failed to count failed logins",
+			logger.WithField("err", err),
+			logger.WithField("ip_address", ipAddress))
+		return false
+	}
+	return failures >= MaxFailedLoginsPerIP
+}
+
+// registerFailedLogin counts a wrong password against the account and locks it
+// once MaxFailedLoginAttempts is reached. The count is incremented in SQL, so
+// guesses sent in parallel each count.
+func (s *AuthService) registerFailedLogin(ctx context.Context,
+	user *models.User,
+	userRepo repository.RepoInterface[models.User],
+) {
+	byID := func() *repository.Query {
+		return repository.NewQueryFilter().Where(repository.Eq("id", user.ID))
+	}
+	fail := func(msg string, err error) {
+		s.lemaLogger.Error(msg,
+			logger.WithField("err", err),
+			logger.WithField("user_id", user.ID))
+	}
+
+	_, err := userRepo.UpdateMany(ctx, byID(), map[string]interface{}{
+		"failed_login_attempts": repository.Increment(1),
+	})
+	if err != nil {
+		fail("failed to record failed login", err)
+		return
+	}
+
+	current, err := userRepo.FindOne(ctx, byID())
+	if err != nil || current == nil {
+		fail("failed to read failed logins", err)
+		return
+	}
+	user.FailedLoginAttempts = current.FailedLoginAttempts
+	if user.FailedLoginAttempts < MaxFailedLoginAttempts {
+		return
+	}
+
+	// Only while the count is still ours: a failure that got in since sets a
+	// longer lock of its own, which this one mustn't shorten
+	lockedUntil := s.now().UTC().Add(lockoutDuration(user.FailedLoginAttempts))
+	user.LockedUntil = &lockedUntil
+	_, err = userRepo.UpdateMany(ctx,
+		byID().Where(repository.Eq("failed_login_attempts", user.FailedLoginAttempts)),
+		map[string]interface{}{"locked_until": lockedUntil})
+	if err != nil {
+		fail("failed to lock account", err)
+	}
+}
+
+func (s *AuthService) resetFailedLogins(ctx context.Context,
+	user *models.User,
+	userRepo repository.RepoInterface[models.User],
+) error {
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+
+	_, err := userRepo.UpdateFields(ctx, *user, "failed_login_attempts", "locked_until")
+	return err
+}
+
+// recordLoginAttempt adds a login made with method to the login history. An
+// empty failureReason records a successful login.
+func recordLoginAttempt(ctx context.Context,
+	lemaLogger logger.Logger,
+	method string,
+	input LoginInput,
+	user *models.User,
+	failureReason string,
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) {
+	entry := models.LoginHistory{
+		Email:         input.Email,
+		IPAddress:     input.IPAddress,
+		UserAgent:     truncate(input.UserAgent, 255),
+		Method:        method,
+		Success:       failureReason == "",
+		FailureReason: failureReason,
+	}
+	if user != nil {
+		entry.UserID = user.ID
+	}
+
+	if _, err := historyRepo.Create(ctx, entry); err != nil {
+		lemaLogger.Error("failed to record login attempt",
+			logger.WithField("err", err),
+			logger.WithField("email", input.Email))
+	}
+}
+
+func lockoutDuration(failedAttempts int) time.Duration {
+	exponent := failedAttempts - MaxFailedLoginAttempts
+	if exponent > 10 {
+		return MaxLockoutDuration
+	}
+
+	duration := LockoutBaseDuration << exponent
+	if duration > MaxLockoutDuration {
+		return MaxLockoutDuration
+	}
+	return duration
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/tejiriaustin/lema/logger"
 	"strconv"
 
@@ -24,6 +25,16 @@ type (
 		Pager
 		UserID string
 	}
+	GetUserPostCursorInput struct {
+		UserID string
+		Cursor string
+		Limit  int64
+	}
+	// GetFeedInput pages through the posts of every user, newest first
+	GetFeedInput struct {
+		Cursor string
+		Limit  int64
+	}
 )
 
 var _ PostServiceInterface = (*PostService)(nil)
@@ -62,7 +73,7 @@ func (s *PostService) GetUserPosts(ctx context.Context,
 	input GetUserPostInput,
 	postRepo repository.RepoInterface[models.Post],
 ) ([]*models.Post, *repository.Paginator, error) {
-	filter := repository.NewQueryFilter().Where("user_id = ?", input.UserID)
+	filter := repository.NewQueryFilter().Where(repository.Eq("user_id", input.UserID))
 
 	posts, paginate, err := postRepo.FindManyPaginated(ctx, filter, input.Page, input.PerPage)
 	if err != nil {
@@ -74,11 +85,46 @@ func (s *PostService) GetUserPosts(ctx context.Context,
 	return posts, paginate, nil
 }
 
+func (s *PostService) GetUserPostsByCursor(ctx context.Context,
+	input GetUserPostCursorInput,
+	postRepo repository.RepoInterface[models.Post],
+) ([]*models.Post, *repository.CursorPage, error) {
+	filter := repository.NewQueryFilter().Where(repository.Eq("user_id", input.UserID))
+
+	posts, page, err := postRepo.FindManyCursor(ctx, filter, input.Cursor, input.Limit)
+	if err != nil {
+		if !errors.Is(err, repository.ErrInvalidCursor) {
+			s.lemaLogger.Error("failed to get user's posts",
+				logger.WithField("err", err),
+				logger.WithField("user_id", input.UserID))
+		}
+		return nil, nil, err
+	}
+	return posts, page, nil
+}
+
+func (s *PostService) GetFeed(ctx context.Context,
+	input GetFeedInput,
+	postRepo repository.RepoInterface[models.Post],
+) ([]*models.Post, *repository.CursorPage, error) {
+	// The feed lists titles, so bodies aren't loaded
+	filter := repository.NewQueryFilter().Omit("body")
+
+	posts, page, err := postRepo.FindManyCursor(ctx, filter, input.Cursor, input.Limit)
+	if err != nil {
+		if !errors.Is(err, repository.ErrInvalidCursor) {
+			s.lemaLogger.Error("failed to get feed", logger.WithField("err", err))
+		}
+		return nil, nil, err
+	}
+	return posts, page, nil
+}
+
 func (s *PostService) DeletePost(ctx context.Context,
 	postID string,
 	postRepo repository.RepoInterface[models.Post],
 ) error {
-	filter := repository.NewQueryFilter().Where("id = ?", postID)
+	filter := repository.NewQueryFilter().Where(repository.Eq("id", postID))
 
 	err := postRepo.DeleteMany(ctx, filter)
 	if err != nil {
@@ -89,3 +135,18 @@ func (s *PostService) DeletePost(ctx context.Context,
 	}
 	return nil
 }
+
+func (s *PostService) DeleteUserPosts(ctx context.Context,
+	userID string,
+	postRepo repository.RepoInterface[models.Post],
+) error {
+	filter := repository.NewQueryFilter().Where(repository.Eq("user_id", userID))
+
+	if err := postRepo.DeleteMany(ctx, filter); err != nil {
+		s.lemaLogger.Error("failed to delete user's posts",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return err
+	}
+	return nil
+}
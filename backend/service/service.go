@@ -7,12 +7,20 @@ import (
 	constants "github.com/tejiriaustin/lema/constants"
 	"github.com/tejiriaustin/lema/env"
 	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/secrets"
+	"github.com/tejiriaustin/lema/sso"
 )
 
 type (
 	Container struct {
-		UserService UserServiceInterface
-		PostService PostServiceInterface
+		UserService  UserServiceInterface
+		PostService  PostServiceInterface
+		AuthService  AuthServiceInterface
+		SSOService   SSOServiceInterface
+		AuditService AuditServiceInterface
+		StatsService StatsServiceInterface
 	}
 
 	Pager struct {
@@ -21,11 +29,24 @@ type (
 	}
 )
 
-func NewService(lemaLogger logger.Logger, conf *env.Environment) *Container {
+func NewService(
+	lemaLogger logger.Logger,
+	conf *env.Environment,
+	mailClient mailer.Mailer,
+	secretBox *secrets.Box,
+	ssoProviders *sso.Registry,
+) *Container {
 	log.Println("Creating Service Container...")
+
+	authService := NewAuthService(lemaLogger, conf, mailClient, secretBox)
+
 	return &Container{
-		UserService: NewUserService(lemaLogger),
-		PostService: NewPostService(lemaLogger),
+		UserService:  NewUserService(lemaLogger),
+		PostService:  NewPostService(lemaLogger),
+		AuthService:  authService,
+		SSOService:   NewSSOService(lemaLogger, conf, ssoProviders, authService),
+		AuditService: NewAuditService(lemaLogger),
+		StatsService: NewStatsService(lemaLogger),
 	}
 }
 
@@ -44,3 +65,8 @@ func GetPageSizeLimitFromContext(ctx context.Context) int64 {
 	}
 	return l
 }
+
+func GetAccountInfoFromContext(ctx context.Context) (models.AccountInfo, bool) {
+	account, ok := ctx.Value(string(constants.ContextKeyAccountInfo)).(models.AccountInfo)
+	return account, ok
+}
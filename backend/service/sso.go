@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/oauth2"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/sso"
+)
+
+const SSOLoginStateTTL = 10 * time.Minute
+
+type (
+	SSOService struct {
+		_           struct{}
+		lemaLogger  logger.Logger
+		conf        *env.Environment
+		providers   *sso.Registry
+		authService AuthServiceInterface
+		now         func() time.Time
+	}
+
+	// SSOLoginRedirect is where to send the browser, plus the signed login
+	// state the caller must hand back (usually via a cookie) on callback.
+	SSOLoginRedirect struct {
+		URL   string
+		State string
+	}
+
+	SSOCallbackInput struct {
+		Provider   string
+		Code       string
+		State      string
+		LoginState string
+		IPAddress  string
+		UserAgent  string
+	}
+
+	ssoLoginState struct {
+		Provider     string `json:"provider"`
+		State        string `json:"state"`
+		Nonce        string `json:"nonce"`
+		CodeVerifier string `json:"code_verifier"`
+		Type         string `json:"typ"`
+		jwt.StandardClaims
+	}
+)
+
+var _ SSOServiceInterface = (*SSOService)(nil)
+
+func NewSSOService(lemaLogger logger.Logger, conf *env.Environment, providers *sso.Registry, authService AuthServiceInterface) SSOServiceInterface {
+	return &SSOService{
+		lemaLogger:  lemaLogger,
+		conf:        conf,
+		providers:   providers,
+		authService: authService,
+		now:         time.Now,
+	}
+}
+
+func (s *SSOService) BeginLogin(ctx context.Context,
+	providerName string,
+) (*SSOLoginRedirect, error) {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier := oauth2.GenerateVerifier()
+
+	redirectURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		s.lemaLogger.Error("failed to build sso redirect",
+			logger.WithField("err", err),
+			logger.WithField("provider", providerName))
+		return nil, err
+	}
+
+	claims := ssoLoginState{
+		Provider:     providerName,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		Type:         constants.TokenTypeSSOState,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: s.now().Add(SSOLoginStateTTL).Unix(),
+		},
+	}
+
+	loginState, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.conf.GetAsBytes(constants.JwtSecret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign sso login state: %w", err)
+	}
+
+	return &SSOLoginRedirect{URL: redirectURL, State: loginState}, nil
+}
+
+func (s *SSOService) CompleteLogin(ctx context.Context,
+	input SSOCallbackInput,
+	userRepo repository.RepoInterface[models.User],
+	identityRepo repository.RepoInterface[models.UserIdentity],
+	tokenRepo repository.RepoInterface[models.UserToken],
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) (*LoginResult, error) {
+	provider, err := s.providers.Get(input.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := LoginInput{IPAddress: input.IPAddress, UserAgent: input.UserAgent}
+
+	loginState, err := s.parseLoginState(input)
+	if err != nil {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodOIDC, attempt, nil, models.LoginFailureInvalidSSOState, historyRepo)
+		return nil, err
+	}
+
+	identity, err := provider.Exchange(ctx, input.Code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		s.lemaLogger.Error("failed to complete sso login",
+			logger.WithField("err", err),
+			logger.WithField("provider", input.Provider))
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodOIDC, attempt, nil, models.LoginFailureSSOFailed, historyRepo)
+		return nil, err
+	}
+	attempt.Email = identity.Email
+
+	user, err := s.findOrLinkUser(ctx, identity, userRepo, identityRepo)
+	if err != nil {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodOIDC, attempt, nil, ssoFailureReason(err), historyRepo)
+		return nil, err
+	}
+
+	result, err := s.authService.StartSession(ctx, user, tokenRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// With two-factor authentication the login is recorded once a code passes
+	if !user.IsTwoFactorEnabled() {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodOIDC, attempt, user, "", historyRepo)
+	}
+	return result, nil
+}
+
+func ssoFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrSSOEmailNotVerified):
+		return models.LoginFailureSSOEmailNotVerified
+	case errors.Is(err, ErrSSOAccountNotFound):
+		return models.LoginFailureSSOAccountNotFound
+	default:
+		return models.LoginFailureSSOFailed
+	}
+}
+
+func (s *SSOService) parseLoginState(input SSOCallbackInput) (*ssoLoginState, error) {
+	var claims ssoLoginState
+
+	_, err := jwt.ParseWithClaims(input.LoginState, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return s.conf.GetAsBytes(constants.JwtSecret), nil
+	})
+	if err != nil {
+		return nil, ErrInvalidSSOState
+	}
+
+	// The state shares its signing key with access tokens, so its type is
+	// what keeps one from standing in for the other
+	if claims.Type != constants.TokenTypeSSOState {
+		return nil, ErrInvalidSSOState
+	}
+	if claims.Provider != input.Provider || subtle.ConstantTimeCompare([]byte(claims.State), []byte(input.State)) != 1 {
+		return nil, ErrInvalidSSOState
+	}
+	return &claims, nil
+}
+
+// findOrLinkUser returns the user already linked to the identity. Otherwise an
+// existing user is linked by email, but only when the provider has verified it.
+func (s *SSOService) findOrLinkUser(ctx context.Context,
+	identity *sso.Identity,
+	userRepo repository.RepoInterface[models.User],
+	identityRepo repository.RepoInterface[models.UserIdentity],
+) (*models.User, error) {
+	filter := repository.NewQueryFilter().Where(
+		repository.Eq("provider", identity.Provider),
+		repository.Eq("subject", identity.Subject),
+	)
+
+	linked, err := identityRepo.FindOne(ctx, filter)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if linked != nil {
+		user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", linked.UserID)))
+		if err != nil || user == nil {
+			return nil, ErrSSOAccountNotFound
+		}
+		return user, nil
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrSSOEmailNotVerified
+	}
+
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("email", identity.Email)))
+	if err != nil || user == nil {
+		return nil, ErrSSOAccountNotFound
+	}
+
+	newIdentity := models.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	if _, err := identityRepo.Create(ctx, newIdentity); err != nil {
+		s.lemaLogger.Error("failed to link sso identity",
+			logger.WithField("err", err),
+			logger.WithField("provider", identity.Provider),
+			logger.WithField("user_id", user.ID))
+		return nil, err
+	}
+
+	// The provider vouched for the address, so there's no need to send our own verification
+	if !user.IsEmailVerified() {
+		now := s.now().UTC()
+		user.EmailVerifiedAt = &now
+
+		updated, err := userRepo.Update(ctx, *user)
+		if err != nil {
+			return nil, err
+		}
+		user = updated
+	}
+
+	return user, nil
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+// MaxStatsRange is the longest period an overview covers
+const MaxStatsRange = 366 * 24 * time.Hour
+
+type (
+	StatsService struct {
+		_          struct{}
+		lemaLogger logger.Logger
+	}
+
+	// StatsOverviewInput selects the records created from From up to, but not
+	// including, To
+	StatsOverviewInput struct {
+		From time.Time
+		To   time.Time
+		// Interval buckets signups by day or week
+		Interval repository.TimeBucket
+		// TopPosters is how many of the users who posted most are listed
+		TopPosters int
+	}
+
+	StatsOverview struct {
+		From     time.Time
+		To       time.Time
+		Interval repository.TimeBucket
+
+		// TotalUsers and TotalPosts count every live record, NewUsers and
+		// NewPosts those created in the range
+		TotalUsers int64
+		TotalPosts int64
+		NewUsers   int64
+		NewPosts   int64
+
+		// Signups and PostsPerDay have an entry for every period in the range,
+		// including empty ones
+		Signups     []repository.GroupCount
+		PostsPerDay []repository.GroupCount
+		TopPosters  []TopPoster
+	}
+
+	TopPoster struct {
+		User  *models.User
+		Posts int64
+	}
+)
+
+var _ StatsServiceInterface = (*StatsService)(nil)
+
+func NewStatsService(lemaLogger logger.Logger) StatsServiceInterface {
+	return &StatsService{
+		lemaLogger: lemaLogger,
+	}
+}
+
+// GetOverview summarises signups and posting activity between input.From and
+// input.To
+func (s *StatsService) GetOverview(ctx context.Context,
+	input StatsOverviewInput,
+	userRepo repository.RepoInterface[models.User],
+	postRepo repository.RepoInterface[models.Post],
+) (*StatsOverview, error) {
+	if input.Interval == "" {
+		input.Interval = repository.Day
+	}
+	if input.Interval != repository.Day && input.Interval != repository.Week {
+		return nil, ErrInvalidStatsInterval
+	}
+	if !input.To.After(input.From) || input.To.Sub(input.From) > MaxStatsRange {
+		return nil, ErrInvalidStatsRange
+	}
+
+	overview := &StatsOverview{From: input.From, To: input.To, Interval: input.Interval}
+	inRange := func() *repository.Query {
+		return repository.NewQueryFilter().Where(repository.Range("created_at", input.From, input.To))
+	}
+
+	var err error
+	fail := func(msg string) (*StatsOverview, error) {
+		s.lemaLogger.Error(msg,
+			logger.WithField("err", err),
+			logger.WithField("from", input.From),
+			logger.WithField("to", input.To))
+		return nil, err
+	}
+
+	if overview.TotalUsers, err = userRepo.Count(ctx, nil); err != nil {
+		return fail("failed to count users")
+	}
+	if overview.TotalPosts, err = postRepo.Count(ctx, nil); err != nil {
+		return fail("failed to count posts")
+	}
+
+	signups, err := userRepo.CountBy(ctx, inRange(), repository.ByTime("created_at", input.Interval))
+	if err != nil {
+		return fail("failed to count signups")
+	}
+	overview.Signups, overview.NewUsers = fillPeriods(signups, input.Interval, input.From, input.To)
+
+	posts, err := postRepo.CountBy(ctx, inRange(), repository.ByTime("created_at", repository.Day))
+	if err != nil {
+		return fail("failed to count posts per day")
+	}
+	overview.PostsPerDay, overview.NewPosts = fillPeriods(posts, repository.Day, input.From, input.To)
+
+	if input.TopPosters > 0 {
+		if overview.TopPosters, err = s.topPosters(ctx, inRange().Limit(input.TopPosters), userRepo, postRepo); err != nil {
+			return fail("failed to get top posters")
+		}
+	}
+
+	return overview, nil
+}
+
+// topPosters lists the users with the most posts matching filter, most first
+func (s *StatsService) topPosters(ctx context.Context,
+	filter *repository.Query,
+	userRepo repository.RepoInterface[models.User],
+	postRepo repository.RepoInterface[models.Post],
+) ([]TopPoster, error) {
+	counts, err := postRepo.CountBy(ctx, filter, repository.ByColumn("user_id"))
+	if err != nil || len(counts) == 0 {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(counts))
+	for _, count := range counts {
+		userIDs = append(userIDs, count.Key)
+	}
+	users, err := userRepo.FindByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	posters := make([]TopPoster, 0, len(counts))
+	for _, count := range counts {
+		// Posts outlive a deleted author until the purge catches up
+		if user, ok := byID[count.Key]; ok {
+			posters = append(posters, TopPoster{User: user, Posts: count.Count})
+		}
+	}
+	return posters, nil
+}
+
+// fillPeriods returns a count for every period of bucket from from up to to,
+// zero where groups has none, and their total
+func fillPeriods(groups []repository.GroupCount, bucket repository.TimeBucket, from, to time.Time) ([]repository.GroupCount, int64) {
+	counts := make(map[string]int64, len(groups))
+	for _, group := range groups {
+		counts[group.Key] = group.Count
+	}
+
+	var (
+		periods []repository.GroupCount
+		total   int64
+	)
+	for start := bucket.Truncate(from); start.Before(to); start = bucket.Next(start) {
+		key := start.Format(repository.BucketKeyFormat)
+		periods = append(periods, repository.GroupCount{Key: key, Count: counts[key]})
+		total += counts[key]
+	}
+	return periods, total
+}
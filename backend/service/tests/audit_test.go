@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+)
+
+type AuditServiceTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestAuditService(t *testing.T) {
+	suite.Run(t, new(AuditServiceTestSuite))
+}
+
+func (suite *AuditServiceTestSuite) TestGetAuditLog() {
+	ctx := context.Background()
+
+	type testCase struct {
+		name        string
+		input       service.GetAuditLogInput
+		setupMock   func(*repomocks.RepoInterface[models.AuditEntry], *loggermocks.Logger)
+		expectError error
+		expectCount int
+	}
+
+	testCases := []testCase{
+		{
+			name:  "entries of one record",
+			input: service.GetAuditLogInput{Pager: service.Pager{Page: 1, PerPage: 10}, Entity: "addresses", RecordID: "address-1"},
+			setupMock: func(repo *repomocks.RepoInterface[models.AuditEntry], _ *loggermocks.Logger) {
+				repo.On("FindManyPaginated", mock.Anything, mock.MatchedBy(func(q *repository.Query) bool {
+					return q != nil
+				}), int64(1), int64(10)).Return([]*models.AuditEntry{
+					{ID: "entry-1", Entity: "addresses", RecordID: "address-1", Action: models.AuditActionUpdate},
+				}, &repository.Paginator{}, nil)
+			},
+			expectCount: 1,
+		},
+		{
+			name:        "entity is required",
+			input:       service.GetAuditLogInput{Pager: service.Pager{Page: 1, PerPage: 10}, RecordID: "address-1"},
+			setupMock:   func(*repomocks.RepoInterface[models.AuditEntry], *loggermocks.Logger) {},
+			expectError: service.ErrAuditEntityRequired,
+		},
+		{
+			name:  "repository error",
+			input: service.GetAuditLogInput{Pager: service.Pager{Page: 1, PerPage: 10}, Entity: "users"},
+			setupMock: func(repo *repomocks.RepoInterface[models.AuditEntry], mockLogger *loggermocks.Logger) {
+				err := errors.New("database error")
+				repo.On("FindManyPaginated", mock.Anything, mock.Anything, int64(1), int64(10)).Return(nil, nil, err)
+				mockLogger.On("Error", "failed to get audit log",
+					logger.Field{Key: "err", Value: err},
+					logger.Field{Key: "entity", Value: "users"},
+					logger.Field{Key: "record_id", Value: ""},
+				).Return()
+			},
+			expectError: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			mockLogger := new(loggermocks.Logger)
+			auditRepo := new(repomocks.RepoInterface[models.AuditEntry])
+			tc.setupMock(auditRepo, mockLogger)
+
+			entries, _, err := service.NewAuditService(mockLogger).GetAuditLog(ctx, tc.input, auditRepo)
+
+			if tc.expectError != nil {
+				suite.EqualError(err, tc.expectError.Error())
+			} else {
+				suite.NoError(err)
+				suite.Len(entries, tc.expectCount)
+			}
+
+			auditRepo.AssertExpectations(suite.T())
+			mockLogger.AssertExpectations(suite.T())
+		})
+	}
+}
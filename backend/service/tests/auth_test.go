@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+)
+
+var tokenInLink = regexp.MustCompile(`\?token=(\S+)`)
+
+type AuthServiceTestSuite struct {
+	testutils.BaseSuite
+	conf *env.Environment
+}
+
+func TestAuthService(t *testing.T) {
+	conf := env.NewEnvironment().
+		SetEnv(constants.JwtSecret, "test-secret").
+		SetEnv(constants.FrontendUrl, "http://localhost:5173")
+
+	suite.Run(t, &AuthServiceTestSuite{conf: &conf})
+}
+
+func (suite *AuthServiceTestSuite) newService() (service.AuthServiceInterface, *mailer.OutboxMailer, *loggermocks.Logger) {
+	outbox, err := mailer.NewOutboxMailer("", "no-reply@lema.local")
+	suite.Require().NoError(err)
+
+	mockLogger := new(loggermocks.Logger)
+	return service.NewAuthService(mockLogger, suite.conf, outbox, nil), outbox, mockLogger
+}
+
+// extractToken pulls the raw token out of the link in an email body
+func (suite *AuthServiceTestSuite) extractToken(body string) string {
+	match := tokenInLink.FindStringSubmatch(body)
+	suite.Require().Len(match, 2)
+
+	token, err := url.QueryUnescape(match[1])
+	suite.Require().NoError(err)
+	return token
+}
+
+func (suite *AuthServiceTestSuite) TestLogin() {
+	ctx := context.Background()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	suite.Require().NoError(err)
+
+	user := &models.User{
+		Shared:       models.Shared{ID: "user-1"},
+		Name:         "John Doe",
+		Email:        "john@example.com",
+		PasswordHash: string(hash),
+	}
+
+	testCases := []struct {
+		name        string
+		password    string
+		foundUser   *models.User
+		expectedErr error
+	}{
+		{name: "valid credentials", password: "correct horse", foundUser: user},
+		{name: "wrong password", password: "battery staple", foundUser: user, expectedErr: service.ErrInvalidCredentials},
+		{name: "unknown email", password: "correct horse", foundUser: nil, expectedErr: service.ErrInvalidCredentials},
+		{name: "user without password", password: "", foundUser: &models.User{Email: "john@example.com"}, expectedErr: service.ErrInvalidCredentials},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			svc, _, _ := suite.newService()
+			userRepo := new(repomocks.RepoInterface[models.User])
+
+			if tc.foundUser != nil {
+				userRepo.On("FindOne", mock.Anything, mock.Anything).Return(tc.foundUser, nil)
+			} else {
+				userRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+			}
+
+			userRepo.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil).Maybe()
+
+			tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+			historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+			historyRepo.On("Count", mock.Anything, mock.Anything).Return(int64(0), nil)
+			historyRepo.On("Create", mock.Anything, mock.MatchedBy(func(h models.LoginHistory) bool {
+				return h.Success == (tc.expectedErr == nil)
+			})).Return(&models.LoginHistory{}, nil)
+
+			input := service.LoginInput{Email: "john@example.com", Password: tc.password, IPAddress: "127.0.0.1"}
+			result, err := svc.Login(ctx, input, userRepo, tokenRepo, historyRepo)
+
+			historyRepo.AssertExpectations(suite.T())
+			if tc.expectedErr != nil {
+				suite.ErrorIs(err, tc.expectedErr)
+				suite.Nil(result)
+				return
+			}
+			suite.NoError(err)
+			suite.NotEmpty(result.AccessToken)
+			suite.True(result.ExpiresAt.After(time.Now()))
+		})
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestEmailVerificationFlow() {
+	ctx := context.Background()
+	svc, outbox, _ := suite.newService()
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	user := &models.User{
+		Shared: models.Shared{ID: "user-1", Version: 1},
+		Name:   "John Doe",
+		Email:  "john@example.com",
+	}
+
+	var stored models.UserToken
+	tokenRepo.On("DeleteMany", mock.Anything, mock.Anything).Return(nil)
+	tokenRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(models.UserToken) }).
+		Return(func(_ context.Context, t models.UserToken) *models.UserToken { return &t }, nil)
+
+	suite.Require().NoError(svc.SendEmailVerification(ctx, user, tokenRepo))
+
+	msg, ok := outbox.Last()
+	suite.Require().True(ok)
+	suite.Equal([]string{"john@example.com"}, msg.To)
+	suite.Contains(msg.Body, "http://localhost:5173/verify-email?token=")
+
+	rawToken := suite.extractToken(msg.Body)
+	suite.NotEqual(rawToken, stored.TokenHash, "only the hash of the token may be stored")
+	suite.Equal(models.TokenPurposeEmailVerification, stored.Purpose)
+
+	tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(&stored, nil)
+	tokenRepo.On("Update", mock.Anything, mock.MatchedBy(func(t models.UserToken) bool {
+		return t.UsedAt != nil
+	})).Return(&stored, nil)
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+	userRepo.On("Update", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.IsEmailVerified()
+	})).Return(user, nil)
+
+	suite.NoError(svc.VerifyEmail(ctx, rawToken, userRepo, tokenRepo))
+
+	userRepo.AssertExpectations(suite.T())
+	tokenRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestVerifyEmailRejectsUnusableTokens() {
+	ctx := context.Background()
+	usedAt := time.Now().Add(-time.Minute)
+
+	testCases := []struct {
+		name  string
+		token *models.UserToken
+	}{
+		{name: "expired token", token: &models.UserToken{ExpiresAt: time.Now().Add(-time.Hour)}},
+		{name: "already used token", token: &models.UserToken{ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}},
+		{name: "unknown token", token: nil},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			svc, _, _ := suite.newService()
+			userRepo := new(repomocks.RepoInterface[models.User])
+			tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+			if tc.token != nil {
+				tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(tc.token, nil)
+			} else {
+				tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+			}
+
+			err := svc.VerifyEmail(ctx, "some-token", userRepo, tokenRepo)
+			suite.ErrorIs(err, service.ErrInvalidToken)
+
+			tokenRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+			userRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestVerifyEmailLosesRaceForToken() {
+	ctx := context.Background()
+	svc, _, _ := suite.newService()
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(&models.UserToken{ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	tokenRepo.On("Update", mock.Anything, mock.Anything).Return(nil, repository.ErrConcurrentModification)
+
+	err := svc.VerifyEmail(ctx, "some-token", userRepo, tokenRepo)
+	suite.ErrorIs(err, service.ErrInvalidToken)
+	userRepo.AssertNotCalled(suite.T(), "FindOne", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestPasswordResetFlow() {
+	ctx := context.Background()
+	svc, outbox, _ := suite.newService()
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	user := &models.User{
+		Shared: models.Shared{ID: "user-1", Version: 1},
+		Name:   "John Doe",
+		Email:  "john@example.com",
+	}
+
+	var stored models.UserToken
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+	tokenRepo.On("DeleteMany", mock.Anything, mock.Anything).Return(nil)
+	tokenRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(models.UserToken) }).
+		Return(func(_ context.Context, t models.UserToken) *models.UserToken { return &t }, nil)
+
+	suite.Require().NoError(svc.RequestPasswordReset(ctx, "john@example.com", userRepo, tokenRepo))
+
+	msg, ok := outbox.Last()
+	suite.Require().True(ok)
+	suite.Equal(models.TokenPurposePasswordReset, stored.Purpose)
+	suite.WithinDuration(time.Now().Add(service.PasswordResetTTL), stored.ExpiresAt, time.Minute)
+
+	var updated models.User
+	tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(&stored, nil)
+	tokenRepo.On("Update", mock.Anything, mock.Anything).Return(&stored, nil)
+	userRepo.On("Update", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { updated = args.Get(1).(models.User) }).
+		Return(user, nil)
+
+	input := service.ResetPasswordInput{Token: suite.extractToken(msg.Body), Password: "a new password"}
+	suite.Require().NoError(svc.ResetPassword(ctx, input, userRepo, tokenRepo))
+
+	suite.NoError(bcrypt.CompareHashAndPassword([]byte(updated.PasswordHash), []byte("a new password")))
+	suite.True(updated.IsEmailVerified())
+}
+
+func (suite *AuthServiceTestSuite) TestRequestPasswordResetForUnknownEmail() {
+	ctx := context.Background()
+	svc, outbox, _ := suite.newService()
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	suite.NoError(svc.RequestPasswordReset(ctx, "nobody@example.com", userRepo, tokenRepo))
+	suite.Empty(outbox.Messages())
+	tokenRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestSendEmailVerificationTokenFailure() {
+	ctx := context.Background()
+	outbox, err := mailer.NewOutboxMailer("", "")
+	suite.Require().NoError(err)
+
+	mockLogger := new(loggermocks.Logger)
+	mockLogger.On("Error", "failed to create token", mock.Anything, mock.Anything, mock.Anything).Return()
+
+	svc := service.NewAuthService(mockLogger, suite.conf, outbox, nil)
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+	tokenRepo.On("DeleteMany", mock.Anything, mock.Anything).Return(nil)
+	tokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil, errors.New("database error"))
+
+	err = svc.SendEmailVerification(ctx, &models.User{Shared: models.Shared{ID: "user-1"}}, tokenRepo)
+	suite.Error(err)
+	suite.Empty(outbox.Messages())
+	mockLogger.AssertExpectations(suite.T())
+}
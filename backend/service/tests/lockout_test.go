@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+)
+
+type LockoutTestSuite struct {
+	testutils.BaseSuite
+	conf         *env.Environment
+	passwordHash string
+}
+
+func TestLockout(t *testing.T) {
+	conf := env.NewEnvironment().
+		SetEnv(constants.JwtSecret, "test-secret")
+
+	passwordHash, err := service.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suite.Run(t, &LockoutTestSuite{conf: &conf, passwordHash: passwordHash})
+}
+
+func (suite *LockoutTestSuite) newService() service.AuthServiceInterface {
+	outbox, err := mailer.NewOutboxMailer("", "")
+	suite.Require().NoError(err)
+
+	return service.NewAuthService(new(loggermocks.Logger), suite.conf, outbox, nil)
+}
+
+func (suite *LockoutTestSuite) newUser(failedAttempts int, lockedUntil *time.Time) *models.User {
+	return &models.User{
+		Shared:              models.Shared{ID: "user-1", Version: 2},
+		Email:               "john@example.com",
+		PasswordHash:        suite.passwordHash,
+		FailedLoginAttempts: failedAttempts,
+		LockedUntil:         lockedUntil,
+	}
+}
+
+// historyRepo returns a login history mock that reports the given number of recent failures from the caller's IP
+func (suite *LockoutTestSuite) historyRepo(recentIPFailures int64) (*repomocks.RepoInterface[models.LoginHistory], *[]models.LoginHistory) {
+	var recorded []models.LoginHistory
+
+	historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+	historyRepo.On("Count", mock.Anything, mock.Anything).Return(recentIPFailures, nil)
+	historyRepo.On("Create", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { recorded = append(recorded, args.Get(1).(models.LoginHistory)) }).
+		Return(&models.LoginHistory{}, nil)
+
+	return historyRepo, &recorded
+}
+
+func (suite *LockoutTestSuite) login(svc service.AuthServiceInterface,
+	password string,
+	userRepo *repomocks.RepoInterface[models.User],
+	historyRepo *repomocks.RepoInterface[models.LoginHistory],
+) (*service.LoginResult, error) {
+	input := service.LoginInput{Email: "john@example.com", Password: password, IPAddress: "203.0.113.7", UserAgent: "test"}
+	return svc.Login(context.Background(), input, userRepo, new(repomocks.RepoInterface[models.UserToken]), historyRepo)
+}
+
+func (suite *LockoutTestSuite) TestFailedLoginLocksWithBackoff() {
+	testCases := []struct {
+		name             string
+		previousFailures int
+		expectedLock     time.Duration
+	}{
+		{name: "below threshold", previousFailures: 0},
+		{name: "reaching threshold", previousFailures: service.MaxFailedLoginAttempts - 1, expectedLock: service.LockoutBaseDuration},
+		{name: "failing again after a lock", previousFailures: service.MaxFailedLoginAttempts + 1, expectedLock: 4 * service.LockoutBaseDuration},
+		{name: "capped backoff", previousFailures: service.MaxFailedLoginAttempts + 40, expectedLock: service.MaxLockoutDuration},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			svc := suite.newService()
+			historyRepo, recorded := suite.historyRepo(0)
+
+			// The count is read back after the increment, as a parallel
+			// failure may have added to it too
+			var updates []map[string]interface{}
+			userRepo := new(repomocks.RepoInterface[models.User])
+			userRepo.On("FindOne", mock.Anything, mock.Anything).Return(suite.newUser(tc.previousFailures, nil), nil).Once()
+			userRepo.On("FindOne", mock.Anything, mock.Anything).Return(suite.newUser(tc.previousFailures+1, nil), nil).Once()
+			userRepo.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { updates = append(updates, args.Get(2).(map[string]interface{})) }).
+				Return(int64(1), nil)
+
+			result, err := suite.login(svc, "battery staple", userRepo, historyRepo)
+			suite.ErrorIs(err, service.ErrInvalidCredentials)
+			suite.Nil(result)
+			userRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+
+			suite.Require().NotEmpty(updates)
+			suite.Equal(map[string]interface{}{"failed_login_attempts": repository.Increment(1)}, updates[0])
+			if tc.expectedLock == 0 {
+				suite.Len(updates, 1)
+			} else {
+				suite.Require().Len(updates, 2)
+				lockedUntil, ok := updates[1]["locked_until"].(time.Time)
+				suite.Require().True(ok)
+				suite.WithinDuration(time.Now().Add(tc.expectedLock), lockedUntil, 5*time.Second)
+			}
+
+			suite.Require().Len(*recorded, 1)
+			suite.Equal(models.LoginFailureInvalidCredentials, (*recorded)[0].FailureReason)
+			suite.Equal("user-1", (*recorded)[0].UserID)
+		})
+	}
+}
+
+func (suite *LockoutTestSuite) TestLockedAccountRejectsCorrectPassword() {
+	svc := suite.newService()
+	historyRepo, recorded := suite.historyRepo(0)
+
+	lockedUntil := time.Now().Add(time.Minute)
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(suite.newUser(service.MaxFailedLoginAttempts, &lockedUntil), nil)
+
+	result, err := suite.login(svc, "correct horse", userRepo, historyRepo)
+	suite.ErrorIs(err, service.ErrAccountLocked)
+	suite.Nil(result)
+	userRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+
+	suite.Require().Len(*recorded, 1)
+	suite.Equal(models.LoginFailureAccountLocked, (*recorded)[0].FailureReason)
+}
+
+func (suite *LockoutTestSuite) TestSuccessfulLoginResetsFailures() {
+	svc := suite.newService()
+	historyRepo, recorded := suite.historyRepo(0)
+
+	expiredLock := time.Now().Add(-time.Minute)
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(suite.newUser(service.MaxFailedLoginAttempts, &expiredLock), nil)
+	userRepo.On("UpdateFields", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.FailedLoginAttempts == 0 && u.LockedUntil == nil
+	}), "failed_login_attempts", "locked_until").Return(&models.User{}, nil)
+
+	result, err := suite.login(svc, "correct horse", userRepo, historyRepo)
+	suite.Require().NoError(err)
+	suite.NotEmpty(result.AccessToken)
+	userRepo.AssertExpectations(suite.T())
+
+	suite.Require().Len(*recorded, 1)
+	suite.True((*recorded)[0].Success)
+	suite.Equal("203.0.113.7", (*recorded)[0].IPAddress)
+}
+
+func (suite *LockoutTestSuite) TestTooManyFailuresFromIP() {
+	svc := suite.newService()
+	historyRepo, recorded := suite.historyRepo(service.MaxFailedLoginsPerIP)
+	userRepo := new(repomocks.RepoInterface[models.User])
+
+	result, err := suite.login(svc, "correct horse", userRepo, historyRepo)
+	suite.ErrorIs(err, service.ErrTooManyLoginAttempts)
+	suite.Nil(result)
+	userRepo.AssertNotCalled(suite.T(), "FindOne", mock.Anything, mock.Anything)
+
+	suite.Require().Len(*recorded, 1)
+	suite.Equal(models.LoginFailureTooManyAttempts, (*recorded)[0].FailureReason)
+}
+
+func (suite *LockoutTestSuite) TestLockStatusAndUnlock() {
+	ctx := context.Background()
+	svc := suite.newService()
+
+	lockedUntil := time.Now().Add(time.Hour)
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(suite.newUser(6, &lockedUntil), nil)
+	userRepo.On("UpdateFields", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.FailedLoginAttempts == 0 && u.LockedUntil == nil
+	}), "failed_login_attempts", "locked_until").Return(&models.User{}, nil)
+
+	status, err := svc.GetLockStatus(ctx, "user-1", userRepo)
+	suite.Require().NoError(err)
+	suite.True(status.Locked)
+	suite.Equal(6, status.FailedAttempts)
+	suite.Equal(&lockedUntil, status.LockedUntil)
+
+	suite.NoError(svc.UnlockAccount(ctx, "user-1", userRepo))
+	userRepo.AssertExpectations(suite.T())
+}
+
+func (suite *LockoutTestSuite) TestUnlockUnknownUser() {
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	suite.ErrorIs(suite.newService().UnlockAccount(context.Background(), "nobody", userRepo), service.ErrUserNotFound)
+}
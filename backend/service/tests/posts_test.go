@@ -226,3 +226,57 @@ func (suite *PostServiceTestSuite) TestGetUserPosts() {
 		}
 	})
 }
+
+func (suite *PostServiceTestSuite) TestGetUserPostsByCursor() {
+	suite.NotPanics(func() {
+		ctx := context.Background()
+
+		type testCase struct {
+			name        string
+			input       service.GetUserPostCursorInput
+			setupMock   func(*repomocks.RepoInterface[models.Post])
+			expectedErr error
+		}
+
+		posts := []*models.Post{{UserID: "user-1", Title: "First Post", Body: "First post content"}}
+		page := &repository.CursorPage{Limit: 1, HasNext: true, NextCursor: "next"}
+
+		testCases := []testCase{
+			{
+				name:  "successfully get a page of posts",
+				input: service.GetUserPostCursorInput{UserID: "user-1", Cursor: "", Limit: 1},
+				setupMock: func(repo *repomocks.RepoInterface[models.Post]) {
+					repo.On("FindManyCursor", mock.Anything, mock.Anything, "", int64(1)).Return(posts, page, nil)
+				},
+			},
+			{
+				name:  "invalid cursor",
+				input: service.GetUserPostCursorInput{UserID: "user-1", Cursor: "garbage", Limit: 1},
+				setupMock: func(repo *repomocks.RepoInterface[models.Post]) {
+					repo.On("FindManyCursor", mock.Anything, mock.Anything, "garbage", int64(1)).Return(nil, nil, repository.ErrInvalidCursor)
+				},
+				expectedErr: repository.ErrInvalidCursor,
+			},
+		}
+
+		for _, tc := range testCases {
+			suite.Run(tc.name, func() {
+				postRepo := new(repomocks.RepoInterface[models.Post])
+				tc.setupMock(postRepo)
+
+				result, cursorPage, err := suite.service.GetUserPostsByCursor(ctx, tc.input, postRepo)
+
+				if tc.expectedErr != nil {
+					suite.ErrorIs(err, tc.expectedErr)
+					suite.Nil(result)
+					suite.Nil(cursorPage)
+					return
+				}
+				suite.NoError(err)
+				suite.Equal(posts, result)
+				suite.Equal(page, cursorPage)
+				postRepo.AssertExpectations(suite.T())
+			})
+		}
+	})
+}
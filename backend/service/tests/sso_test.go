@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/sso"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+	"github.com/tejiriaustin/lema/testutils/oidcfake"
+)
+
+type SSOServiceTestSuite struct {
+	testutils.BaseSuite
+	conf *env.Environment
+	idp  *oidcfake.Server
+}
+
+func TestSSOService(t *testing.T) {
+	idp, err := oidcfake.NewServer("lema", "lema-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idp.Close()
+
+	conf := env.NewEnvironment().
+		SetEnv(constants.JwtSecret, "test-secret")
+
+	suite.Run(t, &SSOServiceTestSuite{conf: &conf, idp: idp})
+}
+
+func (suite *SSOServiceTestSuite) newService() (service.SSOServiceInterface, *loggermocks.Logger) {
+	outbox, err := mailer.NewOutboxMailer("", "")
+	suite.Require().NoError(err)
+
+	mockLogger := new(loggermocks.Logger)
+	mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything).Return().Maybe()
+
+	provider := sso.NewProvider(sso.ProviderConfig{
+		Name:         "fake",
+		IssuerURL:    suite.idp.URL,
+		ClientID:     suite.idp.ClientID,
+		ClientSecret: suite.idp.ClientSecret,
+		RedirectURL:  "http://localhost:8080/auth/oidc/fake/callback",
+	}, nil)
+
+	authService := service.NewAuthService(mockLogger, suite.conf, outbox, nil)
+	return service.NewSSOService(mockLogger, suite.conf, sso.NewRegistry(provider), authService), mockLogger
+}
+
+// authorize starts a login and follows the provider's redirect back
+func (suite *SSOServiceTestSuite) authorize(svc service.SSOServiceInterface) service.SSOCallbackInput {
+	redirect, err := svc.BeginLogin(context.Background(), "fake")
+	suite.Require().NoError(err)
+
+	authURL, err := url.Parse(redirect.URL)
+	suite.Require().NoError(err)
+	suite.Equal("S256", authURL.Query().Get("code_challenge_method"))
+	suite.NotEmpty(authURL.Query().Get("nonce"))
+
+	code, state, err := suite.idp.Authorize(redirect.URL)
+	suite.Require().NoError(err)
+
+	return service.SSOCallbackInput{Provider: "fake", Code: code, State: state, LoginState: redirect.State, IPAddress: "203.0.113.7"}
+}
+
+// historyRepo expects one login attempt through the provider that matches
+func (suite *SSOServiceTestSuite) historyRepo(success bool, failureReason string) *repomocks.RepoInterface[models.LoginHistory] {
+	historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+	historyRepo.On("Create", mock.Anything, mock.MatchedBy(func(h models.LoginHistory) bool {
+		return h.Method == models.LoginMethodOIDC && h.Success == success && h.FailureReason == failureReason && h.IPAddress == "203.0.113.7"
+	})).Return(&models.LoginHistory{}, nil).Once()
+	return historyRepo
+}
+
+func (suite *SSOServiceTestSuite) TestLinksExistingUserByVerifiedEmail() {
+	ctx := context.Background()
+	svc, _ := suite.newService()
+
+	suite.idp.SetClaims(oidcfake.Claims{Subject: "sub-1", Email: "john@example.com", EmailVerified: true})
+
+	user := &models.User{Shared: models.Shared{ID: "user-1", Version: 1}, Email: "john@example.com"}
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	identityRepo := new(repomocks.RepoInterface[models.UserIdentity])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	identityRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+	identityRepo.On("Create", mock.Anything, mock.MatchedBy(func(i models.UserIdentity) bool {
+		return i.UserID == "user-1" && i.Provider == "fake" && i.Subject == "sub-1"
+	})).Return(&models.UserIdentity{}, nil)
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+	userRepo.On("Update", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+		return u.IsEmailVerified()
+	})).Return(user, nil)
+
+	historyRepo := suite.historyRepo(true, "")
+
+	result, err := svc.CompleteLogin(ctx, suite.authorize(svc), userRepo, identityRepo, tokenRepo, historyRepo)
+	suite.Require().NoError(err)
+	suite.NotEmpty(result.AccessToken)
+
+	userRepo.AssertExpectations(suite.T())
+	identityRepo.AssertExpectations(suite.T())
+	historyRepo.AssertExpectations(suite.T())
+}
+
+func (suite *SSOServiceTestSuite) TestLinkedIdentityLogsIn() {
+	ctx := context.Background()
+	svc, _ := suite.newService()
+
+	// The provider no longer vouches for the email, but the identity is already linked
+	suite.idp.SetClaims(oidcfake.Claims{Subject: "sub-2", Email: "jane@example.com"})
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	identityRepo := new(repomocks.RepoInterface[models.UserIdentity])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	identityRepo.On("FindOne", mock.Anything, mock.Anything).Return(&models.UserIdentity{UserID: "user-2", Provider: "fake", Subject: "sub-2"}, nil)
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(&models.User{Shared: models.Shared{ID: "user-2"}, Email: "jane@example.com"}, nil)
+
+	historyRepo := suite.historyRepo(true, "")
+
+	result, err := svc.CompleteLogin(ctx, suite.authorize(svc), userRepo, identityRepo, tokenRepo, historyRepo)
+	suite.Require().NoError(err)
+	suite.NotEmpty(result.AccessToken)
+	identityRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+	historyRepo.AssertExpectations(suite.T())
+}
+
+func (suite *SSOServiceTestSuite) TestRejectsUnverifiedEmail() {
+	ctx := context.Background()
+	svc, _ := suite.newService()
+
+	suite.idp.SetClaims(oidcfake.Claims{Subject: "sub-3", Email: "john@example.com", EmailVerified: false})
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	identityRepo := new(repomocks.RepoInterface[models.UserIdentity])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	identityRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	historyRepo := suite.historyRepo(false, models.LoginFailureSSOEmailNotVerified)
+
+	result, err := svc.CompleteLogin(ctx, suite.authorize(svc), userRepo, identityRepo, tokenRepo, historyRepo)
+	suite.ErrorIs(err, service.ErrSSOEmailNotVerified)
+	suite.Nil(result)
+	userRepo.AssertNotCalled(suite.T(), "FindOne", mock.Anything, mock.Anything)
+	identityRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+	historyRepo.AssertExpectations(suite.T())
+}
+
+func (suite *SSOServiceTestSuite) TestRejectsTamperedState() {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name   string
+		tamper func(*service.SSOCallbackInput)
+	}{
+		{name: "state mismatch", tamper: func(in *service.SSOCallbackInput) { in.State = "forged" }},
+		{name: "missing login state", tamper: func(in *service.SSOCallbackInput) { in.LoginState = "" }},
+		{name: "forged login state", tamper: func(in *service.SSOCallbackInput) { in.LoginState += "x" }},
+		{name: "access token as login state", tamper: func(in *service.SSOCallbackInput) {
+			in.LoginState, _ = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"provider": in.Provider,
+				"state":    in.State,
+				"typ":      constants.TokenTypeAccess,
+				"exp":      time.Now().Add(time.Minute).Unix(),
+			}).SignedString([]byte("test-secret"))
+		}},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			svc, _ := suite.newService()
+			suite.idp.SetClaims(oidcfake.Claims{Subject: "sub-4", Email: "john@example.com", EmailVerified: true})
+
+			input := suite.authorize(svc)
+			tc.tamper(&input)
+
+			userRepo := new(repomocks.RepoInterface[models.User])
+			identityRepo := new(repomocks.RepoInterface[models.UserIdentity])
+			tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+			historyRepo := suite.historyRepo(false, models.LoginFailureInvalidSSOState)
+
+			result, err := svc.CompleteLogin(ctx, input, userRepo, identityRepo, tokenRepo, historyRepo)
+			suite.ErrorIs(err, service.ErrInvalidSSOState)
+			suite.Nil(result)
+			identityRepo.AssertNotCalled(suite.T(), "FindOne", mock.Anything, mock.Anything)
+			historyRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *SSOServiceTestSuite) TestUnknownProvider() {
+	svc, _ := suite.newService()
+
+	_, err := svc.BeginLogin(context.Background(), "nope")
+	suite.ErrorIs(err, sso.ErrUnknownProvider)
+}
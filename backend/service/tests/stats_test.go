@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+)
+
+type StatsServiceTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestStatsService(t *testing.T) {
+	suite.Run(t, new(StatsServiceTestSuite))
+}
+
+func (suite *StatsServiceTestSuite) TestGetOverview() {
+	ctx := context.Background()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	postRepo := new(repomocks.RepoInterface[models.Post])
+
+	userRepo.On("Count", mock.Anything, (*repository.Query)(nil)).Return(int64(40), nil)
+	postRepo.On("Count", mock.Anything, (*repository.Query)(nil)).Return(int64(90), nil)
+	userRepo.On("CountBy", mock.Anything, mock.Anything, repository.ByTime("created_at", repository.Day)).
+		Return([]repository.GroupCount{{Key: "2024-01-02", Count: 3}}, nil)
+	postRepo.On("CountBy", mock.Anything, mock.Anything, repository.ByTime("created_at", repository.Day)).
+		Return([]repository.GroupCount{{Key: "2024-01-01", Count: 5}, {Key: "2024-01-03", Count: 2}}, nil)
+	postRepo.On("CountBy", mock.Anything, mock.Anything, repository.ByColumn("user_id")).
+		Return([]repository.GroupCount{{Key: "alice", Count: 4}, {Key: "gone", Count: 2}, {Key: "bob", Count: 1}}, nil)
+	userRepo.On("FindByIDs", mock.Anything, []string{"alice", "gone", "bob"}).Return([]*models.User{
+		{Shared: models.Shared{ID: "bob"}, Name: "Bob"},
+		{Shared: models.Shared{ID: "alice"}, Name: "Alice"},
+	}, nil)
+
+	overview, err := service.NewStatsService(new(loggermocks.Logger)).GetOverview(ctx, service.StatsOverviewInput{
+		From: from, To: to, TopPosters: 3,
+	}, userRepo, postRepo)
+	suite.Require().NoError(err)
+
+	suite.Equal(repository.Day, overview.Interval)
+	suite.Equal(int64(40), overview.TotalUsers)
+	suite.Equal(int64(90), overview.TotalPosts)
+	suite.Equal(int64(3), overview.NewUsers)
+	suite.Equal(int64(7), overview.NewPosts)
+	suite.Equal([]repository.GroupCount{
+		{Key: "2024-01-01", Count: 0},
+		{Key: "2024-01-02", Count: 3},
+		{Key: "2024-01-03", Count: 0},
+	}, overview.Signups, "days without signups are filled in")
+	suite.Equal([]repository.GroupCount{
+		{Key: "2024-01-01", Count: 5},
+		{Key: "2024-01-02", Count: 0},
+		{Key: "2024-01-03", Count: 2},
+	}, overview.PostsPerDay)
+
+	suite.Require().Len(overview.TopPosters, 2, "posts of deleted users are skipped")
+	suite.Equal("Alice", overview.TopPosters[0].User.Name)
+	suite.Equal(int64(4), overview.TopPosters[0].Posts)
+	suite.Equal("Bob", overview.TopPosters[1].User.Name)
+
+	userRepo.AssertExpectations(suite.T())
+	postRepo.AssertExpectations(suite.T())
+}
+
+func (suite *StatsServiceTestSuite) TestWeeklySignups() {
+	userRepo := new(repomocks.RepoInterface[models.User])
+	postRepo := new(repomocks.RepoInterface[models.Post])
+
+	userRepo.On("Count", mock.Anything, mock.Anything).Return(int64(0), nil)
+	postRepo.On("Count", mock.Anything, mock.Anything).Return(int64(0), nil)
+	userRepo.On("CountBy", mock.Anything, mock.Anything, repository.ByTime("created_at", repository.Week)).
+		Return([]repository.GroupCount{{Key: "2024-01-08", Count: 6}}, nil)
+	postRepo.On("CountBy", mock.Anything, mock.Anything, repository.ByTime("created_at", repository.Day)).
+		Return([]repository.GroupCount{}, nil)
+
+	overview, err := service.NewStatsService(new(loggermocks.Logger)).GetOverview(context.Background(), service.StatsOverviewInput{
+		From:     time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		Interval: repository.Week,
+	}, userRepo, postRepo)
+	suite.Require().NoError(err)
+
+	suite.Equal([]repository.GroupCount{
+		{Key: "2024-01-01", Count: 0},
+		{Key: "2024-01-08", Count: 6},
+	}, overview.Signups, "weeks start on the Monday before from")
+	suite.Len(overview.PostsPerDay, 7)
+	suite.Empty(overview.TopPosters)
+}
+
+func (suite *StatsServiceTestSuite) TestInvalidInput() {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statsService := service.NewStatsService(new(loggermocks.Logger))
+
+	testCases := []struct {
+		name        string
+		input       service.StatsOverviewInput
+		expectError error
+	}{
+		{"to before from", service.StatsOverviewInput{From: from, To: from.AddDate(0, 0, -1)}, service.ErrInvalidStatsRange},
+		{"more than a year", service.StatsOverviewInput{From: from, To: from.AddDate(2, 0, 0)}, service.ErrInvalidStatsRange},
+		{"unknown interval", service.StatsOverviewInput{From: from, To: from.AddDate(0, 0, 1), Interval: "month"}, service.ErrInvalidStatsInterval},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			_, err := statsService.GetOverview(context.Background(), tc.input,
+				new(repomocks.RepoInterface[models.User]), new(repomocks.RepoInterface[models.Post]))
+			suite.ErrorIs(err, tc.expectError)
+		})
+	}
+}
+
+func (suite *StatsServiceTestSuite) TestRepositoryError() {
+	err := errors.New("database error")
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("Count", mock.Anything, mock.Anything).Return(int64(0), err)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockLogger := new(loggermocks.Logger)
+	mockLogger.On("Error", "failed to count users",
+		logger.Field{Key: "err", Value: err},
+		logger.Field{Key: "from", Value: from},
+		logger.Field{Key: "to", Value: from.AddDate(0, 0, 7)},
+	).Return()
+
+	_, got := service.NewStatsService(mockLogger).GetOverview(context.Background(), service.StatsOverviewInput{
+		From: from, To: from.AddDate(0, 0, 7),
+	}, userRepo, new(repomocks.RepoInterface[models.Post]))
+	suite.ErrorIs(got, err)
+	mockLogger.AssertExpectations(suite.T())
+}
@@ -0,0 +1,360 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/mailer"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+	"github.com/tejiriaustin/lema/secrets"
+	"github.com/tejiriaustin/lema/service"
+	"github.com/tejiriaustin/lema/testutils"
+	loggermocks "github.com/tejiriaustin/lema/testutils/mocks/logger"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+)
+
+type TwoFactorTestSuite struct {
+	testutils.BaseSuite
+	conf      *env.Environment
+	secretBox *secrets.Box
+}
+
+func TestTwoFactor(t *testing.T) {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+
+	secretBox, err := secrets.NewBox(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := env.NewEnvironment().
+		SetEnv(constants.JwtSecret, "test-secret").
+		SetEnv(constants.TotpIssuer, "Lema Test")
+
+	suite.Run(t, &TwoFactorTestSuite{conf: &conf, secretBox: secretBox})
+}
+
+func (suite *TwoFactorTestSuite) newService() service.AuthServiceInterface {
+	outbox, err := mailer.NewOutboxMailer("", "")
+	suite.Require().NoError(err)
+
+	return service.NewAuthService(new(loggermocks.Logger), suite.conf, outbox, suite.secretBox)
+}
+
+// enrolledUser returns a user with two-factor enabled and its plain-text secret
+func (suite *TwoFactorTestSuite) enrolledUser(lastUsedStep int64) (*models.User, string) {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "Lema Test", AccountName: "john@example.com"})
+	suite.Require().NoError(err)
+
+	sealed, err := suite.secretBox.Seal([]byte(key.Secret()))
+	suite.Require().NoError(err)
+
+	enabledAt := time.Now().Add(-time.Hour)
+	return &models.User{
+		Shared:             models.Shared{ID: "user-1", Version: 3},
+		Email:              "john@example.com",
+		TotpSecret:         sealed,
+		TotpLastUsedStep:   lastUsedStep,
+		TwoFactorEnabledAt: &enabledAt,
+	}, key.Secret()
+}
+
+func (suite *TwoFactorTestSuite) TestEnrollment() {
+	ctx := context.Background()
+	svc := suite.newService()
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	recoveryRepo := new(repomocks.RepoInterface[models.RecoveryCode])
+
+	user := models.User{Shared: models.Shared{ID: "user-1", Version: 1}, Email: "john@example.com"}
+
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(func(context.Context, *repository.Query, ...string) *models.User {
+		copied := user
+		return &copied
+	}, nil)
+	userRepo.On("Update", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { user = args.Get(1).(models.User) }).
+		Return(&user, nil)
+
+	enrollment, err := svc.BeginTwoFactorEnrollment(ctx, "user-1", userRepo)
+	suite.Require().NoError(err)
+	suite.Contains(enrollment.ProvisioningURI, "otpauth://totp/Lema%20Test:john@example.com")
+	suite.Contains(enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+	suite.NotContains(user.TotpSecret, enrollment.Secret, "the secret must be stored encrypted")
+	suite.False(user.IsTwoFactorEnabled())
+
+	_, err = svc.ConfirmTwoFactorEnrollment(ctx, "user-1", "000000", userRepo, recoveryRepo)
+	suite.ErrorIs(err, service.ErrInvalidTwoFactorCode)
+	suite.False(user.IsTwoFactorEnabled())
+
+	var stored []models.RecoveryCode
+	recoveryRepo.On("DeleteMany", mock.Anything, mock.Anything).Return(nil)
+	recoveryRepo.On("CreateMany", mock.Anything, mock.Anything, 0).
+		Run(func(args mock.Arguments) { stored = append(stored, args.Get(1).([]models.RecoveryCode)...) }).
+		Return([]*models.RecoveryCode{}, nil)
+
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+	suite.Require().NoError(err)
+
+	recoveryCodes, err := svc.ConfirmTwoFactorEnrollment(ctx, "user-1", code, userRepo, recoveryRepo)
+	suite.Require().NoError(err)
+	suite.Len(recoveryCodes, service.RecoveryCodeCount)
+	suite.Len(stored, service.RecoveryCodeCount)
+	suite.NotEqual(recoveryCodes[0], stored[0].CodeHash)
+	suite.True(user.IsTwoFactorEnabled())
+
+	_, err = svc.BeginTwoFactorEnrollment(ctx, "user-1", userRepo)
+	suite.ErrorIs(err, service.ErrTwoFactorAlreadyEnabled)
+}
+
+func (suite *TwoFactorTestSuite) TestLoginReturnsChallenge() {
+	ctx := context.Background()
+	svc := suite.newService()
+
+	user, _ := suite.enrolledUser(0)
+	passwordHash, err := service.HashPassword("correct horse")
+	suite.Require().NoError(err)
+	user.PasswordHash = passwordHash
+	user.FailedLoginAttempts = 2
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+	tokenRepo.On("DeleteMany", mock.Anything, mock.Anything).Return(nil)
+	tokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(t models.UserToken) bool {
+		return t.Purpose == models.TokenPurposeTwoFactorLogin
+	})).Return(&models.UserToken{}, nil)
+
+	historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+	historyRepo.On("Count", mock.Anything, mock.Anything).Return(int64(0), nil)
+
+	result, err := svc.Login(ctx, service.LoginInput{Email: "john@example.com", Password: "correct horse"}, userRepo, tokenRepo, historyRepo)
+	suite.Require().NoError(err)
+	suite.True(result.TwoFactorRequired)
+	suite.NotEmpty(result.ChallengeToken)
+	suite.Empty(result.AccessToken)
+	tokenRepo.AssertExpectations(suite.T())
+
+	// The password alone doesn't make a successful login
+	userRepo.AssertNotCalled(suite.T(), "UpdateFields", mock.Anything, mock.Anything, mock.Anything)
+	historyRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *TwoFactorTestSuite) TestCompleteTwoFactorLogin() {
+	ctx := context.Background()
+	currentStep := time.Now().Unix() / 30
+
+	challenge := func() *models.UserToken {
+		return &models.UserToken{
+			Shared:    models.Shared{Version: 1},
+			UserID:    "user-1",
+			Purpose:   models.TokenPurposeTwoFactorLogin,
+			ExpiresAt: time.Now().Add(time.Minute),
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		lastUsedStep int64
+		code         func(secret string) string
+		setupMock    func(*repomocks.RepoInterface[models.User], *repomocks.RepoInterface[models.RecoveryCode])
+		expectedErr  error
+	}{
+		{
+			name: "valid authenticator code",
+			code: func(secret string) string {
+				code, _ := totp.GenerateCode(secret, time.Now())
+				return code
+			},
+			setupMock: func(userRepo *repomocks.RepoInterface[models.User], _ *repomocks.RepoInterface[models.RecoveryCode]) {
+				userRepo.On("Update", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+					return u.TotpLastUsedStep >= currentStep
+				})).Return(func(_ context.Context, u models.User) *models.User { return &u }, nil)
+			},
+		},
+		{
+			name:         "replayed authenticator code",
+			lastUsedStep: currentStep + 1,
+			code: func(secret string) string {
+				code, _ := totp.GenerateCode(secret, time.Now())
+				return code
+			},
+			expectedErr: service.ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "wrong authenticator code",
+			code: func(secret string) string {
+				code, _ := totp.GenerateCode(secret, time.Now().Add(-time.Hour))
+				return code
+			},
+			expectedErr: service.ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "valid recovery code",
+			code: func(string) string { return "abcde-fghij" },
+			setupMock: func(_ *repomocks.RepoInterface[models.User], recoveryRepo *repomocks.RepoInterface[models.RecoveryCode]) {
+				recoveryRepo.On("FindOne", mock.Anything, mock.Anything).Return(&models.RecoveryCode{UserID: "user-1"}, nil)
+				recoveryRepo.On("Update", mock.Anything, mock.MatchedBy(func(r models.RecoveryCode) bool {
+					return r.UsedAt != nil
+				})).Return(&models.RecoveryCode{}, nil)
+			},
+		},
+		{
+			name: "unknown recovery code",
+			code: func(string) string { return "abcde-fghij" },
+			setupMock: func(_ *repomocks.RepoInterface[models.User], recoveryRepo *repomocks.RepoInterface[models.RecoveryCode]) {
+				recoveryRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, nil)
+			},
+			expectedErr: service.ErrInvalidTwoFactorCode,
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			svc := suite.newService()
+			user, secret := suite.enrolledUser(tc.lastUsedStep)
+			user.FailedLoginAttempts = 2
+
+			userRepo := new(repomocks.RepoInterface[models.User])
+			tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+			recoveryRepo := new(repomocks.RepoInterface[models.RecoveryCode])
+			historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+
+			userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+			tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(challenge(), nil)
+			if tc.expectedErr == nil {
+				tokenRepo.On("Update", mock.Anything, mock.MatchedBy(func(t models.UserToken) bool {
+					return t.UsedAt != nil
+				})).Return(&models.UserToken{}, nil)
+				userRepo.On("UpdateFields", mock.Anything, mock.MatchedBy(func(u models.User) bool {
+					return u.FailedLoginAttempts == 0
+				}), "failed_login_attempts", "locked_until").Return(&models.User{}, nil)
+				historyRepo.On("Create", mock.Anything, mock.MatchedBy(func(h models.LoginHistory) bool {
+					return h.Success && h.UserID == "user-1" && h.IPAddress == "203.0.113.7"
+				})).Return(&models.LoginHistory{}, nil)
+			} else {
+				tokenRepo.On("UpdateMany", mock.Anything, mock.Anything, map[string]interface{}{"attempts": repository.Increment(1)}).Return(int64(1), nil)
+				userRepo.On("UpdateMany", mock.Anything, mock.Anything, map[string]interface{}{"failed_login_attempts": repository.Increment(1)}).Return(int64(1), nil)
+				historyRepo.On("Create", mock.Anything, mock.MatchedBy(func(h models.LoginHistory) bool {
+					return !h.Success && h.FailureReason == models.LoginFailureInvalidTwoFactor
+				})).Return(&models.LoginHistory{}, nil)
+			}
+			if tc.setupMock != nil {
+				tc.setupMock(userRepo, recoveryRepo)
+			}
+
+			input := service.TwoFactorLoginInput{ChallengeToken: "challenge", Code: tc.code(secret), IPAddress: "203.0.113.7"}
+			result, err := svc.CompleteTwoFactorLogin(ctx, input, userRepo, tokenRepo, recoveryRepo, historyRepo)
+
+			if tc.expectedErr != nil {
+				suite.ErrorIs(err, tc.expectedErr)
+				suite.Nil(result)
+				tokenRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+				// A wrong code counts against the challenge and the account alike
+				tokenRepo.AssertExpectations(suite.T())
+				userRepo.AssertExpectations(suite.T())
+				historyRepo.AssertExpectations(suite.T())
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.NotEmpty(result.AccessToken)
+			userRepo.AssertExpectations(suite.T())
+			tokenRepo.AssertExpectations(suite.T())
+			recoveryRepo.AssertExpectations(suite.T())
+			historyRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *TwoFactorTestSuite) TestChallengeIsDiscardedAfterTooManyWrongCodes() {
+	ctx := context.Background()
+	svc := suite.newService()
+	user, _ := suite.enrolledUser(0)
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+	historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+
+	challenge := models.UserToken{
+		Shared:    models.Shared{ID: "challenge-1", Version: 1},
+		UserID:    "user-1",
+		Purpose:   models.TokenPurposeTwoFactorLogin,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+	userRepo.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).Return(int64(1), nil)
+	historyRepo.On("Create", mock.Anything, mock.Anything).Return(&models.LoginHistory{}, nil)
+	tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(func(context.Context, *repository.Query, ...string) *models.UserToken {
+		copied := challenge
+		return &copied
+	}, nil)
+	tokenRepo.On("UpdateMany", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { challenge.Attempts++ }).
+		Return(int64(1), nil)
+	tokenRepo.On("DeleteMany", mock.Anything, mock.Anything).Return(nil).Once()
+
+	input := service.TwoFactorLoginInput{ChallengeToken: "challenge", Code: "000000"}
+	for i := 0; i < service.MaxTwoFactorAttempts; i++ {
+		_, err := svc.CompleteTwoFactorLogin(ctx, input, userRepo, tokenRepo, nil, historyRepo)
+		suite.ErrorIs(err, service.ErrInvalidTwoFactorCode)
+	}
+	tokenRepo.AssertExpectations(suite.T())
+
+	// Even if discarding it had failed, the challenge takes no more codes
+	_, err := svc.CompleteTwoFactorLogin(ctx, input, userRepo, tokenRepo, nil, historyRepo)
+	suite.ErrorIs(err, service.ErrInvalidToken)
+}
+
+func (suite *TwoFactorTestSuite) TestLockedAccountCannotCompleteLogin() {
+	ctx := context.Background()
+	svc := suite.newService()
+	user, secret := suite.enrolledUser(0)
+	lockedUntil := time.Now().Add(time.Hour)
+	user.LockedUntil = &lockedUntil
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	tokenRepo := new(repomocks.RepoInterface[models.UserToken])
+	historyRepo := new(repomocks.RepoInterface[models.LoginHistory])
+
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(user, nil)
+	tokenRepo.On("FindOne", mock.Anything, mock.Anything).Return(&models.UserToken{
+		UserID:    "user-1",
+		Purpose:   models.TokenPurposeTwoFactorLogin,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	historyRepo.On("Create", mock.Anything, mock.MatchedBy(func(h models.LoginHistory) bool {
+		return h.FailureReason == models.LoginFailureAccountLocked
+	})).Return(&models.LoginHistory{}, nil)
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	suite.Require().NoError(err)
+
+	_, err = svc.CompleteTwoFactorLogin(ctx, service.TwoFactorLoginInput{ChallengeToken: "challenge", Code: code}, userRepo, tokenRepo, nil, historyRepo)
+	suite.ErrorIs(err, service.ErrAccountLocked)
+	userRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+	historyRepo.AssertExpectations(suite.T())
+}
+
+func (suite *TwoFactorTestSuite) TestUnavailableWithoutEncryptionKey() {
+	outbox, err := mailer.NewOutboxMailer("", "")
+	suite.Require().NoError(err)
+
+	svc := service.NewAuthService(new(loggermocks.Logger), suite.conf, outbox, nil)
+
+	_, err = svc.BeginTwoFactorEnrollment(context.Background(), "user-1", new(repomocks.RepoInterface[models.User]))
+	suite.ErrorIs(err, service.ErrTwoFactorUnavailable)
+}
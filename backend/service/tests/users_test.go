@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -161,11 +162,12 @@ func (suite *UserServiceTestSuite) TestCreateUser() {
 func (suite *UserServiceTestSuite) TestGetUsers() {
 	suite.NotPanics(func() {
 		ctx := context.Background()
+		verifiedAt := time.Now()
 
 		type testCase struct {
 			name        string
 			input       func() service.GetUsersInput
-			output      func() []*models.User
+			output      func() []*models.UserSummary
 			paginate    func() *repository.Paginator
 			setupMock   func(*repomocks.RepoInterface[models.User])
 			expectError bool
@@ -182,13 +184,17 @@ func (suite *UserServiceTestSuite) TestGetUsers() {
 						},
 					}
 				},
-				output: func() []*models.User {
-					return []*models.User{
+				output: func() []*models.UserSummary {
+					return []*models.UserSummary{
 						{
-							Name:  "John Doe",
-							Email: "john@example.com",
+							ID:            "john",
+							Name:          "John Doe",
+							Email:         "john@example.com",
+							EmailVerified: true,
+							Address:       &models.AddressSummary{ID: "home", City: "Lagos"},
 						},
 						{
+							ID:    "jane",
 							Name:  "Jane Doe",
 							Email: "jane@example.com",
 						},
@@ -207,15 +213,18 @@ func (suite *UserServiceTestSuite) TestGetUsers() {
 						mock.Anything,
 						int64(1),
 						int64(10),
-						"Address",
 					).Return([]*models.User{
 						{
-							Name:  "John Doe",
-							Email: "john@example.com",
+							Shared:          models.Shared{ID: "john"},
+							Name:            "John Doe",
+							Email:           "john@example.com",
+							EmailVerifiedAt: &verifiedAt,
+							Address:         &models.Address{Shared: models.Shared{ID: "home"}, City: "Lagos"},
 						},
 						{
-							Name:  "Jane Doe",
-							Email: "jane@example.com",
+							Shared: models.Shared{ID: "jane"},
+							Name:   "Jane Doe",
+							Email:  "jane@example.com",
 						},
 					}, &repository.Paginator{
 						CurrentPage: 1,
@@ -235,7 +244,7 @@ func (suite *UserServiceTestSuite) TestGetUsers() {
 						},
 					}
 				},
-				output: func() []*models.User {
+				output: func() []*models.UserSummary {
 					return nil
 				},
 				paginate: func() *repository.Paginator {
@@ -247,7 +256,6 @@ func (suite *UserServiceTestSuite) TestGetUsers() {
 						mock.Anything,
 						int64(1),
 						int64(10),
-						"Address",
 					).Return(nil, nil, errors.New("database error"))
 				},
 				expectError: true,
@@ -375,6 +383,58 @@ func (suite *UserServiceTestSuite) TestGetUserByID() {
 	})
 }
 
+func (suite *UserServiceTestSuite) TestGetUsersByIDs() {
+	svc := service.NewUserService(new(loggermocks.Logger))
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("FindByIDs", mock.Anything, []string{"alice", "bob", "deleted"}, "Address").
+		Return([]*models.User{
+			{Shared: models.Shared{ID: "alice"}, Name: "Alice"},
+			{Shared: models.Shared{ID: "bob"}, Name: "Bob"},
+		}, nil).Once()
+
+	// The request's loader remembers the users, so the second lookup is free
+	loaders := &repository.Loaders{Users: repository.NewLoader[models.User](userRepo, "Address")}
+	ctx := repository.WithLoaders(context.Background(), loaders)
+
+	users, err := svc.GetUsersByIDs(ctx, []string{"alice", "bob", "alice", "deleted"}, userRepo)
+	suite.Require().NoError(err)
+	suite.Len(users, 2)
+	suite.Equal("Alice", users["alice"].Name)
+	suite.NotContains(users, "deleted")
+
+	users, err = svc.GetUsersByIDs(ctx, []string{"bob"}, userRepo)
+	suite.Require().NoError(err)
+	suite.Equal("Bob", users["bob"].Name)
+
+	userRepo.AssertExpectations(suite.T())
+}
+
+func (suite *UserServiceTestSuite) TestLockUser() {
+	ctx := context.Background()
+
+	mockLogger := new(loggermocks.Logger)
+	mockLogger.On("Error", "failed to lock user", mock.Anything).Return().Once()
+	svc := service.NewUserService(mockLogger)
+
+	userRepo := new(repomocks.RepoInterface[models.User])
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(&models.User{Name: "John Doe"}, nil).Once()
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound).Once()
+	userRepo.On("FindOne", mock.Anything, mock.Anything).Return(nil, errors.New("connection reset")).Once()
+
+	user, err := svc.LockUser(ctx, "user-123", userRepo)
+	suite.Require().NoError(err)
+	suite.Equal("John Doe", user.Name)
+
+	_, err = svc.LockUser(ctx, "non-existent", userRepo)
+	suite.ErrorIs(err, service.ErrUserNotFound)
+
+	// Only unexpected errors are logged
+	_, err = svc.LockUser(ctx, "user-123", userRepo)
+	suite.ErrorIs(err, service.ErrUserNotFound)
+	mockLogger.AssertExpectations(suite.T())
+}
+
 func (suite *UserServiceTestSuite) TestGetUserCount() {
 	suite.NotPanics(func() {
 		ctx := context.Background()
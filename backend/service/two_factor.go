@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	constants "github.com/tejiriaustin/lema/constants"
+	"github.com/tejiriaustin/lema/logger"
+	"github.com/tejiriaustin/lema/models"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+const (
+	RecoveryCodeCount = 10
+
+	// MaxTwoFactorAttempts is how many wrong codes a login challenge takes
+	// before it is discarded and the password has to be entered again
+	MaxTwoFactorAttempts = 5
+
+	totpPeriod = 30
+	totpSkew   = 1
+)
+
+type (
+	TwoFactorEnrollment struct {
+		Secret          string
+		ProvisioningURI string
+	}
+
+	TwoFactorLoginInput struct {
+		ChallengeToken string
+		Code           string
+		IPAddress      string
+		UserAgent      string
+	}
+)
+
+func (s *AuthService) BeginTwoFactorEnrollment(ctx context.Context,
+	userID string,
+	userRepo repository.RepoInterface[models.User],
+) (*TwoFactorEnrollment, error) {
+	if s.secretBox == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", userID)))
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	issuer := s.conf.GetAsString(constants.TotpIssuer)
+	if issuer == "" {
+		issuer = "Lema"
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	sealed, err := s.secretBox.Seal([]byte(key.Secret()))
+	if err != nil {
+		return nil, err
+	}
+
+	// The secret stays pending until it is confirmed with a first code
+	user.TotpSecret = sealed
+	if _, err := userRepo.Update(ctx, *user); err != nil {
+		s.lemaLogger.Error("failed to store totp secret",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+	}, nil
+}
+
+func (s *AuthService) ConfirmTwoFactorEnrollment(ctx context.Context,
+	userID string,
+	code string,
+	userRepo repository.RepoInterface[models.User],
+	recoveryRepo repository.RepoInterface[models.RecoveryCode],
+) ([]string, error) {
+	if s.secretBox == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", userID)))
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	if user.IsTwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TotpSecret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	step, err := s.validateTotp(user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.replaceRecoveryCodes(ctx, user.ID, recoveryRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	user.TwoFactorEnabledAt = &now
+	user.TotpLastUsedStep = step
+
+	if _, err := userRepo.Update(ctx, *user); err != nil {
+		s.lemaLogger.Error("failed to enable two-factor authentication",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// CompleteTwoFactorLogin finishes a login started with a password. Wrong
+// codes count against the challenge and, like wrong passwords, against the
+// account, so guessing codes ends in a lockout too. The login only counts as
+// successful, and the account's failures are forgotten, once a code passes.
+func (s *AuthService) CompleteTwoFactorLogin(ctx context.Context,
+	input TwoFactorLoginInput,
+	userRepo repository.RepoInterface[models.User],
+	tokenRepo repository.RepoInterface[models.UserToken],
+	recoveryRepo repository.RepoInterface[models.RecoveryCode],
+	historyRepo repository.RepoInterface[models.LoginHistory],
+) (*LoginResult, error) {
+	if s.secretBox == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+
+	challenge, err := s.findToken(ctx, input.ChallengeToken, models.TokenPurposeTwoFactorLogin, tokenRepo)
+	if err != nil || challenge.Attempts >= MaxTwoFactorAttempts {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := userRepo.FindOne(ctx, repository.NewQueryFilter().Where(repository.Eq("id", challenge.UserID)))
+	if err != nil || user == nil || !user.IsTwoFactorEnabled() {
+		return nil, ErrInvalidToken
+	}
+
+	attempt := LoginInput{Email: user.Email, IPAddress: input.IPAddress, UserAgent: input.UserAgent}
+	if user.IsLocked(s.now()) {
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, attempt, user, models.LoginFailureAccountLocked, historyRepo)
+		return nil, ErrAccountLocked
+	}
+
+	code := strings.TrimSpace(input.Code)
+	if isTotpCode(code) {
+		var step int64
+		step, err = s.validateTotp(user, code)
+		if err == nil {
+			user.TotpLastUsedStep = step
+			var updated *models.User
+			updated, err = userRepo.Update(ctx, *user)
+			switch {
+			case errors.Is(err, repository.ErrConcurrentModification):
+				// Another request used a code in the meantime
+				err = ErrInvalidTwoFactorCode
+			case err == nil:
+				user = updated
+			}
+		}
+	} else {
+		err = s.useRecoveryCode(ctx, user.ID, code, recoveryRepo)
+	}
+
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		s.failTwoFactorChallenge(ctx, challenge, tokenRepo)
+		s.registerFailedLogin(ctx, user, userRepo)
+		recordLoginAttempt(ctx, s.lemaLogger, models.LoginMethodPassword, attempt, user, models.LoginFailureInvalidTwoFactor, historyRepo)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.consumeToken(ctx, challenge, tokenRepo); err != nil {
+		return nil, err
+	}
+
+	if err := s.completeLogin(ctx, attempt, user, userRepo, historyRepo); err != nil {
+		return nil, err
+	}
+	return s.issueAccessToken(user)
+}
+
+// failTwoFactorChallenge counts a wrong code against a login challenge and
+// discards the challenge once it has taken MaxTwoFactorAttempts. The count is
+// incremented in SQL, so codes sent in parallel each count.
+func (s *AuthService) failTwoFactorChallenge(ctx context.Context,
+	challenge *models.UserToken,
+	tokenRepo repository.RepoInterface[models.UserToken],
+) {
+	byID := func() *repository.Query {
+		return repository.NewQueryFilter().Where(repository.Eq("id", challenge.ID))
+	}
+	fail := func(msg string, err error) {
+		s.lemaLogger.Error(msg,
+			logger.WithField("err", err),
+			logger.WithField("user_id", challenge.UserID))
+	}
+
+	_, err := tokenRepo.UpdateMany(ctx, byID(), map[string]interface{}{
+		"attempts": repository.Increment(1),
+	})
+	if err != nil {
+		fail("failed to record wrong two-factor code", err)
+		return
+	}
+
+	current, err := tokenRepo.FindOne(ctx, byID())
+	if err != nil || current == nil || current.Attempts < MaxTwoFactorAttempts {
+		return
+	}
+	if err := tokenRepo.DeleteMany(ctx, byID()); err != nil {
+		fail("failed to discard two-factor challenge", err)
+	}
+}
+
+// validateTotp checks a code against the user's secret, allowing one step of
+// clock drift either way. Codes from a step that was already used are rejected
+// so an intercepted code cannot be replayed.
+func (s *AuthService) validateTotp(user *models.User, code string) (int64, error) {
+	secret, err := s.secretBox.Open(user.TotpSecret)
+	if err != nil {
+		s.lemaLogger.Error("failed to decrypt totp secret",
+			logger.WithField("err", err),
+			logger.WithField("user_id", user.ID))
+		return 0, err
+	}
+
+	opts := totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+
+	current := s.now().Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step := current + offset
+		if step <= user.TotpLastUsedStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(string(secret), time.Unix(step*totpPeriod, 0), opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate totp code: %w", err)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+
+	return 0, ErrInvalidTwoFactorCode
+}
+
+// replaceRecoveryCodes discards any existing recovery codes and returns a fresh set
+func (s *AuthService) replaceRecoveryCodes(ctx context.Context,
+	userID string,
+	recoveryRepo repository.RepoInterface[models.RecoveryCode],
+) ([]string, error) {
+	if err := recoveryRepo.DeleteMany(ctx, repository.NewQueryFilter().Where(repository.Eq("user_id", userID))); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	recoveryCodes := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		recoveryCodes = append(recoveryCodes, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(code),
+		})
+	}
+
+	if _, err := recoveryRepo.CreateMany(ctx, recoveryCodes, 0); err != nil {
+		s.lemaLogger.Error("failed to store recovery codes",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) useRecoveryCode(ctx context.Context,
+	userID string,
+	code string,
+	recoveryRepo repository.RepoInterface[models.RecoveryCode],
+) error {
+	filter := repository.NewQueryFilter().Where(
+		repository.Eq("user_id", userID),
+		repository.Eq("code_hash", hashToken(strings.ToLower(code))),
+		repository.IsNull("used_at"),
+	)
+
+	recoveryCode, err := recoveryRepo.FindOne(ctx, filter)
+	if err != nil || recoveryCode == nil {
+		return ErrInvalidTwoFactorCode
+	}
+
+	now := s.now().UTC()
+	recoveryCode.UsedAt = &now
+
+	if _, err := recoveryRepo.Update(ctx, *recoveryCode); err != nil {
+		if errors.Is(err, repository.ErrConcurrentModification) {
+			return ErrInvalidTwoFactorCode
+		}
+		return err
+	}
+	return nil
+}
+
+// newRecoveryCode returns a code such as "k3j9d-x2mqa"
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+func isTotpCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
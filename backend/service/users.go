@@ -17,6 +17,7 @@ type (
 	CreateUserInput struct {
 		FullName string
 		Email    string
+		Password string
 		Address  *models.Address
 	}
 
@@ -47,7 +48,8 @@ func (s *UserService) CreateUser(ctx context.Context,
 		Address: input.Address,
 	}
 
-	filter := repository.NewQueryFilter().Where("email = ?", user.Email)
+	// A deleted user keeps their email until they are purged
+	filter := repository.NewQueryFilter().Where(repository.Eq("email", user.Email)).WithDeleted()
 
 	foundUser, err := userRepo.FindOne(ctx, filter)
 	if foundUser != nil {
@@ -57,6 +59,13 @@ func (s *UserService) CreateUser(ctx context.Context,
 		return nil, errors.New("A user with this email already exists")
 	}
 
+	if input.Password != "" {
+		user.PasswordHash, err = HashPassword(input.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	createdUser, err := userRepo.Create(ctx, user)
 	if err != nil {
 		s.lemaLogger.Error("failed to create USER",
@@ -74,32 +83,111 @@ func (s *UserService) CreateUser(ctx context.Context,
 func (s *UserService) GetUsers(ctx context.Context,
 	input GetUsersInput,
 	userRepo repository.RepoInterface[models.User],
-) ([]*models.User, *repository.Paginator, error) {
+) ([]*models.UserSummary, *repository.Paginator, error) {
+
+	// Only what the user listing shows, which also keeps password hashes and
+	// TOTP secrets out of memory
+	filter := repository.NewQueryFilter().
+		Select(models.UserSummaryColumns...).
+		Preload("Address", models.AddressSummaryColumns...)
 
-	users, paginate, err := userRepo.FindManyPaginated(ctx, nil, input.Page, input.PerPage, "Address")
+	users, paginate, err := userRepo.FindManyPaginated(ctx, filter, input.Page, input.PerPage)
 	if err != nil {
 		s.lemaLogger.Error("failed to get users", logger.WithField("err", err))
 		return nil, nil, err
 	}
 
-	return users, paginate, nil
+	summaries := make([]*models.UserSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, user.Summary())
+	}
+	return summaries, paginate, nil
 }
 
 func (s *UserService) GetUserByID(ctx context.Context,
 	userID string,
 	userRepo repository.RepoInterface[models.User],
 ) (*models.User, error) {
-	filter := repository.NewQueryFilter().Where("id = ?", userID)
+	filter := repository.NewQueryFilter().Where(repository.Eq("id", userID))
 
 	user, err := userRepo.FindOne(ctx, filter, "Address")
 	if err != nil || user == nil {
 		s.lemaLogger.Error("failed to get user by id", logger.WithField("err", err))
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	return user, nil
 }
 
+// GetUsersByIDs loads the users with ids in one query, keyed by ID. Within a
+// request it reads through the request's loader, so users the request has
+// already loaded aren't read again. Users that don't exist are left out.
+func (s *UserService) GetUsersByIDs(ctx context.Context,
+	ids []string,
+	userRepo repository.RepoInterface[models.User],
+) (map[string]*models.User, error) {
+	loader := repository.NewLoader[models.User](userRepo, "Address")
+	if loaders, ok := repository.LoadersFromContext(ctx); ok {
+		loader = loaders.Users
+	}
+
+	users, err := loader.LoadMany(ctx, ids)
+	if err != nil {
+		s.lemaLogger.Error("failed to get users by ids", logger.WithField("err", err))
+		return nil, err
+	}
+	return users, nil
+}
+
+// LockUser loads a user and locks their row until the transaction userRepo
+// belongs to ends, so the user can't be deleted before it commits
+func (s *UserService) LockUser(ctx context.Context,
+	userID string,
+	userRepo repository.RepoInterface[models.User],
+) (*models.User, error) {
+	filter := repository.NewQueryFilter().Where(repository.Eq("id", userID)).ForUpdate()
+
+	user, err := userRepo.FindOne(ctx, filter)
+	if err != nil || user == nil {
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			s.lemaLogger.Error("failed to lock user", logger.WithField("err", err))
+		}
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// DeleteUser soft deletes a user and their address. Run it inside a unit of
+// work together with the removal of anything else the user owns.
+func (s *UserService) DeleteUser(ctx context.Context,
+	userID string,
+	userRepo repository.RepoInterface[models.User],
+	addressRepo repository.RepoInterface[models.Address],
+) error {
+	filter := repository.NewQueryFilter().Where(repository.Eq("id", userID))
+
+	user, err := userRepo.FindOne(ctx, filter)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := addressRepo.DeleteMany(ctx, repository.NewQueryFilter().Where(repository.Eq("user_id", userID))); err != nil {
+		s.lemaLogger.Error("failed to delete user's address",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return err
+	}
+
+	if err := userRepo.DeleteMany(ctx, filter); err != nil {
+		s.lemaLogger.Error("failed to delete user",
+			logger.WithField("err", err),
+			logger.WithField("user_id", userID))
+		return err
+	}
+	return nil
+}
+
 func (s *UserService) GetUserCount(ctx context.Context,
 	userRepo repository.RepoInterface[models.User],
 ) (int64, error) {
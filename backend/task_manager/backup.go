@@ -0,0 +1,80 @@
+package task_manager
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/lema/env"
+)
+
+const (
+	// BackupDatabaseTask is the name the backup job is registered under
+	BackupDatabaseTask = "backup-database"
+
+	backupPrefix     = "lema-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// Backuper writes a consistent copy of the database to a file
+type Backuper interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// BackupDatabase returns a Handler that writes a timestamped backup into dir
+// and then deletes all but the newest keep backups there. Files that don't
+// look like its own backups are left alone.
+func BackupDatabase(backuper Backuper, dir string, keep int) Handler {
+	return func(ctx context.Context, _ *env.Environment) {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			log.Printf("Failed to create backup directory %s: %v", dir, err)
+			return
+		}
+
+		path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupTimeFormat)+backupSuffix)
+		if err := backuper.Backup(ctx, path); err != nil {
+			log.Printf("Failed to back up the database: %v", err)
+			return
+		}
+		log.Printf("Backed up the database to %s", path)
+
+		pruneBackups(dir, keep)
+	}
+}
+
+// pruneBackups removes the oldest backups in dir beyond keep. Backup names
+// sort by the time they were taken.
+func pruneBackups(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Failed to list backups in %s: %v", dir, err)
+		return
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= keep {
+		return
+	}
+
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			log.Printf("Failed to remove old backup %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package task_manager
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tejiriaustin/lema/env"
+	"github.com/tejiriaustin/lema/repository"
+)
+
+// PurgeDeletedTask is the name the purge job is registered under
+const PurgeDeletedTask = "purge-deleted-records"
+
+// PurgeTarget names a repository whose soft deleted records are purged
+type PurgeTarget struct {
+	Name   string
+	Purger repository.Purger
+}
+
+// PurgeDeleted returns a Handler that permanently removes records soft deleted
+// more than retention ago. Targets are purged in order, so list records
+// before the records they reference.
+func PurgeDeleted(retention time.Duration, targets ...PurgeTarget) Handler {
+	return func(ctx context.Context, _ *env.Environment) {
+		deletedBefore := time.Now().Add(-retention)
+
+		for _, target := range targets {
+			removed, err := target.Purger.Purge(ctx, deletedBefore)
+			if err != nil {
+				log.Printf("Failed to purge deleted %s: %v", target.Name, err)
+				continue
+			}
+			if removed > 0 {
+				log.Printf("Purged %d deleted %s", removed, target.Name)
+			}
+		}
+	}
+}
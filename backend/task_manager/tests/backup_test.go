@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/task_manager"
+	"github.com/tejiriaustin/lema/testutils"
+	taskmocks "github.com/tejiriaustin/lema/testutils/mocks/task_manager"
+)
+
+type BackupTestSuite struct {
+	testutils.BaseSuite
+	dir string
+}
+
+func TestBackup(t *testing.T) {
+	suite.Run(t, new(BackupTestSuite))
+}
+
+func (suite *BackupTestSuite) SetupTest() {
+	suite.dir = filepath.Join(suite.T().TempDir(), "backups")
+}
+
+func (suite *BackupTestSuite) files() []string {
+	entries, err := os.ReadDir(suite.dir)
+	suite.Require().NoError(err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func (suite *BackupTestSuite) TestWritesABackupAndPrunesOldOnes() {
+	suite.Require().NoError(os.MkdirAll(suite.dir, 0o750))
+	for _, name := range []string{"lema-20240101T000000Z.db", "lema-20240102T000000Z.db", "notes.txt"} {
+		suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, name), nil, 0o600))
+	}
+
+	backuper := new(taskmocks.Backuper)
+	backuper.On("Backup", mock.Anything, mock.MatchedBy(func(path string) bool {
+		return filepath.Dir(path) == suite.dir && strings.HasPrefix(filepath.Base(path), "lema-")
+	})).Run(func(args mock.Arguments) {
+		suite.Require().NoError(os.WriteFile(args.String(1), nil, 0o600))
+	}).Return(nil)
+
+	task_manager.BackupDatabase(backuper, suite.dir, 2)(context.Background(), nil)
+
+	files := suite.files()
+	suite.Len(files, 3)
+	suite.NotContains(files, "lema-20240101T000000Z.db", "the oldest backup is pruned")
+	suite.Contains(files, "lema-20240102T000000Z.db")
+	suite.Contains(files, "notes.txt", "other files are left alone")
+	backuper.AssertExpectations(suite.T())
+}
+
+func (suite *BackupTestSuite) TestFailedBackupPrunesNothing() {
+	suite.Require().NoError(os.MkdirAll(suite.dir, 0o750))
+	for _, name := range []string{"lema-20240101T000000Z.db", "lema-20240102T000000Z.db"} {
+		suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, name), nil, 0o600))
+	}
+
+	backuper := new(taskmocks.Backuper)
+	backuper.On("Backup", mock.Anything, mock.Anything).Return(errors.New("disk full"))
+
+	task_manager.BackupDatabase(backuper, suite.dir, 1)(context.Background(), nil)
+
+	suite.Len(suite.files(), 2)
+	backuper.AssertExpectations(suite.T())
+}
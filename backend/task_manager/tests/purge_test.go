@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/tejiriaustin/lema/task_manager"
+	"github.com/tejiriaustin/lema/testutils"
+	repomocks "github.com/tejiriaustin/lema/testutils/mocks/repository"
+)
+
+type PurgeTestSuite struct {
+	testutils.BaseSuite
+}
+
+func TestPurge(t *testing.T) {
+	suite.Run(t, new(PurgeTestSuite))
+}
+
+func (suite *PurgeTestSuite) TestPurgesEveryTargetInOrder() {
+	var order []string
+	cutoff := func(before time.Time) bool {
+		return time.Since(before) >= 24*time.Hour && time.Since(before) < 25*time.Hour
+	}
+
+	posts := new(repomocks.Purger)
+	posts.On("Purge", mock.Anything, mock.MatchedBy(cutoff)).
+		Run(func(mock.Arguments) { order = append(order, "posts") }).
+		Return(int64(0), errors.New("database is locked"))
+
+	users := new(repomocks.Purger)
+	users.On("Purge", mock.Anything, mock.MatchedBy(cutoff)).
+		Run(func(mock.Arguments) { order = append(order, "users") }).
+		Return(int64(3), nil)
+
+	job := task_manager.PurgeDeleted(24*time.Hour,
+		task_manager.PurgeTarget{Name: "posts", Purger: posts},
+		task_manager.PurgeTarget{Name: "users", Purger: users},
+	)
+	job(context.Background(), nil)
+
+	suite.Equal([]string{"posts", "users"}, order, "a failed target does not stop the rest")
+	posts.AssertExpectations(suite.T())
+	users.AssertExpectations(suite.T())
+}
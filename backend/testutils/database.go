@@ -0,0 +1,40 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tejiriaustin/lema/database"
+)
+
+// TestDatabaseURL names the environment variable that points the database
+// tests at a Postgres or MySQL server instead of a throwaway SQLite file.
+const TestDatabaseURL = "TEST_DATABASE_URL"
+
+// NewTestDatabase opens the database named by TEST_DATABASE_URL, or a SQLite
+// file in a temporary directory when it is unset, and recreates tables so
+// every caller starts from empty tables. The connection is closed when the
+// test finishes.
+func NewTestDatabase(t testing.TB, name string, tables ...interface{}) *database.Client {
+	t.Helper()
+
+	dsn := os.Getenv(TestDatabaseURL)
+	if dsn == "" {
+		dsn = "sqlite://" + filepath.Join(t.TempDir(), name+".db")
+	}
+
+	dbConn, err := database.Initialize(&database.Config{DB: dsn})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = dbConn.Close() })
+
+	if err := dbConn.DB.Migrator().DropTable(tables...); err != nil {
+		t.Fatalf("failed to drop test tables: %v", err)
+	}
+	if err := dbConn.Migrate(tables...); err != nil {
+		t.Fatalf("failed to migrate test tables: %v", err)
+	}
+	return dbConn
+}